@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+)
+
+// issues a certificate for the public key in one key file, signed by the private key in another
+func issueCertificate(args []string) {
+	flags := flag.NewFlagSet("cert", flag.ExitOnError)
+	issuer_path := flags.String("issuer", "./auth.keys", "key file of the issuing authority")
+	subject_path := flags.String("keys", "", "key file holding the public key to certify, defaults to the issuer (self-signed)")
+	subject := flags.String("subject", "", "name of the party the key belongs to")
+	days := flags.Int("days", 365, "number of days the certificate is valid for")
+	authority := flags.Bool("authority", false, "allow the certificate to issue other certificates")
+	out_path := flags.String("out", "./auth.cert", "location for the certificate")
+
+	flags.Parse(args)
+
+	if *subject_path == "" {
+		subject_path = issuer_path
+	}
+
+	private, group, issuer, err := loadPrivateKey(*issuer_path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	public, err := loadPublicKey(*subject_path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	serial_bytes := make([]byte, 8)
+	_, err = rand.Read(serial_bytes)
+
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	certificate := auth.NewCertificate(binary.BigEndian.Uint64(serial_bytes), *subject, public, now, now.AddDate(0, 0, *days), *authority)
+
+	if err := certificate.Sign(private, group, issuer); err != nil {
+		panic(err)
+	}
+
+	certificate_bytes, err := certificate.Marshal()
+
+	if err != nil {
+		panic(err)
+	}
+
+	out_file, err := os.Create(*out_path)
+
+	if err != nil {
+		panic(err)
+	}
+	defer out_file.Close()
+
+	headers := make(map[string]string)
+	headers["subject"] = *subject
+	headers["serial"] = strconv.FormatUint(certificate.Serial(), 10)
+
+	pem.Encode(out_file, &pem.Block{Type: "FFS CERTIFICATE", Headers: headers, Bytes: certificate_bytes})
+}
+
+// signs a revocation list for the given serials
+func revokeCertificates(args []string) {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	issuer_path := flags.String("issuer", "./auth.keys", "key file of the issuing authority")
+	serials := flags.String("serials", "", "comma separated serials to revoke")
+	days := flags.Int("days", 30, "number of days until the list must be replaced")
+	out_path := flags.String("out", "./auth.crl", "location for the revocation list")
+
+	flags.Parse(args)
+
+	private, group, issuer, err := loadPrivateKey(*issuer_path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	revoked := make([]uint64, 0)
+
+	for _, field := range strings.Split(*serials, ",") {
+		if field == "" {
+			continue
+		}
+
+		serial, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+
+		if err != nil {
+			panic(err)
+		}
+
+		revoked = append(revoked, serial)
+	}
+
+	now := time.Now()
+	list := auth.NewRevocationList(revoked, now, now.AddDate(0, 0, *days))
+
+	if err := list.Sign(private, group, issuer); err != nil {
+		panic(err)
+	}
+
+	list_bytes, err := list.Marshal()
+
+	if err != nil {
+		panic(err)
+	}
+
+	out_file, err := os.Create(*out_path)
+
+	if err != nil {
+		panic(err)
+	}
+	defer out_file.Close()
+
+	pem.Encode(out_file, &pem.Block{Type: "FFS REVOCATION LIST", Headers: nil, Bytes: list_bytes})
+}
+
+// checks a chain of certificates (leaf first) against the public keys of trusted authorities
+func verifyCertificates(args []string) {
+	flags := flag.NewFlagSet("verify-cert", flag.ExitOnError)
+	chain_path := flags.String("chain", "./auth.cert", "file holding the certificate chain, leaf first")
+	anchor_paths := flags.String("anchors", "./auth.keys", "comma separated key files of trusted authorities")
+	crl_paths := flags.String("crl", "", "comma separated revocation lists to check against")
+
+	flags.Parse(args)
+
+	blocks, err := readBlocksOfType(*chain_path, "FFS CERTIFICATE")
+
+	if err != nil {
+		panic(err)
+	}
+
+	chain := make([]*auth.Certificate, 0)
+
+	for _, block := range blocks {
+		certificate, err := auth.ParseCertificate(block.Bytes)
+
+		if err != nil {
+			panic(err)
+		}
+
+		chain = append(chain, certificate)
+	}
+
+	anchors := make([]*auth.FFSPublicKey, 0)
+
+	for _, path := range strings.Split(*anchor_paths, ",") {
+		anchor, err := loadPublicKey(path)
+
+		if err != nil {
+			panic(err)
+		}
+
+		anchors = append(anchors, anchor)
+	}
+
+	revocations := make([]*auth.RevocationList, 0)
+
+	for _, path := range strings.Split(*crl_paths, ",") {
+		if path == "" {
+			continue
+		}
+
+		blocks, err := readBlocksOfType(path, "FFS REVOCATION LIST")
+
+		if err != nil {
+			panic(err)
+		}
+
+		for _, block := range blocks {
+			list, err := auth.ParseRevocationList(block.Bytes)
+
+			if err != nil {
+				panic(err)
+			}
+
+			revocations = append(revocations, list)
+		}
+	}
+
+	if err := auth.VerifyChain(chain, anchors, time.Now(), revocations); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println("certificate for", chain[0].Subject(), "is valid")
+}
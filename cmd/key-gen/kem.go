@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/pem"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// generates a static ECDH key pair, the private file is kept by the recipient and the public file can be
+// compiled into builds that only ever encapsulate
+func generateKEMKeys(args []string) {
+	flags := flag.NewFlagSet("kem", flag.ExitOnError)
+	curve_name := flags.String("curve", "x25519", "curve for the key pair, x25519 or p256")
+	private_path := flags.String("path", "./kem.keys", "location for the private key file")
+	public_path := flags.String("public", "./kem.pub", "location for the public key file")
+
+	flags.Parse(args)
+
+	curve, err := enc.CurveByName(*curve_name)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := os.Stat(*private_path); err == nil {
+		panic(errors.New(*private_path + ": key file already exists"))
+	}
+
+	private, err := enc.ECDHKeyPair(curve)
+
+	if err != nil {
+		panic(err)
+	}
+
+	headers := make(map[string]string)
+	headers["curve"] = *curve_name
+
+	private_block := pem.Block{Type: "ECDH PRIVATE KEY", Headers: headers, Bytes: private.Bytes()}
+	public_block := pem.Block{Type: "ECDH PUBLIC KEY", Headers: headers, Bytes: private.PublicKey().Bytes()}
+
+	private_file, err := os.OpenFile(*private_path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		panic(err)
+	}
+	defer private_file.Close()
+
+	pem.Encode(private_file, &private_block)
+	pem.Encode(private_file, &public_block)
+
+	public_file, err := os.Create(*public_path)
+
+	if err != nil {
+		panic(err)
+	}
+	defer public_file.Close()
+
+	pem.Encode(public_file, &public_block)
+}
@@ -7,7 +7,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io"
+	"math/big"
 	"os"
 	"strconv"
 
@@ -15,10 +15,70 @@ import (
 	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
 )
 
+// writes a key file holding the public key, private key, modulus and KDF master, the file is left open
+func writeKeyFile(path string, group *gt.CompositeMulGroup, public_key, private_key []*big.Int, master_key_bytes []byte) (*os.File, error) {
+	key_file, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	size := group.Ring().Size()
+	public_key_bytes := make([]byte, 0)
+	private_key_bytes := make([]byte, 0)
+	for i := 0; i < len(public_key); i++ {
+		temp_pub := make([]byte, size/8)
+		temp_priv := make([]byte, size/8)
+		public_key_bytes = append(public_key_bytes, public_key[i].FillBytes(temp_pub)...)
+		private_key_bytes = append(private_key_bytes, private_key[i].FillBytes(temp_priv)...)
+	}
+
+	modulus_bytes := make([]byte, size/8)
+	modulus_bytes = group.Modulus().FillBytes(modulus_bytes)
+
+	headers := make(map[string]string)
+	headers["k"] = strconv.Itoa(len(public_key))
+	headers["size"] = strconv.Itoa(size)
+
+	public_block := pem.Block{Type: "FFS PUBLIC KEY", Headers: headers, Bytes: public_key_bytes}
+	private_block := pem.Block{Type: "FFS PRIVATE KEY", Headers: headers, Bytes: private_key_bytes}
+	modulus_block := pem.Block{Type: "FFS MODULUS", Headers: nil, Bytes: modulus_bytes}
+	master_block := pem.Block{Type: "KDF MASTER", Headers: nil, Bytes: master_key_bytes}
+
+	pem.Encode(key_file, &public_block)
+	pem.Encode(key_file, &private_block)
+	pem.Encode(key_file, &modulus_block)
+	pem.Encode(key_file, &master_block)
+
+	return key_file, nil
+}
+
+// subcommands that operate on existing key files, any other invocation generates or exports keys
+var commands = map[string]func([]string){
+	"cert":              issueCertificate,
+	"revoke":            revokeCertificates,
+	"verify-cert":       verifyCertificates,
+	"split":             splitMaster,
+	"combine":           combineMaster,
+	"derive":            deriveKeys,
+	"recover":           recoverKeys,
+	"kem":               generateKEMKeys,
+	"encrypt":           encryptKeyFile,
+	"decrypt":           decryptKeyFile,
+	"change-passphrase": changePassphrase,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	public := flag.Bool("public", false, "generate public key constants")
 	private := flag.Bool("private", false, "generate private key constants")
-	k := flag.Int("k", 32, "the number of elements in the keys")
+	k := flag.Int("k", 128, "the number of elements in the keys")
 	size := flag.Int("size", 3072, "the size of the group to use for proofs")
 	master_size := flag.Int("master-size", 256, "the size of the master key for encapsulation")
 	key_path := flag.String("path", "./auth.keys", "location for the generated key file")
@@ -45,56 +105,41 @@ func main() {
 			panic(err)
 		}
 
-		key_file, err = os.Create(*key_path)
+		master_key_bytes := make([]byte, *master_size)
+		_, err = rand.Reader.Read(master_key_bytes)
+
+		key_file, err = writeKeyFile(*key_path, group, public_key, private_key, master_key_bytes)
 		defer key_file.Close()
 
 		if err != nil {
 			panic(err)
 		}
-
-		public_key_bytes := make([]byte, 0)
-		private_key_bytes := make([]byte, 0)
-		for i := 0; i < len(public_key); i++ {
-			temp_pub := make([]byte, (*size)/8)
-			temp_priv := make([]byte, (*size)/8)
-			public_key_bytes = append(public_key_bytes, public_key[i].FillBytes(temp_pub)...)
-			private_key_bytes = append(private_key_bytes, private_key[i].FillBytes(temp_priv)...)
-		}
-
-		master_key_bytes := make([]byte, *master_size)
-		_, err = rand.Reader.Read(master_key_bytes)
-
-		modulus_bytes := make([]byte, (*size)/8)
-		modulus_bytes = group.Modulus().FillBytes(modulus_bytes)
-
-		headers := make(map[string]string)
-		headers["k"] = strconv.Itoa(*k)
-		headers["size"] = strconv.Itoa(*size)
-
-		public_block := pem.Block{Type: "FFS PUBLIC KEY", Headers: headers, Bytes: public_key_bytes}
-		private_block := pem.Block{Type: "FFS PRIVATE KEY", Headers: headers, Bytes: private_key_bytes}
-		modulus_block := pem.Block{Type: "FFS MODULUS", Headers: nil, Bytes: modulus_bytes}
-		master_block := pem.Block{Type: "KDF MASTER", Headers: nil, Bytes: master_key_bytes}
-
-		pem.Encode(key_file, &public_block)
-		pem.Encode(key_file, &private_block)
-		pem.Encode(key_file, &modulus_block)
-		pem.Encode(key_file, &master_block)
-
-		key_file.Seek(0, 0)
 	}
 
 	if *public || *private {
-		key_bytes, err := io.ReadAll(key_file)
+		blocks, err := readBlocks(*key_path)
+
+		if err != nil {
+			panic(err)
+		}
+
+		public_block_decoded := blocks["FFS PUBLIC KEY"]
+		modulus_block_decoded := blocks["FFS MODULUS"]
+		master_block_decoded, err := unlockBlock(blocks["KDF MASTER"])
 
 		if err != nil {
 			panic(err)
 		}
 
-		public_block_decoded, key_bytes := pem.Decode(key_bytes)
-		private_block_decoded, key_bytes := pem.Decode(key_bytes)
-		modulus_block_decoded, key_bytes := pem.Decode(key_bytes)
-		master_block_decoded, key_bytes := pem.Decode(key_bytes)
+		private_block_decoded := blocks["FFS PRIVATE KEY"]
+
+		if *private {
+			private_block_decoded, err = unlockBlock(private_block_decoded)
+
+			if err != nil {
+				panic(err)
+			}
+		}
 
 		var out_file *os.File
 		defer out_file.Close()
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+)
+
+// reads every PEM block in a file, in order
+func readBlockList(path string) ([]*pem.Block, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*pem.Block, 0)
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+
+		if block == nil {
+			break
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+// reads every PEM block in a file, keyed by block type
+func readBlocks(path string) (map[string]*pem.Block, error) {
+	list, err := readBlockList(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[string]*pem.Block)
+
+	for _, block := range list {
+		blocks[block.Type] = block
+	}
+
+	return blocks, nil
+}
+
+// reads every PEM block of a given type in a file, in order
+func readBlocksOfType(path string, block_type string) ([]*pem.Block, error) {
+	list, err := readBlockList(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*pem.Block, 0)
+
+	for _, block := range list {
+		if block.Type == block_type {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks, nil
+}
+
+// splits a key block into its elements using the size header
+func decodeElements(block *pem.Block) ([]*big.Int, error) {
+	if block == nil {
+		return nil, errors.New("missing key block")
+	}
+
+	size, err := strconv.Atoi(block.Headers["size"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	width := size / 8
+	elements := make([]*big.Int, 0)
+
+	for i := 0; i+width <= len(block.Bytes); i += width {
+		element := big.NewInt(0)
+		element.SetBytes(block.Bytes[i : i+width])
+		elements = append(elements, element)
+	}
+
+	return elements, nil
+}
+
+// loads the public key and modulus from a key file
+func loadPublicKey(path string) (*auth.FFSPublicKey, error) {
+	blocks, err := readBlocks(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := decodeElements(blocks["FFS PUBLIC KEY"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	if blocks["FFS MODULUS"] == nil {
+		return nil, errors.New("missing modulus block")
+	}
+
+	modulus := big.NewInt(0)
+	modulus.SetBytes(blocks["FFS MODULUS"].Bytes)
+
+	return auth.NewFFSPublicKey(public, modulus), nil
+}
+
+// loads the private key, its group and matching public key from a key file
+func loadPrivateKey(path string) ([]*big.Int, *gt.CompositeMulGroup, *auth.FFSPublicKey, error) {
+	blocks, err := readBlocks(path)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	private_block, err := unlockBlock(blocks["FFS PRIVATE KEY"])
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	private, err := decodeElements(private_block)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	public, err := loadPublicKey(path)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	group := gt.NewCompGroup(gt.SetupModRing(public.Modulus()))
+
+	return private, group, public, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// block types that hold secrets and are encrypted by the encrypt command
+var secretBlockTypes = map[string]bool{
+	"FFS PRIVATE KEY":  true,
+	"KDF MASTER":       true,
+	"ECDH PRIVATE KEY": true,
+}
+
+// passphrases can be given in these variables instead of being typed
+const (
+	passphraseVariable     = "ZEROCAT_PASSPHRASE"
+	newPassphraseVariable  = "ZEROCAT_NEW_PASSPHRASE"
+	seedPassphraseVariable = "ZEROCAT_SEED_PASSPHRASE"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// the passphrase unlocking key files, asked for at most once
+var cachedPassphrase []byte
+
+// reads a passphrase from the environment variable, or prompts for a line on standard input
+func readPassphrase(variable, prompt string) ([]byte, error) {
+	if value, ok := os.LookupEnv(variable); ok {
+		return []byte(value), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+
+	if err != nil && line == "" {
+		return nil, err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+
+	if line == "" {
+		return nil, errors.New("empty passphrase")
+	}
+
+	return []byte(line), nil
+}
+
+// returns the block decrypted if it is protected by a passphrase
+func unlockBlock(block *pem.Block) (*pem.Block, error) {
+	if block == nil || !enc.IsEncryptedPEMBlock(block) {
+		return block, nil
+	}
+
+	if cachedPassphrase == nil {
+		passphrase, err := readPassphrase(passphraseVariable, "passphrase: ")
+
+		if err != nil {
+			return nil, err
+		}
+
+		cachedPassphrase = passphrase
+	}
+
+	return enc.DecryptPEMBlock(block, cachedPassphrase)
+}
+
+// replaces a key file with the given blocks, writing a temporary file first so a failure leaves the old one intact
+func rewriteKeyFile(path string, blocks []*pem.Block) error {
+	temp_file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp_file.Name())
+
+	if err := temp_file.Chmod(0600); err != nil {
+		temp_file.Close()
+		return err
+	}
+
+	for _, block := range blocks {
+		if err := pem.Encode(temp_file, block); err != nil {
+			temp_file.Close()
+			return err
+		}
+	}
+
+	if err := temp_file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp_file.Name(), path)
+}
+
+// applies the transform to every secret block of a key file and rewrites it
+func transformKeyFile(path string, transform func(*pem.Block) (*pem.Block, error)) {
+	blocks, err := readBlockList(path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	changed := 0
+
+	for i, block := range blocks {
+		if !secretBlockTypes[block.Type] {
+			continue
+		}
+
+		blocks[i], err = transform(block)
+
+		if err != nil {
+			panic(errors.New(path + ": " + block.Type + ": " + err.Error()))
+		}
+
+		changed++
+	}
+
+	if changed == 0 {
+		panic(errors.New(path + ": no private key blocks"))
+	}
+
+	if err := rewriteKeyFile(path, blocks); err != nil {
+		panic(err)
+	}
+}
+
+// reads a new passphrase, asking twice when it is typed
+func readNewPassphrase() []byte {
+	return readConfirmedPassphrase(newPassphraseVariable, "new passphrase: ")
+}
+
+// reads a passphrase that is about to protect something, asking twice when it is typed
+func readConfirmedPassphrase(variable, prompt string) []byte {
+	passphrase, err := readPassphrase(variable, prompt)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if _, ok := os.LookupEnv(variable); !ok {
+		confirmation, err := readPassphrase(variable, "repeat passphrase: ")
+
+		if err != nil {
+			panic(err)
+		}
+
+		if string(confirmation) != string(passphrase) {
+			panic(errors.New("passphrases do not match"))
+		}
+	}
+
+	return passphrase
+}
+
+// encrypts the private key, master and ECDH private key blocks of a key file under a passphrase
+func encryptKeyFile(args []string) {
+	flags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	key_path := flags.String("path", "./auth.keys", "key file to encrypt")
+	iterations := flags.Int("iterations", enc.DefaultKeyFileIterations, "PBKDF2 iterations for the passphrase")
+
+	flags.Parse(args)
+
+	passphrase := readNewPassphrase()
+
+	transformKeyFile(*key_path, func(block *pem.Block) (*pem.Block, error) {
+		if enc.IsEncryptedPEMBlock(block) {
+			return nil, errors.New("already encrypted, use change-passphrase")
+		}
+
+		return enc.EncryptPEMBlock(block, passphrase, *iterations)
+	})
+}
+
+// removes the passphrase from a key file
+func decryptKeyFile(args []string) {
+	flags := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	key_path := flags.String("path", "./auth.keys", "key file to decrypt")
+
+	flags.Parse(args)
+
+	transformKeyFile(*key_path, unlockBlock)
+}
+
+// re-encrypts a key file under a new passphrase, blocks that were not encrypted are encrypted too
+func changePassphrase(args []string) {
+	flags := flag.NewFlagSet("change-passphrase", flag.ExitOnError)
+	key_path := flags.String("path", "./auth.keys", "key file to re-encrypt")
+	iterations := flags.Int("iterations", enc.DefaultKeyFileIterations, "PBKDF2 iterations for the new passphrase")
+
+	flags.Parse(args)
+
+	// ask for the old passphrase before the new one
+	blocks, err := readBlockList(*key_path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	for _, block := range blocks {
+		if secretBlockTypes[block.Type] && enc.IsEncryptedPEMBlock(block) {
+			if _, err := unlockBlock(block); err != nil {
+				panic(err)
+			}
+
+			break
+		}
+	}
+
+	passphrase := readNewPassphrase()
+
+	transformKeyFile(*key_path, func(block *pem.Block) (*pem.Block, error) {
+		block, err := unlockBlock(block)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return enc.EncryptPEMBlock(block, passphrase, *iterations)
+	})
+}
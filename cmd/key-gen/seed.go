@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+)
+
+var masterLabel = []byte("kdf master")
+
+// the key shape flags shared by derive and recover, a key is only rebuilt if these match
+func seedFlags(flags *flag.FlagSet) (*int, *int, *int, *string) {
+	k := flags.Int("k", 128, "the number of elements in the keys")
+	size := flags.Int("size", 3072, "the size of the group to use for proofs")
+	master_size := flags.Int("master-size", 256, "the size of the master key for encapsulation")
+	key_path := flags.String("path", "./auth.keys", "location for the key file")
+
+	return k, size, master_size, key_path
+}
+
+// writes the key file that the seed determines
+func writeSeededKeyFile(seed []byte, k, size, master_size int, key_path string) error {
+	group, public_key, private_key, err := auth.FFSKeyPairFromSeed(seed, k, size)
+
+	if err != nil {
+		return err
+	}
+
+	random, err := auth.SeedRandom(seed, masterLabel)
+
+	if err != nil {
+		return err
+	}
+
+	master_key_bytes := make([]byte, master_size)
+
+	if _, err := io.ReadFull(random, master_key_bytes); err != nil {
+		return err
+	}
+
+	key_file, err := writeKeyFile(key_path, group, public_key, private_key, master_key_bytes)
+
+	if err != nil {
+		return err
+	}
+
+	return key_file.Close()
+}
+
+// derives a new key file from a fresh seed or a passphrase and prints the seed backup
+func deriveKeys(args []string) {
+	flags := flag.NewFlagSet("derive", flag.ExitOnError)
+	passphrase := flags.Bool("passphrase", false, "derive the seed from a passphrase, read from "+seedPassphraseVariable+" or prompted for, instead of drawing a fresh one")
+	k, size, master_size, key_path := seedFlags(flags)
+
+	flags.Parse(args)
+
+	if _, err := os.Stat(*key_path); err == nil {
+		panic(errors.New(*key_path + ": key file already exists"))
+	}
+
+	var seed []byte
+	var err error
+
+	if *passphrase {
+		seed = auth.SeedFromPassphrase(string(readConfirmedPassphrase(seedPassphraseVariable, "seed passphrase: ")))
+	} else {
+		seed, err = auth.NewSeed()
+
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if err := writeSeededKeyFile(seed, *k, *size, *master_size, *key_path); err != nil {
+		panic(err)
+	}
+
+	words, _ := auth.SeedToWords(seed)
+	encoded, _ := auth.SeedToString(seed)
+
+	fmt.Println("words:", words)
+	fmt.Println("seed:", encoded)
+	fmt.Println("recover with -k", *k, "-size", *size, "-master-size", *master_size)
+}
+
+// rebuilds the key file from a seed backup or the passphrase it was derived from
+func recoverKeys(args []string) {
+	flags := flag.NewFlagSet("recover", flag.ExitOnError)
+	words := flags.String("words", "", "seed backup as words")
+	encoded := flags.String("seed", "", "seed backup as an encoded string")
+	passphrase := flags.Bool("passphrase", false, "rebuild from the passphrase the seed was derived from, read from "+seedPassphraseVariable+" or prompted for")
+	force := flags.Bool("force", false, "overwrite an existing key file")
+	k, size, master_size, key_path := seedFlags(flags)
+
+	flags.Parse(args)
+
+	if _, err := os.Stat(*key_path); err == nil && !*force {
+		panic(errors.New(*key_path + ": key file already exists, use -force to overwrite it"))
+	}
+
+	var seed []byte
+	var err error
+
+	switch {
+	case *words != "":
+		seed, err = auth.SeedFromWords(*words)
+	case *encoded != "":
+		seed, err = auth.SeedFromString(*encoded)
+	case *passphrase:
+		var line []byte
+		line, err = readPassphrase(seedPassphraseVariable, "seed passphrase: ")
+		seed = auth.SeedFromPassphrase(string(line))
+	default:
+		err = errors.New("one of -words, -seed or -passphrase is required")
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := writeSeededKeyFile(seed, *k, *size, *master_size, *key_path); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc/shamir"
+)
+
+// splits the KDF master of a key file into share files, any threshold of which can rebuild it
+func splitMaster(args []string) {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	key_path := flags.String("path", "./auth.keys", "key file holding the master to split")
+	threshold := flags.Int("threshold", 2, "number of shares needed to rebuild the master")
+	count := flags.Int("shares", 3, "number of shares to produce")
+	out_prefix := flags.String("out", "./master", "prefix for the share files, written as <prefix>.share.<index>")
+	group_size := flags.Int("group-size", 2048, "size of the group used for the share commitments")
+	order_size := flags.Int("order-size", 256, "size of the prime order of the commitment group")
+
+	flags.Parse(args)
+
+	blocks, err := readBlocks(*key_path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	master_block, err := unlockBlock(blocks["KDF MASTER"])
+
+	if err != nil {
+		panic(err)
+	}
+
+	if master_block == nil {
+		panic(errors.New("missing master block"))
+	}
+
+	group, err := gt.SetupPrimeOrderGroup(*group_size, *order_size)
+
+	if err != nil {
+		panic(err)
+	}
+
+	shares, commitments, err := shamir.Split(group, master_block.Bytes, *threshold, *count)
+
+	if err != nil {
+		panic(err)
+	}
+
+	commitment_block := pem.Block{Type: "PEDERSEN COMMITMENTS", Headers: nil, Bytes: commitments.Marshal()}
+
+	for _, share := range shares {
+		share_file, err := os.OpenFile(*out_prefix+".share."+strconv.FormatUint(uint64(share.Index()), 10), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+		if err != nil {
+			panic(err)
+		}
+
+		headers := make(map[string]string)
+		headers["index"] = strconv.FormatUint(uint64(share.Index()), 10)
+		headers["threshold"] = strconv.FormatUint(uint64(share.Threshold()), 10)
+
+		pem.Encode(share_file, &pem.Block{Type: "KDF MASTER SHARE", Headers: headers, Bytes: share.Marshal()})
+		pem.Encode(share_file, &commitment_block)
+		share_file.Close()
+	}
+}
+
+// checks share files against their commitments and rebuilds the KDF master from them
+func combineMaster(args []string) {
+	flags := flag.NewFlagSet("combine", flag.ExitOnError)
+	share_paths := flags.String("shares", "", "comma separated share files")
+	out_path := flags.String("out", "./master.pem", "location for the rebuilt master")
+
+	flags.Parse(args)
+
+	shares := make([]*shamir.Share, 0)
+	var commitment_bytes []byte
+
+	for _, path := range strings.Split(*share_paths, ",") {
+		blocks, err := readBlocks(path)
+
+		if err != nil {
+			panic(err)
+		}
+
+		if blocks["KDF MASTER SHARE"] == nil || blocks["PEDERSEN COMMITMENTS"] == nil {
+			panic(errors.New(path + ": missing share or commitment block"))
+		}
+
+		// every holder must have been dealt the same commitments
+		if commitment_bytes == nil {
+			commitment_bytes = blocks["PEDERSEN COMMITMENTS"].Bytes
+		} else if !bytes.Equal(commitment_bytes, blocks["PEDERSEN COMMITMENTS"].Bytes) {
+			panic(errors.New(path + ": commitments differ from the other shares"))
+		}
+
+		share, err := shamir.ParseShare(blocks["KDF MASTER SHARE"].Bytes)
+
+		if err != nil {
+			panic(err)
+		}
+
+		shares = append(shares, share)
+	}
+
+	commitments, err := shamir.ParseCommitments(commitment_bytes)
+
+	if err != nil {
+		panic(err)
+	}
+
+	master, err := shamir.Combine(commitments.Group(), shares, commitments)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	out_file, err := os.OpenFile(*out_path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		panic(err)
+	}
+	defer out_file.Close()
+
+	pem.Encode(out_file, &pem.Block{Type: "KDF MASTER", Headers: nil, Bytes: master})
+}
@@ -1,7 +1,7 @@
 //go:build private
 // +build private
 
-//go:generate go run ../key-gen/key_gen_ffs.go --private --path ../../keys/auth.keys
+//go:generate go run ../key-gen --private --path ../../keys/auth.keys
 
 package main
 
@@ -10,9 +10,12 @@ import (
 	"encoding/base64"
 	"flag"
 	"fmt"
+	"io"
 	"math/big"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/SimonHorrocks/Zerocat/pkg/auth"
 	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
@@ -71,24 +74,41 @@ func main() {
 	banner()
 
 	send := make(chan []byte)
+	// the session ends on the first error or signal, returning from main so the deferred destroys run
+	failed := make(chan error, 2)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
 	input_wrapper := comm.NewFFSInputWrapper(prover, os.Stdin)
 
 	deriver := enc.NewSha256Deriver(master_key)
+	enc.Wipe(master_key)
 	encapsulator := enc.NewAESEncapsulator(deriver)
+	sealer := enc.NewKEMDEM(encapsulator, enc.NewGCMDEM())
+	defer encapsulator.Destroy()
+	defer prover.Destroy()
 
 	encapsulation_buffer := new(bytes.Buffer)
-	encapsulation_wrapper := comm.NewEncapsulationWrapper(encapsulator, encapsulation_buffer)
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(sealer, encapsulation_buffer)
 
 	go func() {
 		for {
 			data, err := input_wrapper.Wrap()
-			challenger.Update(data[prover.Group().Ring().Size()/4:])
 
 			if err != nil {
-				panic(err)
+				failed <- err
+				return
 			}
 
+			message, err := comm.ProofMessage(data)
+
+			if err != nil {
+				failed <- err
+				return
+			}
+
+			challenger.Update(message)
+
 			send <- data
 		}
 	}()
@@ -100,18 +120,21 @@ func main() {
 	}
 
 	if connection, err := listenner.Accept(); err == nil {
+		defer connection.Close()
 
-		decryption_wrapper := comm.NewDecryptionWrapper(encapsulator, connection, 12, 32)
+		decryption_wrapper := comm.NewDecryptionWrapper(sealer, connection)
 
 		go func() {
 			for {
 				data, err := decryption_wrapper.Wrap()
 
 				if err != nil {
-					panic(err)
+					failed <- err
+					return
 				}
 
 				os.Stdout.Write(data)
+				comm.Release(data)
 			}
 		}()
 
@@ -122,10 +145,17 @@ func main() {
 				data, err := encapsulation_wrapper.Wrap()
 
 				if err != nil {
-					panic(err)
+					report(err)
+					return
 				}
 
 				connection.Write(data)
+				comm.Release(data)
+			case err := <-failed:
+				report(err)
+				return
+			case <-signals:
+				return
 			}
 		}
 	} else {
@@ -133,6 +163,13 @@ func main() {
 	}
 }
 
+// a closed connection or input is the normal end of a session
+func report(err error) {
+	if err != io.EOF {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
 func banner() {
 	fmt.Println("")
 	fmt.Println("    .@@/                        (@@.    ")
@@ -1,7 +1,7 @@
 //go:build public
 // +build public
 
-//go:generate go run ../key-gen/key_gen_ffs.go --public --path ../../keys/auth.keys
+//go:generate go run ../key-gen --public --path ../../keys/auth.keys
 
 package main
 
@@ -9,9 +9,14 @@ import (
 	"bytes"
 	"encoding/base64"
 	"flag"
+	"fmt"
+	"io"
 	"math/big"
 	"net"
+	"os"
 	"os/exec"
+	"os/signal"
+	"syscall"
 
 	"github.com/SimonHorrocks/Zerocat/pkg/auth"
 	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
@@ -91,23 +96,31 @@ func main() {
 
 	send := make(chan []byte)
 	recieve := make(chan []byte)
+	// the session ends on the first error or signal, returning from main so the deferred destroy runs
+	failed := make(chan error, 2)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
 	deriver := enc.NewSha256Deriver(master_key)
+	enc.Wipe(master_key)
 	encapsulator := enc.NewAESEncapsulator(deriver)
+	sealer := enc.NewKEMDEM(encapsulator, enc.NewGCMDEM())
+	defer encapsulator.Destroy()
 
-	decryption_wrapper := comm.NewDecryptionWrapper(encapsulator, connection, 12, 32)
+	decryption_wrapper := comm.NewDecryptionWrapper(sealer, connection)
 
 	encapsulation_buffer := new(bytes.Buffer)
-	encapsulation_wrapper := comm.NewEncapsulationWrapper(encapsulator, encapsulation_buffer)
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(sealer, encapsulation_buffer)
 
-	go reverseProof(*command, send, recieve, forward)
+	go reverseProof(*command, send, recieve, failed, forward)
 
 	go func() {
 		for {
 			data, err := decryption_wrapper.Wrap()
 
 			if err != nil {
-				panic(err)
+				failed <- err
+				return
 			}
 
 			recieve <- data
@@ -121,15 +134,29 @@ func main() {
 			data, err := encapsulation_wrapper.Wrap()
 
 			if err != nil {
-				panic(err)
+				report(err)
+				return
 			}
 
 			connection.Write(data)
+			comm.Release(data)
+		case err := <-failed:
+			report(err)
+			return
+		case <-signals:
+			return
 		}
 	}
 }
 
-func reverseProof(command string, send chan []byte, recieve chan []byte, forward net.Conn) {
+// a closed connection is the normal end of a session
+func report(err error) {
+	if err != io.EOF {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func reverseProof(command string, send chan []byte, recieve chan []byte, failed chan error, forward net.Conn) {
 	cmd := exec.Command(command)
 
 	stdin, _ := cmd.StdinPipe()
@@ -147,7 +174,8 @@ func reverseProof(command string, send chan []byte, recieve chan []byte, forward
 				data, err := output_wrapper.Wrap()
 
 				if err != nil {
-					panic(err)
+					failed <- err
+					return
 				}
 
 				if data[0] == 1 {
@@ -0,0 +1,297 @@
+package accumulator
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+)
+
+var (
+	ErrMember    = errors.New("accumulator: element is already a member")
+	ErrNotMember = errors.New("accumulator: element is not a member")
+)
+
+// size of the primes elements are mapped to
+const primeSize = 256
+
+var primeLabel = []byte("zerocat accumulator hash to prime")
+
+// maps an element to a prime by hashing it with a counter until the result is prime
+func HashToPrime(element []byte) *big.Int {
+	candidate := big.NewInt(0)
+
+	for counter := uint32(0); ; counter++ {
+		hash := sha256.New()
+		hash.Write(primeLabel)
+		hash.Write(binary.BigEndian.AppendUint32(nil, counter))
+		hash.Write(element)
+		digest := hash.Sum(nil)
+
+		// force the top bit so every prime is exactly primeSize bits and the low bit so it is odd
+		digest[0] |= 0x80
+		digest[len(digest)-1] |= 1
+		candidate.SetBytes(digest)
+
+		if candidate.ProbablyPrime(20) {
+			return candidate
+		}
+	}
+}
+
+// an RSA accumulator: value = base ** (x1 * x2 * ... xn) mod n where xi are the hashed members.
+// the manager keeps the members in order to produce witnesses, verifiers only need the value
+type Accumulator struct {
+	modulus *big.Int
+	base    *big.Int
+	value   *big.Int
+	members []*big.Int
+}
+
+// sets up an empty accumulator using a random quadratic residue of the group as the base
+func Setup(group *gt.CompositeMulGroup) (*Accumulator, error) {
+	root, err := group.Random()
+
+	if err != nil {
+		return nil, err
+	}
+
+	base := big.NewInt(0)
+	base.Exp(root, gt.Two, group.Modulus())
+
+	return NewAccumulator(group.Modulus(), base), nil
+}
+
+// for the purposes of deserialization, creates an empty accumulator from its public parameters
+func NewAccumulator(modulus, base *big.Int) *Accumulator {
+	accumulator := new(Accumulator)
+	accumulator.modulus = modulus
+	accumulator.base = base
+	accumulator.value = big.NewInt(0)
+	accumulator.value.Set(base)
+	accumulator.members = make([]*big.Int, 0)
+
+	return accumulator
+}
+
+func (accumulator *Accumulator) Modulus() *big.Int {
+	return accumulator.modulus
+}
+
+func (accumulator *Accumulator) Base() *big.Int {
+	return accumulator.base
+}
+
+// the constant size value representing the whole set
+func (accumulator *Accumulator) Value() *big.Int {
+	return accumulator.value
+}
+
+func (accumulator *Accumulator) index(prime *big.Int) int {
+	for i := 0; i < len(accumulator.members); i++ {
+		if accumulator.members[i].Cmp(prime) == 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// adds an element returning the new value
+func (accumulator *Accumulator) Add(element []byte) (*big.Int, error) {
+	return accumulator.BatchAdd([][]byte{element})
+}
+
+// adds several elements with a single exponentiation, returning the new value
+func (accumulator *Accumulator) BatchAdd(elements [][]byte) (*big.Int, error) {
+	primes := make([]*big.Int, 0)
+
+	for i := 0; i < len(elements); i++ {
+		prime := HashToPrime(elements[i])
+
+		if accumulator.index(prime) != -1 {
+			return nil, ErrMember
+		}
+
+		for j := 0; j < len(primes); j++ {
+			if primes[j].Cmp(prime) == 0 {
+				return nil, ErrMember
+			}
+		}
+
+		primes = append(primes, prime)
+	}
+
+	value := big.NewInt(0)
+	value.Exp(accumulator.value, product(primes, nil), accumulator.modulus)
+	accumulator.value = value
+	accumulator.members = append(accumulator.members, primes...)
+
+	return accumulator.value, nil
+}
+
+// removes an element, recomputing the value from the remaining members
+func (accumulator *Accumulator) Delete(element []byte) (*big.Int, error) {
+	i := accumulator.index(HashToPrime(element))
+
+	if i == -1 {
+		return nil, ErrNotMember
+	}
+
+	accumulator.members = append(accumulator.members[:i], accumulator.members[i+1:]...)
+	value := big.NewInt(0)
+	value.Exp(accumulator.base, product(accumulator.members, nil), accumulator.modulus)
+	accumulator.value = value
+
+	return accumulator.value, nil
+}
+
+// the witness for a member is the accumulation of every other member
+func (accumulator *Accumulator) MembershipWitness(element []byte) (*big.Int, error) {
+	prime := HashToPrime(element)
+
+	if accumulator.index(prime) == -1 {
+		return nil, ErrNotMember
+	}
+
+	witness := big.NewInt(0)
+	return witness.Exp(accumulator.base, product(accumulator.members, prime), accumulator.modulus), nil
+}
+
+// a proof that an element is not in the accumulator: value ** a * d ** x = base
+type NonMembershipWitness struct {
+	a *big.Int
+	d *big.Int
+}
+
+func NewNonMembershipWitness(a, d *big.Int) *NonMembershipWitness {
+	witness := new(NonMembershipWitness)
+	witness.a = a
+	witness.d = d
+
+	return witness
+}
+
+func (witness *NonMembershipWitness) A() *big.Int {
+	return witness.a
+}
+
+func (witness *NonMembershipWitness) D() *big.Int {
+	return witness.d
+}
+
+// produces a non-membership witness using bezout coefficients a * u + b * x = 1 where u is the product of members
+func (accumulator *Accumulator) NonMembershipWitness(element []byte) (*NonMembershipWitness, error) {
+	prime := HashToPrime(element)
+
+	if accumulator.index(prime) != -1 {
+		return nil, ErrMember
+	}
+
+	members := product(accumulator.members, nil)
+	a := big.NewInt(0)
+	b := big.NewInt(0)
+	big.NewInt(0).GCD(a, b, members, prime)
+
+	// shift a into [0, x) so the witness does not leak the size of the product
+	shift := big.NewInt(0)
+	shift.Div(a, prime)
+	a.Mod(a, prime)
+	shift.Mul(shift, members)
+	b.Add(b, shift)
+
+	return NewNonMembershipWitness(a, signedExp(accumulator.base, b, accumulator.modulus)), nil
+}
+
+// checks witness ** x = value
+func VerifyMembership(modulus, value *big.Int, element []byte, witness *big.Int) bool {
+	check := big.NewInt(0)
+	check.Exp(witness, HashToPrime(element), modulus)
+
+	return check.Cmp(value) == 0
+}
+
+// checks value ** a * d ** x = base
+func VerifyNonMembership(modulus, base, value *big.Int, element []byte, witness *NonMembershipWitness) bool {
+	prime := HashToPrime(element)
+
+	if witness.a.Sign() < 0 || witness.a.Cmp(prime) >= 0 {
+		return false
+	}
+
+	check := big.NewInt(0)
+	check.Exp(value, witness.a, modulus)
+	other := big.NewInt(0)
+	other.Exp(witness.d, prime, modulus)
+	check.Mul(check, other)
+	check.Mod(check, modulus)
+
+	return check.Cmp(base) == 0
+}
+
+// brings a membership witness up to date after elements were added
+func UpdateWitnessOnAdd(modulus, witness *big.Int, added [][]byte) *big.Int {
+	primes := make([]*big.Int, 0)
+
+	for i := 0; i < len(added); i++ {
+		primes = append(primes, HashToPrime(added[i]))
+	}
+
+	updated := big.NewInt(0)
+	return updated.Exp(witness, product(primes, nil), modulus)
+}
+
+// brings a membership witness up to date after another element was deleted, value is the new accumulator value
+func UpdateWitnessOnDelete(modulus, witness *big.Int, element, deleted []byte, value *big.Int) (*big.Int, error) {
+	prime := HashToPrime(element)
+	removed := HashToPrime(deleted)
+
+	if prime.Cmp(removed) == 0 {
+		return nil, ErrNotMember
+	}
+
+	// a * x + b * y = 1 gives witness' = witness ** b * value ** a
+	a := big.NewInt(0)
+	b := big.NewInt(0)
+	big.NewInt(0).GCD(a, b, prime, removed)
+
+	updated := signedExp(witness, b, modulus)
+	updated.Mul(updated, signedExp(value, a, modulus))
+
+	return updated.Mod(updated, modulus), nil
+}
+
+// multiplies every prime together, skipping the first one equal to skip
+func product(primes []*big.Int, skip *big.Int) *big.Int {
+	result := big.NewInt(1)
+	skipped := false
+
+	for i := 0; i < len(primes); i++ {
+		if !skipped && skip != nil && primes[i].Cmp(skip) == 0 {
+			skipped = true
+			continue
+		}
+
+		result.Mul(result, primes[i])
+	}
+
+	return result
+}
+
+// computes base ** exponent mod n allowing negative exponents through the inverse of the base
+func signedExp(base, exponent, modulus *big.Int) *big.Int {
+	result := big.NewInt(0)
+
+	if exponent.Sign() >= 0 {
+		return result.Exp(base, exponent, modulus)
+	}
+
+	inverse := big.NewInt(0)
+	inverse.ModInverse(base, modulus)
+	positive := big.NewInt(0)
+	positive.Neg(exponent)
+
+	return result.Exp(inverse, positive, modulus)
+}
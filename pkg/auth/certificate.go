@@ -0,0 +1,484 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"time"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+var (
+	ErrEmptyChain        = errors.New("auth: empty certificate chain")
+	ErrUnknownIssuer     = errors.New("auth: certificate issuer is not trusted")
+	ErrNotAuthority      = errors.New("auth: certificate issuer is not an authority")
+	ErrBadSignature      = errors.New("auth: invalid signature")
+	ErrExpired           = errors.New("auth: outside of validity period")
+	ErrRevoked           = errors.New("auth: certificate has been revoked")
+	ErrUnsigned          = errors.New("auth: object has not been signed")
+	ErrRevocationExpired = errors.New("auth: revocation list is out of date")
+	ErrWeakKey           = errors.New("auth: key has too few elements to sign certificates")
+)
+
+// a signature under k elements can be forged with about 2**k hashes by grinding the statement until the
+// challenge avoids every secret, so signing and verifying keys need at least this many
+const MinCertificateKeys = 128
+
+// domain separation tags so a signature over one kind of object is never valid for another
+var (
+	certificateTag = []byte("zerocat certificate v1")
+	revocationTag  = []byte("zerocat revocation list v1")
+)
+
+// a feige-fiat-shamir public key together with the modulus it lives under
+type FFSPublicKey struct {
+	elements []*big.Int
+	modulus  *big.Int
+}
+
+// constructs a public key from its elements and modulus
+func NewFFSPublicKey(elements []*big.Int, modulus *big.Int) *FFSPublicKey {
+	key := new(FFSPublicKey)
+	key.elements = elements
+	key.modulus = modulus
+
+	return key
+}
+
+func (key *FFSPublicKey) Elements() []*big.Int {
+	return key.elements
+}
+
+func (key *FFSPublicKey) Modulus() *big.Int {
+	return key.modulus
+}
+
+// encodes the key as the modulus followed by each element padded to the size of the modulus
+func (key *FFSPublicKey) Marshal() []byte {
+	size := (key.modulus.BitLen() + 7) / 8
+	output := make([]byte, 0)
+	output = enc.AppendField(output, key.modulus.Bytes())
+	output = appendUint64(output, uint64(len(key.elements)))
+
+	for i := 0; i < len(key.elements); i++ {
+		element := make([]byte, size)
+		output = enc.AppendField(output, key.elements[i].FillBytes(element))
+	}
+
+	return output
+}
+
+// identifies a key by the SHA256 hash of its encoding
+func (key *FFSPublicKey) KeyID() []byte {
+	hash := sha256.New()
+	hash.Write(key.Marshal())
+
+	return hash.Sum(nil)
+}
+
+// decodes a public key produced by Marshal
+func ParseFFSPublicKey(data []byte) (*FFSPublicKey, error) {
+	reader := newFieldReader(data)
+	key, err := readFFSPublicKey(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return key, reader.finish()
+}
+
+func readFFSPublicKey(reader *fieldReader) (*FFSPublicKey, error) {
+	modulus := reader.bigInt()
+	count := reader.uint64()
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	elements := make([]*big.Int, 0)
+
+	for i := uint64(0); i < count && reader.err == nil; i++ {
+		elements = append(elements, reader.bigInt())
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return NewFFSPublicKey(elements, modulus), nil
+}
+
+// signs a block as a non-interactive feige-fiat-shamir proof under a fresh challenger
+func signBlock(block []byte, private []*big.Int, group *gt.CompositeMulGroup) (*Proof, error) {
+	if len(private) < MinCertificateKeys {
+		return nil, ErrWeakKey
+	}
+
+	randomness, err := group.Random()
+
+	if err != nil {
+		return nil, err
+	}
+
+	prover := SetupFFSProver(private, NewChainChallenger(), group)
+
+	return prover.ProofGen(randomness, block)
+}
+
+// checks a signature produced by signBlock
+func verifyBlock(block []byte, signature *Proof, key *FFSPublicKey) bool {
+	if signature == nil || len(key.elements) < MinCertificateKeys {
+		return false
+	}
+
+	// a zero statement and proof would satisfy the verification equation for any block
+	group := gt.NewCompGroup(gt.SetupModRing(key.modulus))
+
+	if !group.In(signature.statement) || !group.In(signature.proof) {
+		return false
+	}
+
+	verifier := SetupFFSVerifier(key.elements, NewChainChallenger(), key.modulus)
+
+	return verifier.Verify(signature, block)
+}
+
+func appendSignature(buf []byte, signature *Proof) []byte {
+	buf = enc.AppendField(buf, signature.statement.Bytes())
+	return enc.AppendField(buf, signature.proof.Bytes())
+}
+
+func readSignature(reader *fieldReader) *Proof {
+	statement := reader.bigInt()
+	proof := reader.bigInt()
+
+	return NewProof(statement, proof)
+}
+
+// binds a feige-fiat-shamir public key to a subject name for a period of time, signed by an issuer
+type Certificate struct {
+	serial     uint64
+	subject    string
+	public     *FFSPublicKey
+	not_before time.Time
+	not_after  time.Time
+	authority  bool
+	issuer_id  []byte
+	signature  *Proof
+}
+
+// constructs an unsigned certificate, authority marks whether it may issue other certificates
+func NewCertificate(serial uint64, subject string, public *FFSPublicKey, not_before, not_after time.Time, authority bool) *Certificate {
+	certificate := new(Certificate)
+	certificate.serial = serial
+	certificate.subject = subject
+	certificate.public = public
+	certificate.not_before = not_before
+	certificate.not_after = not_after
+	certificate.authority = authority
+
+	return certificate
+}
+
+func (certificate *Certificate) Serial() uint64 {
+	return certificate.serial
+}
+
+func (certificate *Certificate) Subject() string {
+	return certificate.subject
+}
+
+func (certificate *Certificate) PublicKey() *FFSPublicKey {
+	return certificate.public
+}
+
+func (certificate *Certificate) NotBefore() time.Time {
+	return certificate.not_before
+}
+
+func (certificate *Certificate) NotAfter() time.Time {
+	return certificate.not_after
+}
+
+func (certificate *Certificate) Authority() bool {
+	return certificate.authority
+}
+
+func (certificate *Certificate) IssuerKeyID() []byte {
+	return certificate.issuer_id
+}
+
+// checks that the time falls inside the validity period
+func (certificate *Certificate) ValidAt(now time.Time) bool {
+	return !now.Before(certificate.not_before) && !now.After(certificate.not_after)
+}
+
+// the encoded certificate without its signature
+func (certificate *Certificate) body() []byte {
+	output := make([]byte, 0)
+	output = appendUint64(output, certificate.serial)
+	output = enc.AppendField(output, []byte(certificate.subject))
+	output = enc.AppendField(output, certificate.public.Marshal())
+	output = appendUint64(output, uint64(certificate.not_before.Unix()))
+	output = appendUint64(output, uint64(certificate.not_after.Unix()))
+
+	if certificate.authority {
+		output = append(output, byte(1))
+	} else {
+		output = append(output, byte(0))
+	}
+
+	return enc.AppendField(output, certificate.issuer_id)
+}
+
+// the portion of the certificate covered by the signature
+func (certificate *Certificate) signed() []byte {
+	return append(enc.AppendField(nil, certificateTag), certificate.body()...)
+}
+
+// signs the certificate with the issuer's private key, the issuer's public key is used to record its key ID
+func (certificate *Certificate) Sign(private []*big.Int, group *gt.CompositeMulGroup, issuer *FFSPublicKey) error {
+	certificate.issuer_id = issuer.KeyID()
+	signature, err := signBlock(certificate.signed(), private, group)
+
+	if err != nil {
+		return err
+	}
+
+	certificate.signature = signature
+
+	return nil
+}
+
+// checks the certificate was signed by the given issuer key
+func (certificate *Certificate) CheckSignature(issuer *FFSPublicKey) bool {
+	return bytes.Equal(certificate.issuer_id, issuer.KeyID()) && verifyBlock(certificate.signed(), certificate.signature, issuer)
+}
+
+// encodes the signed certificate
+func (certificate *Certificate) Marshal() ([]byte, error) {
+	if certificate.signature == nil {
+		return nil, ErrUnsigned
+	}
+
+	return appendSignature(certificate.body(), certificate.signature), nil
+}
+
+// decodes a certificate produced by Marshal
+func ParseCertificate(data []byte) (*Certificate, error) {
+	reader := newFieldReader(data)
+	certificate := new(Certificate)
+	certificate.serial = reader.uint64()
+	certificate.subject = string(reader.field())
+	public_bytes := reader.field()
+	certificate.not_before = time.Unix(int64(reader.uint64()), 0)
+	certificate.not_after = time.Unix(int64(reader.uint64()), 0)
+
+	if reader.err == nil && len(reader.data) > 0 {
+		certificate.authority = reader.data[0] == 1
+		reader.data = reader.data[1:]
+	} else {
+		reader.err = ErrMalformed
+	}
+
+	certificate.issuer_id = reader.field()
+	certificate.signature = readSignature(reader)
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	public, err := ParseFFSPublicKey(public_bytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.public = public
+
+	return certificate, nil
+}
+
+// a signed list of certificate serials an issuer has revoked
+type RevocationList struct {
+	serials     []uint64
+	this_update time.Time
+	next_update time.Time
+	issuer_id   []byte
+	signature   *Proof
+}
+
+// constructs an unsigned revocation list valid from this_update until next_update
+func NewRevocationList(serials []uint64, this_update, next_update time.Time) *RevocationList {
+	list := new(RevocationList)
+	list.serials = serials
+	list.this_update = this_update
+	list.next_update = next_update
+
+	return list
+}
+
+func (list *RevocationList) Serials() []uint64 {
+	return list.serials
+}
+
+func (list *RevocationList) ThisUpdate() time.Time {
+	return list.this_update
+}
+
+func (list *RevocationList) NextUpdate() time.Time {
+	return list.next_update
+}
+
+func (list *RevocationList) IssuerKeyID() []byte {
+	return list.issuer_id
+}
+
+// checks whether a serial appears on the list
+func (list *RevocationList) Revoked(serial uint64) bool {
+	for i := 0; i < len(list.serials); i++ {
+		if list.serials[i] == serial {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the encoded revocation list without its signature
+func (list *RevocationList) body() []byte {
+	output := make([]byte, 0)
+	output = appendUint64(output, uint64(list.this_update.Unix()))
+	output = appendUint64(output, uint64(list.next_update.Unix()))
+	output = appendUint64(output, uint64(len(list.serials)))
+
+	for i := 0; i < len(list.serials); i++ {
+		output = appendUint64(output, list.serials[i])
+	}
+
+	return enc.AppendField(output, list.issuer_id)
+}
+
+// the portion of the revocation list covered by the signature
+func (list *RevocationList) signed() []byte {
+	return append(enc.AppendField(nil, revocationTag), list.body()...)
+}
+
+// signs the revocation list with the issuer's private key
+func (list *RevocationList) Sign(private []*big.Int, group *gt.CompositeMulGroup, issuer *FFSPublicKey) error {
+	list.issuer_id = issuer.KeyID()
+	signature, err := signBlock(list.signed(), private, group)
+
+	if err != nil {
+		return err
+	}
+
+	list.signature = signature
+
+	return nil
+}
+
+// checks the revocation list was signed by the given issuer key
+func (list *RevocationList) CheckSignature(issuer *FFSPublicKey) bool {
+	return bytes.Equal(list.issuer_id, issuer.KeyID()) && verifyBlock(list.signed(), list.signature, issuer)
+}
+
+// encodes the signed revocation list
+func (list *RevocationList) Marshal() ([]byte, error) {
+	if list.signature == nil {
+		return nil, ErrUnsigned
+	}
+
+	return appendSignature(list.body(), list.signature), nil
+}
+
+// decodes a revocation list produced by Marshal
+func ParseRevocationList(data []byte) (*RevocationList, error) {
+	reader := newFieldReader(data)
+	list := new(RevocationList)
+	list.this_update = time.Unix(int64(reader.uint64()), 0)
+	list.next_update = time.Unix(int64(reader.uint64()), 0)
+	count := reader.uint64()
+	list.serials = make([]uint64, 0)
+
+	for i := uint64(0); i < count && reader.err == nil; i++ {
+		list.serials = append(list.serials, reader.uint64())
+	}
+
+	list.issuer_id = reader.field()
+	list.signature = readSignature(reader)
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// validates a chain ordered from the leaf up, each certificate must be signed by the next
+// and the last by one of the trust anchors. revocation lists are matched to certificates by
+// issuer key ID and must be signed by that issuer and current to be accepted.
+func VerifyChain(chain []*Certificate, anchors []*FFSPublicKey, now time.Time, revocations []*RevocationList) error {
+	if len(chain) == 0 {
+		return ErrEmptyChain
+	}
+
+	for i := 0; i < len(chain); i++ {
+		certificate := chain[i]
+
+		if !certificate.ValidAt(now) {
+			return ErrExpired
+		}
+
+		var issuer *FFSPublicKey
+
+		if i+1 < len(chain) {
+			if !chain[i+1].authority {
+				return ErrNotAuthority
+			}
+
+			issuer = chain[i+1].public
+		} else {
+			for j := 0; j < len(anchors); j++ {
+				if bytes.Equal(anchors[j].KeyID(), certificate.issuer_id) {
+					issuer = anchors[j]
+					break
+				}
+			}
+
+			if issuer == nil {
+				return ErrUnknownIssuer
+			}
+		}
+
+		if !certificate.CheckSignature(issuer) {
+			return ErrBadSignature
+		}
+
+		for j := 0; j < len(revocations); j++ {
+			list := revocations[j]
+
+			if !bytes.Equal(list.issuer_id, certificate.issuer_id) {
+				continue
+			}
+
+			if !list.CheckSignature(issuer) {
+				return ErrBadSignature
+			}
+
+			if now.Before(list.this_update) || now.After(list.next_update) {
+				return ErrRevocationExpired
+			}
+
+			if list.Revoked(certificate.serial) {
+				return ErrRevoked
+			}
+		}
+	}
+
+	return nil
+}
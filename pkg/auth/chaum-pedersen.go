@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"math/big"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// proof that log_g(a) = log_h(b) without revealing the logarithm (chaum-pedersen)
+type DLEQProof struct {
+	challenge *big.Int
+	response  *big.Int
+}
+
+func (proof *DLEQProof) Challenge() *big.Int {
+	return proof.challenge
+}
+
+func (proof *DLEQProof) Response() *big.Int {
+	return proof.response
+}
+
+// encodes the proof as its challenge and response
+func (proof *DLEQProof) Marshal() []byte {
+	output := make([]byte, 0)
+	output = enc.AppendField(output, proof.challenge.Bytes())
+
+	return enc.AppendField(output, proof.response.Bytes())
+}
+
+// decodes a proof produced by Marshal
+func ParseDLEQProof(data []byte) (*DLEQProof, error) {
+	reader := newFieldReader(data)
+	proof := new(DLEQProof)
+	proof.challenge = reader.bigInt()
+	proof.response = reader.bigInt()
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// proves that g ** secret and h ** secret share the same exponent, the block is bound into the challenge
+func ProveDLEQ(group *gt.PrimeOrderGroup, challenger Challenger, g, h, secret *big.Int, block []byte) (*DLEQProof, error) {
+	nonce, err := group.RandomExponent()
+
+	if err != nil {
+		return nil, err
+	}
+
+	a := group.Exp(g, secret)
+	b := group.Exp(h, secret)
+	// commitments t1 = g ** k, t2 = h ** k
+	t1 := group.Exp(g, nonce)
+	t2 := group.Exp(h, nonce)
+
+	proof := new(DLEQProof)
+	proof.challenge = transcriptChallenge(challenger, group.Order(), t1, []*big.Int{g, a, h, b, t2}, block)
+	// s = k - c * x mod q
+	proof.response = big.NewInt(0)
+	proof.response.Mul(proof.challenge, secret)
+	proof.response.Sub(nonce, proof.response)
+	proof.response.Mod(proof.response, group.Order())
+
+	return proof, nil
+}
+
+// verifies a proof that log_g(a) = log_h(b)
+func VerifyDLEQ(group *gt.PrimeOrderGroup, challenger Challenger, g, a, h, b *big.Int, proof *DLEQProof, block []byte) bool {
+	for _, element := range []*big.Int{g, a, h, b} {
+		if !group.In(element) {
+			return false
+		}
+	}
+
+	if proof.response.Sign() < 0 || proof.response.Cmp(group.Order()) >= 0 {
+		return false
+	}
+
+	// t1 = g ** s * a ** c, t2 = h ** s * b ** c
+	t1 := group.Mul(group.Exp(g, proof.response), group.Exp(a, proof.challenge))
+	t2 := group.Mul(group.Exp(h, proof.response), group.Exp(b, proof.challenge))
+
+	challenge := transcriptChallenge(challenger, group.Order(), t1, []*big.Int{g, a, h, b, t2}, block)
+
+	return challenge.Cmp(proof.challenge) == 0
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+var ErrMalformed = errors.New("auth: malformed encoding")
+
+// appends a fixed width big endian uint64 to the buffer
+func appendUint64(buf []byte, value uint64) []byte {
+	return binary.BigEndian.AppendUint64(buf, value)
+}
+
+// reads back fields written by enc.AppendField, the first failure sticks so callers only check err once
+type fieldReader struct {
+	data []byte
+	err  error
+}
+
+func newFieldReader(data []byte) *fieldReader {
+	reader := new(fieldReader)
+	reader.data = data
+
+	return reader
+}
+
+// reads the next length prefixed field
+func (reader *fieldReader) field() []byte {
+	if reader.err != nil {
+		return nil
+	}
+
+	field, rest, err := enc.ReadField(reader.data)
+
+	if err != nil {
+		reader.err = ErrMalformed
+		return nil
+	}
+
+	reader.data = rest
+
+	return field
+}
+
+// reads the next field as a big integer
+func (reader *fieldReader) bigInt() *big.Int {
+	number := big.NewInt(0)
+	number.SetBytes(reader.field())
+
+	return number
+}
+
+// reads the next fixed width uint64
+func (reader *fieldReader) uint64() uint64 {
+	if reader.err != nil {
+		return 0
+	}
+
+	if len(reader.data) < 8 {
+		reader.err = ErrMalformed
+		return 0
+	}
+
+	value := binary.BigEndian.Uint64(reader.data)
+	reader.data = reader.data[8:]
+
+	return value
+}
+
+// fails the reader if any bytes are left over
+func (reader *fieldReader) finish() error {
+	if reader.err == nil && len(reader.data) != 0 {
+		reader.err = ErrMalformed
+	}
+
+	return reader.err
+}
@@ -1,16 +1,32 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
 	"math/big"
 
 	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
 )
 
+// the chain challenger yields a SHA-1 digest, one bit per key element
+const MaxFFSKeys = 160
+
+var ErrFFSKeys = errors.New("auth: more key elements than challenge bits")
+
+var ffsNonceDomain = []byte("zerocat ffs hedged commitment")
+
 // feige-fiat-shamir prover object
 type FFSProver struct {
 	private    []*big.Int
 	challenger Challenger
 	group      *gt.CompositeMulGroup
+	hedged     bool
+	random     io.Reader
+	destroyed  bool
 }
 
 // setup a fiege-fiat-shamir prover object
@@ -27,8 +43,76 @@ func (prover *FFSProver) Group() *gt.CompositeMulGroup {
 	return prover.group
 }
 
-// generates NIZK proof for feige-fiat-shamir
-func (prover *FFSProver) ProofGen(randomness *big.Int, block []byte) *Proof {
+// wipes the private key elements in place, the prover can not produce randomness for proofs afterwards
+func (prover *FFSProver) Destroy() {
+	enc.WipeInts(prover.private)
+	prover.destroyed = true
+}
+
+// derives commitment randomness from the private key, the challenger state and the message mixed with
+// entropy from random, so a broken or repeating random source can not cause r to be reused across messages
+func (prover *FFSProver) Hedge(random io.Reader) {
+	prover.hedged = true
+	prover.random = random
+}
+
+// produces the commitment randomness for a block, fresh from the group unless the prover is hedged
+func (prover *FFSProver) Randomness(block []byte) (*big.Int, error) {
+	if prover.destroyed {
+		return nil, enc.ErrDestroyed
+	}
+
+	if !prover.hedged {
+		return prover.group.Random()
+	}
+
+	// a failed read leaves the entropy zeroed, the derivation is then deterministic but still safe
+	entropy := make([]byte, 32)
+
+	if prover.random != nil {
+		io.ReadFull(prover.random, entropy)
+	}
+
+	key := sha256.New()
+
+	for i := 0; i < len(prover.private); i++ {
+		element := enc.AppendField(nil, prover.private[i].Bytes())
+		key.Write(element)
+		enc.Wipe(element)
+	}
+
+	key_digest := key.Sum(nil)
+	defer enc.Wipe(key_digest)
+
+	// challenging zero captures the challenger state and the block without revealing anything new
+	transcript := prover.challenger.Challenge(gt.Zero, block)
+
+	message := make([]byte, 0)
+	message = enc.AppendField(message, key_digest)
+	message = enc.AppendField(message, transcript)
+	message = enc.AppendField(message, block)
+	message = enc.AppendField(message, entropy)
+	defer enc.Wipe(message)
+
+	return prover.group.HashToElement(ffsNonceDomain, message)
+}
+
+// the i-th bit of the challenge, least significant bit of each byte first
+func challengeBit(challenge []byte, i int) byte {
+	return (challenge[i/8] >> (i % 8)) & 1
+}
+
+// generates NIZK proof for feige-fiat-shamir, fails with enc.ErrDestroyed once the prover is destroyed
+// and with ErrFFSKeys if the key has more elements than the challenge has bits
+func (prover *FFSProver) ProofGen(randomness *big.Int, block []byte) (*Proof, error) {
+	if prover.destroyed {
+		return nil, enc.ErrDestroyed
+	}
+
+	if len(prover.private) > MaxFFSKeys {
+		return nil, ErrFFSKeys
+	}
+
 	proof := new(Proof)
 
 	// statemenmt =  r**2 mod n
@@ -40,19 +124,21 @@ func (prover *FFSProver) ProofGen(randomness *big.Int, block []byte) *Proof {
 	prover.group.Mod(proof.proof)
 
 	challenge := prover.challenger.Challenge(proof.statement, block)
+
+	// other challengers may yield shorter digests than the chain challenger
+	if len(prover.private) > 8*len(challenge) {
+		return nil, ErrFFSKeys
+	}
+
 	// y = r * sc1 * sc2 * ... sck mod n
 	for i := 0; i < len(prover.private); i++ {
-		segment := i % 8
-		index := i - (segment * 8)
-		bit := (challenge[segment] >> byte(index)) & 1
-
-		if bit == 1 {
+		if challengeBit(challenge, i) == 1 {
 			proof.proof.Mul(proof.proof, prover.private[i])
 			prover.group.Mod(proof.proof)
 		}
 	}
 
-	return proof
+	return proof, nil
 }
 
 // feige-fiat-shamir verifier object
@@ -89,13 +175,15 @@ func (verifier *FFSVerifier) Verify(proof *Proof, block []byte) bool {
 	verification.Mod(verification, verifier.modulus)
 
 	challenge := verifier.challenger.Challenge(proof.statement, block)
+
+	// every key element needs its own challenge bit
+	if len(verifier.public) > 8*len(challenge) {
+		return false
+	}
+
 	// z = x * vc1 * vc2 * ... vck mod n
 	for i := 0; i < len(verifier.public); i++ {
-		segment := i % 8
-		index := i - (segment * 8)
-		bit := (challenge[segment] >> byte(index)) & 1
-
-		if bit == 1 {
+		if challengeBit(challenge, i) == 1 {
 			verification.Mul(verification, verifier.public[i])
 			verification.Mod(verification, verifier.modulus)
 		}
@@ -105,11 +193,53 @@ func (verifier *FFSVerifier) Verify(proof *Proof, block []byte) bool {
 }
 
 func FFSKeyPair(k int, group *gt.CompositeMulGroup) ([]*big.Int, []*big.Int, error) {
+	return FFSKeyPairFrom(rand.Reader, k, group)
+}
+
+// generates a feige-fiat-shamir key pair using the given source of randomness
+func FFSKeyPairFrom(random io.Reader, k int, group *gt.CompositeMulGroup) ([]*big.Int, []*big.Int, error) {
+	if k > MaxFFSKeys {
+		return nil, nil, ErrFFSKeys
+	}
+
+	private := make([]*big.Int, 0)
+	public := make([]*big.Int, 0)
+
+	for i := 0; i < k; i++ {
+		candidate, err := group.RandomFrom(random)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		private = append(private, candidate)
+
+		square := big.NewInt(0)
+		square.Exp(candidate, gt.Two, group.Modulus())
+
+		public = append(public, square)
+	}
+
+	return public, private, nil
+}
+
+var ffsSeedDomain = []byte("zerocat ffs key pair from seed")
+
+// derives a feige-fiat-shamir key pair deterministically from a seed by hashing it into the group
+func DeriveFFSKeyPair(k int, group *gt.CompositeMulGroup, seed []byte) ([]*big.Int, []*big.Int, error) {
+	if k > MaxFFSKeys {
+		return nil, nil, ErrFFSKeys
+	}
+
 	private := make([]*big.Int, 0)
 	public := make([]*big.Int, 0)
 
 	for i := 0; i < k; i++ {
-		candidate, err := group.Random()
+		message := make([]byte, 0)
+		message = binary.BigEndian.AppendUint32(message, uint32(i))
+		message = append(message, seed...)
+
+		candidate, err := group.HashToElement(ffsSeedDomain, message)
 
 		if err != nil {
 			return nil, nil, err
@@ -1,6 +1,18 @@
 package grouptheory
 
-import "math/big"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+var (
+	ErrGroupSize       = errors.New("grouptheory: invalid group size")
+	ErrGroupParameters = errors.New("grouptheory: invalid group parameters")
+)
 
 // a multiplicative group of inverses modulo a number
 type MultiplicativeGroup interface {
@@ -26,8 +38,13 @@ func NewCompGroup(ring *ModRing) *CompositeMulGroup {
 
 // setups a composite multiplicative group
 func SetupCompGroup(size int) *CompositeMulGroup {
+	return SetupCompGroupFrom(rand.Reader, size)
+}
+
+// setups a composite multiplicative group using the given source of randomness
+func SetupCompGroupFrom(random io.Reader, size int) *CompositeMulGroup {
 	group := new(CompositeMulGroup)
-	ring, p, q := CompositePrimeRing(size)
+	ring, p, q := CompositePrimeRingFrom(random, size)
 
 	group.ring = ring
 	group.totient = big.NewInt(1)
@@ -45,11 +62,21 @@ func (compositeGroup *CompositeMulGroup) Ring() *ModRing {
 	return compositeGroup.ring
 }
 
+// the order of the group, zero when the group was created without its factors
+func (compositeGroup *CompositeMulGroup) Totient() *big.Int {
+	return compositeGroup.totient
+}
+
 // samples a composite multiplicative group
 func (compositeGroup *CompositeMulGroup) Random() (*big.Int, error) {
+	return compositeGroup.RandomFrom(rand.Reader)
+}
+
+// samples a composite multiplicative group using the given source of randomness
+func (compositeGroup *CompositeMulGroup) RandomFrom(random io.Reader) (*big.Int, error) {
 	for {
 
-		if candidate, err := compositeGroup.ring.Random(); err != nil {
+		if candidate, err := compositeGroup.ring.RandomFrom(random); err != nil {
 			return nil, err
 		} else if compositeGroup.In(candidate) {
 			return candidate, nil
@@ -79,6 +106,29 @@ func (compositeGroup *CompositeMulGroup) Inverse(member *big.Int) *big.Int {
 	return inverse
 }
 
+// computes base ** (2 ** t) mod n. when the group was set up with its totient the exponent is
+// reduced first, otherwise (as for anybody holding only the modulus) t sequential squarings are needed
+func (compositeGroup *CompositeMulGroup) RepeatedSquare(base *big.Int, t uint64) *big.Int {
+	result := big.NewInt(0)
+
+	if compositeGroup.totient.Sign() > 0 {
+		exponent := big.NewInt(0)
+		exponent.SetUint64(t)
+		exponent.Exp(Two, exponent, compositeGroup.totient)
+
+		return result.Exp(base, exponent, compositeGroup.ring.modulus)
+	}
+
+	result.Mod(base, compositeGroup.ring.modulus)
+
+	for i := uint64(0); i < t; i++ {
+		result.Mul(result, result)
+		result.Mod(result, compositeGroup.ring.modulus)
+	}
+
+	return result
+}
+
 func (compositeGroup *CompositeMulGroup) Modulus() *big.Int {
 	return compositeGroup.ring.modulus
 }
@@ -86,3 +136,210 @@ func (compositeGroup *CompositeMulGroup) Modulus() *big.Int {
 func (compositeGroup *CompositeMulGroup) Mod(number *big.Int) *big.Int {
 	return compositeGroup.ring.Mod(number)
 }
+
+// a subgroup of prime order q inside the multiplicative group modulo a prime p = kq + 1
+type PrimeOrderGroup struct {
+	ring      *ModRing
+	order     *big.Int
+	generator *big.Int
+}
+
+// sets up a group with a modulus of size bits and a prime order of order_size bits
+func SetupPrimeOrderGroup(size, order_size int) (*PrimeOrderGroup, error) {
+	return SetupPrimeOrderGroupFrom(rand.Reader, size, order_size)
+}
+
+// sets up a prime order group using the given source of randomness
+func SetupPrimeOrderGroupFrom(random io.Reader, size, order_size int) (*PrimeOrderGroup, error) {
+	if order_size < 2 || size <= order_size+1 {
+		return nil, ErrGroupSize
+	}
+
+	order, err := RandomPrime(random, order_size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// search for an even cofactor k so that p = kq + 1 is a prime of exactly size bits
+	modulus := big.NewInt(0)
+	bound := big.NewInt(0)
+	bound.Lsh(One, uint(size-order_size))
+
+	for {
+		cofactor, err := rand.Int(random, bound)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cofactor.SetBit(cofactor, 0, 0)
+		modulus.Mul(cofactor, order)
+		modulus.Add(modulus, One)
+
+		if modulus.BitLen() == size && modulus.ProbablyPrime(20) {
+			break
+		}
+	}
+
+	group := new(PrimeOrderGroup)
+	group.ring = SetupModRing(modulus)
+	group.order = order
+
+	// any element raised to the cofactor lands in the subgroup, only the identity is unsuitable
+	cofactor := big.NewInt(0)
+	cofactor.Sub(modulus, One)
+	cofactor.Div(cofactor, order)
+
+	for base := big.NewInt(2); ; base.Add(base, One) {
+		generator := big.NewInt(0)
+		generator.Exp(base, cofactor, modulus)
+
+		if generator.Cmp(One) != 0 {
+			group.generator = generator
+			break
+		}
+	}
+
+	return group, nil
+}
+
+// for the purposes of deserialization, creates a group from its parameters after checking them
+func NewPrimeOrderGroup(modulus, order, generator *big.Int) (*PrimeOrderGroup, error) {
+	remainder := big.NewInt(0)
+	remainder.Sub(modulus, One)
+	remainder.Mod(remainder, order)
+
+	if !modulus.ProbablyPrime(20) || !order.ProbablyPrime(20) || remainder.Sign() != 0 {
+		return nil, ErrGroupParameters
+	}
+
+	group := new(PrimeOrderGroup)
+	group.ring = SetupModRing(modulus)
+	group.order = order
+
+	if !group.In(generator) || generator.Cmp(One) == 0 {
+		return nil, ErrGroupParameters
+	}
+
+	group.generator = generator
+
+	return group, nil
+}
+
+func (primeGroup *PrimeOrderGroup) Ring() *ModRing {
+	return primeGroup.ring
+}
+
+func (primeGroup *PrimeOrderGroup) Modulus() *big.Int {
+	return primeGroup.ring.modulus
+}
+
+func (primeGroup *PrimeOrderGroup) Order() *big.Int {
+	return primeGroup.order
+}
+
+func (primeGroup *PrimeOrderGroup) Generator() *big.Int {
+	return primeGroup.generator
+}
+
+// checks membership of the prime order subgroup
+func (primeGroup *PrimeOrderGroup) In(number *big.Int) bool {
+	if !primeGroup.ring.In(number) {
+		return false
+	}
+
+	check := big.NewInt(0)
+	check.Exp(number, primeGroup.order, primeGroup.ring.modulus)
+
+	return check.Cmp(One) == 0
+}
+
+// samples an exponent uniformly from 1 to q - 1
+func (primeGroup *PrimeOrderGroup) RandomExponent() (*big.Int, error) {
+	return primeGroup.RandomExponentFrom(rand.Reader)
+}
+
+// samples an exponent using the given source of randomness
+func (primeGroup *PrimeOrderGroup) RandomExponentFrom(random io.Reader) (*big.Int, error) {
+	bound := big.NewInt(0)
+	bound.Sub(primeGroup.order, One)
+	exponent, err := rand.Int(random, bound)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exponent.Add(exponent, One), nil
+}
+
+// samples a random element of the subgroup
+func (primeGroup *PrimeOrderGroup) Random() (*big.Int, error) {
+	exponent, err := primeGroup.RandomExponent()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return primeGroup.Exp(primeGroup.generator, exponent), nil
+}
+
+// computes base ** exponent mod p
+func (primeGroup *PrimeOrderGroup) Exp(base, exponent *big.Int) *big.Int {
+	result := big.NewInt(0)
+	return result.Exp(base, exponent, primeGroup.ring.modulus)
+}
+
+// computes a * b mod p
+func (primeGroup *PrimeOrderGroup) Mul(a, b *big.Int) *big.Int {
+	result := big.NewInt(0)
+	result.Mul(a, b)
+
+	return primeGroup.ring.Mod(result)
+}
+
+// computes the multiplicative inverse modulo p
+func (primeGroup *PrimeOrderGroup) Inverse(member *big.Int) *big.Int {
+	if !primeGroup.In(member) {
+		return nil
+	}
+
+	inverse := big.NewInt(0)
+	return inverse.ModInverse(member, primeGroup.ring.modulus)
+}
+
+// attempts before a group is judged unable to yield a generator, each one fails with probability 1/q
+const maxGeneratorAttempts = 128
+
+// derives a generator whose discrete log relative to any other generator is unknown,
+// by hashing the label into the field and raising it to the cofactor
+func (primeGroup *PrimeOrderGroup) DeriveGenerator(label []byte) (*big.Int, error) {
+	cofactor := big.NewInt(0)
+	cofactor.Sub(primeGroup.ring.modulus, One)
+	cofactor.Div(cofactor, primeGroup.order)
+
+	length := (primeGroup.ring.size + 128 + 7) / 8
+
+	for counter := uint32(0); counter < maxGeneratorAttempts; counter++ {
+		stream := make([]byte, 0, length+sha256.Size)
+
+		for block := uint32(0); len(stream) < length; block++ {
+			hash := sha256.New()
+			hash.Write(label)
+			hash.Write(binary.BigEndian.AppendUint32(nil, counter))
+			hash.Write(binary.BigEndian.AppendUint32(nil, block))
+			stream = hash.Sum(stream)
+		}
+
+		candidate := big.NewInt(0)
+		candidate.SetBytes(stream[:length])
+		primeGroup.ring.Mod(candidate)
+		candidate.Exp(candidate, cofactor, primeGroup.ring.modulus)
+
+		if candidate.Cmp(One) > 0 {
+			return candidate, nil
+		}
+	}
+
+	return nil, ErrGroupParameters
+}
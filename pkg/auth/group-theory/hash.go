@@ -0,0 +1,122 @@
+package grouptheory
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrExpandLength = errors.New("grouptheory: requested expansion or domain is too long")
+	ErrHashToGroup  = errors.New("grouptheory: hashed value is not a member of the group")
+)
+
+// maps data to a prime of exactly size bits by hashing it with the domain and a counter until the result is prime
+func HashToPrime(domain, data []byte, size int) *big.Int {
+	length := (size + 7) / 8
+	candidate := big.NewInt(0)
+
+	for counter := uint32(0); ; counter++ {
+		stream := make([]byte, 0, length+sha256.Size)
+
+		for block := uint32(0); len(stream) < length; block++ {
+			hash := sha256.New()
+			hash.Write(binary.BigEndian.AppendUint32(nil, uint32(len(domain))))
+			hash.Write(domain)
+			hash.Write(binary.BigEndian.AppendUint32(nil, counter))
+			hash.Write(binary.BigEndian.AppendUint32(nil, block))
+			hash.Write(data)
+			stream = hash.Sum(stream)
+		}
+
+		candidate.SetBytes(stream[:length])
+		// trim to size bits then force the top bit so the size is exact and the low bit so it is odd
+		candidate.Rsh(candidate, uint(length*8-size))
+		candidate.SetBit(candidate, size-1, 1)
+		candidate.SetBit(candidate, 0, 1)
+
+		if candidate.ProbablyPrime(20) {
+			return candidate
+		}
+	}
+}
+
+// expands a message into length uniform bytes under a domain separation tag (RFC 9380 expand_message_xmd with SHA256)
+func ExpandMessage(message, domain []byte, length int) ([]byte, error) {
+	blocks := (length + sha256.Size - 1) / sha256.Size
+
+	if blocks > 255 || length > 65535 || len(domain) > 255 {
+		return nil, ErrExpandLength
+	}
+
+	domain_prime := append(append([]byte(nil), domain...), byte(len(domain)))
+
+	// b_0 = H(Z_pad || msg || I2OSP(len, 2) || I2OSP(0, 1) || DST_prime)
+	hash := sha256.New()
+	hash.Write(make([]byte, hash.BlockSize()))
+	hash.Write(message)
+	hash.Write([]byte{byte(length >> 8), byte(length), 0})
+	hash.Write(domain_prime)
+	first := hash.Sum(nil)
+
+	// b_i = H(strxor(b_0, b_(i-1)) || I2OSP(i, 1) || DST_prime)
+	output := make([]byte, 0, blocks*sha256.Size)
+	previous := make([]byte, sha256.Size)
+
+	for i := 1; i <= blocks; i++ {
+		for j := 0; j < len(previous); j++ {
+			previous[j] ^= first[j]
+		}
+
+		hash.Reset()
+		hash.Write(previous)
+		hash.Write([]byte{byte(i)})
+		hash.Write(domain_prime)
+		previous = hash.Sum(nil)
+		output = append(output, previous...)
+	}
+
+	return output[:length], nil
+}
+
+// reduces expanded bytes into the ring, with 128 extra bits the result is statistically close to uniform
+func (ring *ModRing) hashToRing(domain, message []byte) (*big.Int, error) {
+	uniform, err := ExpandMessage(message, domain, (ring.size+128+7)/8)
+
+	if err != nil {
+		return nil, err
+	}
+
+	element := big.NewInt(0)
+	element.SetBytes(uniform)
+
+	return ring.Mod(element), nil
+}
+
+// maps a message to an element of the group in a single pass. the only failure is landing on a
+// value sharing a factor with the modulus, which is as unlikely as factoring it by chance
+func (compositeGroup *CompositeMulGroup) HashToElement(domain, message []byte) (*big.Int, error) {
+	element, err := compositeGroup.ring.hashToRing(domain, message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !compositeGroup.In(element) {
+		return nil, ErrHashToGroup
+	}
+
+	return element, nil
+}
+
+// maps a message to a quadratic residue by squaring a hashed element
+func (compositeGroup *CompositeMulGroup) HashToQR(domain, message []byte) (*big.Int, error) {
+	element, err := compositeGroup.HashToElement(domain, message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return element.Exp(element, Two, compositeGroup.ring.modulus), nil
+}
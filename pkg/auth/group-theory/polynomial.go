@@ -0,0 +1,165 @@
+package grouptheory
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+)
+
+var ErrInterpolation = errors.New("grouptheory: points must be distinct and non-empty")
+
+// a polynomial with coefficients in a ring, lowest degree first
+type Polynomial struct {
+	ring         *ModRing
+	coefficients []*big.Int
+}
+
+// constructs a polynomial from its coefficients, lowest degree first
+func NewPolynomial(ring *ModRing, coefficients []*big.Int) *Polynomial {
+	polynomial := new(Polynomial)
+	polynomial.ring = ring
+	polynomial.coefficients = make([]*big.Int, len(coefficients))
+
+	for i := 0; i < len(coefficients); i++ {
+		polynomial.coefficients[i] = big.NewInt(0)
+		polynomial.coefficients[i].Mod(coefficients[i], ring.modulus)
+	}
+
+	return polynomial
+}
+
+// samples a polynomial of the given degree with a fixed constant term
+func RandomPolynomial(ring *ModRing, degree int, constant *big.Int) (*Polynomial, error) {
+	return RandomPolynomialFrom(rand.Reader, ring, degree, constant)
+}
+
+// samples a polynomial of the given degree with a fixed constant term using the given source of randomness
+func RandomPolynomialFrom(random io.Reader, ring *ModRing, degree int, constant *big.Int) (*Polynomial, error) {
+	coefficients := []*big.Int{constant}
+
+	for i := 0; i < degree; i++ {
+		coefficient, err := ring.RandomFrom(random)
+
+		if err != nil {
+			return nil, err
+		}
+
+		coefficients = append(coefficients, coefficient)
+	}
+
+	return NewPolynomial(ring, coefficients), nil
+}
+
+func (polynomial *Polynomial) Coefficients() []*big.Int {
+	return polynomial.coefficients
+}
+
+func (polynomial *Polynomial) Degree() int {
+	return len(polynomial.coefficients) - 1
+}
+
+// evaluates the polynomial at x using horner's method
+func (polynomial *Polynomial) Evaluate(x *big.Int) *big.Int {
+	result := big.NewInt(0)
+
+	for i := len(polynomial.coefficients) - 1; i >= 0; i-- {
+		result.Mul(result, x)
+		result.Add(result, polynomial.coefficients[i])
+		polynomial.ring.Mod(result)
+	}
+
+	return result
+}
+
+// adds two polynomials over the same ring
+func (polynomial *Polynomial) Add(other *Polynomial) *Polynomial {
+	length := len(polynomial.coefficients)
+
+	if len(other.coefficients) > length {
+		length = len(other.coefficients)
+	}
+
+	coefficients := make([]*big.Int, length)
+
+	for i := 0; i < length; i++ {
+		coefficients[i] = big.NewInt(0)
+
+		if i < len(polynomial.coefficients) {
+			coefficients[i].Add(coefficients[i], polynomial.coefficients[i])
+		}
+
+		if i < len(other.coefficients) {
+			coefficients[i].Add(coefficients[i], other.coefficients[i])
+		}
+	}
+
+	return NewPolynomial(polynomial.ring, coefficients)
+}
+
+// multiplies two polynomials over the same ring
+func (polynomial *Polynomial) Mul(other *Polynomial) *Polynomial {
+	if len(polynomial.coefficients) == 0 || len(other.coefficients) == 0 {
+		return NewPolynomial(polynomial.ring, nil)
+	}
+
+	coefficients := make([]*big.Int, len(polynomial.coefficients)+len(other.coefficients)-1)
+
+	for i := 0; i < len(coefficients); i++ {
+		coefficients[i] = big.NewInt(0)
+	}
+
+	term := big.NewInt(0)
+
+	for i := 0; i < len(polynomial.coefficients); i++ {
+		for j := 0; j < len(other.coefficients); j++ {
+			term.Mul(polynomial.coefficients[i], other.coefficients[j])
+			coefficients[i+j].Add(coefficients[i+j], term)
+		}
+	}
+
+	return NewPolynomial(polynomial.ring, coefficients)
+}
+
+// evaluates the unique polynomial through the points (xs[i], ys[i]) at a, the ring modulus must be prime
+func Interpolate(ring *ModRing, xs, ys []*big.Int, at *big.Int) (*big.Int, error) {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return nil, ErrInterpolation
+	}
+
+	result := big.NewInt(0)
+	numerator := big.NewInt(0)
+	denominator := big.NewInt(0)
+	term := big.NewInt(0)
+
+	for i := 0; i < len(xs); i++ {
+		// l_i(a) = prod (a - x_j) / (x_i - x_j) for j != i
+		numerator.SetInt64(1)
+		denominator.SetInt64(1)
+
+		for j := 0; j < len(xs); j++ {
+			if i == j {
+				continue
+			}
+
+			term.Sub(at, xs[j])
+			numerator.Mul(numerator, term)
+			ring.Mod(numerator)
+
+			term.Sub(xs[i], xs[j])
+			denominator.Mul(denominator, term)
+			ring.Mod(denominator)
+		}
+
+		if denominator.ModInverse(denominator, ring.modulus) == nil {
+			return nil, ErrInterpolation
+		}
+
+		term.Mul(numerator, denominator)
+		term.Mul(term, ys[i])
+		result.Add(result, term)
+		ring.Mod(result)
+	}
+
+	return result, nil
+}
@@ -2,6 +2,7 @@ package grouptheory
 
 import (
 	"crypto/rand"
+	"io"
 	"math/big"
 )
 
@@ -33,7 +34,12 @@ func SetupModRing(modulus *big.Int) *ModRing {
 
 // sets up a ring of prime modulus p (all elements therefore co-prime to p)
 func PrimeRing(size int) *ModRing {
-	if p, err := rand.Prime(rand.Reader, size); err != nil {
+	return PrimeRingFrom(rand.Reader, size)
+}
+
+// sets up a ring of prime modulus p using the given source of randomness
+func PrimeRingFrom(random io.Reader, size int) *ModRing {
+	if p, err := RandomPrime(random, size); err != nil {
 		return nil
 	} else {
 		return SetupModRing(p)
@@ -42,13 +48,18 @@ func PrimeRing(size int) *ModRing {
 
 // sets up a ring of composite prime modulus (not a multiplicative group yet since no gaurantee of co-prime)
 func CompositePrimeRing(size int) (*ModRing, *big.Int, *big.Int) {
-	p, err := rand.Prime(rand.Reader, size/2)
+	return CompositePrimeRingFrom(rand.Reader, size)
+}
+
+// sets up a ring of composite prime modulus using the given source of randomness
+func CompositePrimeRingFrom(random io.Reader, size int) (*ModRing, *big.Int, *big.Int) {
+	p, err := RandomPrime(random, size/2)
 
 	if err != nil {
 		return nil, nil, nil
 	}
 
-	q, err := rand.Prime(rand.Reader, size/2)
+	q, err := RandomPrime(random, size/2)
 
 	if err != nil {
 		return nil, nil, nil
@@ -61,6 +72,43 @@ func CompositePrimeRing(size int) (*ModRing, *big.Int, *big.Int) {
 	return SetupModRing(n), p, q
 }
 
+// generates a prime of exactly size bits from the reader, the top two bits are set so that the
+// product of two such primes has exactly twice the size. unlike crypto/rand.Prime the reader is
+// always honoured, so a deterministic reader gives a deterministic prime
+func RandomPrime(random io.Reader, size int) (*big.Int, error) {
+	if size < 2 {
+		return nil, ErrGroupSize
+	}
+
+	buf := make([]byte, (size+7)/8)
+	excess := uint(len(buf)*8 - size)
+	candidate := big.NewInt(0)
+
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, err
+		}
+
+		buf[0] &= byte(0xff >> excess)
+		candidate.SetBytes(buf)
+		candidate.SetBit(candidate, size-1, 1)
+
+		if size > 2 {
+			candidate.SetBit(candidate, size-2, 1)
+		}
+
+		candidate.SetBit(candidate, 0, 1)
+
+		if candidate.ProbablyPrime(20) {
+			return candidate, nil
+		}
+	}
+}
+
+func (ring *ModRing) Modulus() *big.Int {
+	return ring.modulus
+}
+
 func (ring *ModRing) Size() int {
 	return ring.size
 }
@@ -72,10 +120,15 @@ func (ring *ModRing) In(number *big.Int) bool {
 
 // samples an element from the ring
 func (ring *ModRing) Random() (*big.Int, error) {
+	return ring.RandomFrom(rand.Reader)
+}
+
+// samples an element from the ring using the given source of randomness
+func (ring *ModRing) RandomFrom(random io.Reader) (*big.Int, error) {
 
 	for {
 
-		if candidate, err := rand.Int(rand.Reader, ring.modulus); err != nil {
+		if candidate, err := rand.Int(random, ring.modulus); err != nil {
 			return nil, err
 		} else if ring.In(candidate) {
 			return candidate, nil
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"math/big"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// label used to derive the second pedersen generator so that nobody knows log_g(h)
+var pedersenLabel = []byte("zerocat pedersen generator h")
+
+// pedersen commitment scheme C = g ** m * h ** r over a prime order group
+type Pedersen struct {
+	group *gt.PrimeOrderGroup
+	h     *big.Int
+}
+
+// setup a pedersen commitment scheme, the second generator is derived from the group parameters
+func SetupPedersen(group *gt.PrimeOrderGroup) (*Pedersen, error) {
+	h, err := group.DeriveGenerator(pedersenLabel)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pedersen := new(Pedersen)
+	pedersen.group = group
+	pedersen.h = h
+
+	return pedersen, nil
+}
+
+func (pedersen *Pedersen) Group() *gt.PrimeOrderGroup {
+	return pedersen.group
+}
+
+func (pedersen *Pedersen) H() *big.Int {
+	return pedersen.h
+}
+
+// a commitment to a value which hides it until opened
+type PedersenCommitment struct {
+	value *big.Int
+}
+
+func NewPedersenCommitment(value *big.Int) *PedersenCommitment {
+	commitment := new(PedersenCommitment)
+	commitment.value = value
+
+	return commitment
+}
+
+func (commitment *PedersenCommitment) Value() *big.Int {
+	return commitment.value
+}
+
+func (commitment *PedersenCommitment) Marshal() []byte {
+	return enc.AppendField(nil, commitment.value.Bytes())
+}
+
+func ParsePedersenCommitment(data []byte) (*PedersenCommitment, error) {
+	reader := newFieldReader(data)
+	commitment := NewPedersenCommitment(reader.bigInt())
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	return commitment, nil
+}
+
+// the message and blinding factor needed to open a commitment
+type PedersenOpening struct {
+	message  *big.Int
+	blinding *big.Int
+}
+
+func NewPedersenOpening(message, blinding *big.Int) *PedersenOpening {
+	opening := new(PedersenOpening)
+	opening.message = message
+	opening.blinding = blinding
+
+	return opening
+}
+
+func (opening *PedersenOpening) Message() *big.Int {
+	return opening.message
+}
+
+func (opening *PedersenOpening) Blinding() *big.Int {
+	return opening.blinding
+}
+
+func (opening *PedersenOpening) Marshal() []byte {
+	output := enc.AppendField(nil, opening.message.Bytes())
+	return enc.AppendField(output, opening.blinding.Bytes())
+}
+
+func ParsePedersenOpening(data []byte) (*PedersenOpening, error) {
+	reader := newFieldReader(data)
+	opening := NewPedersenOpening(reader.bigInt(), reader.bigInt())
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	return opening, nil
+}
+
+// commits to a message using a fresh blinding factor
+func (pedersen *Pedersen) Commit(message *big.Int) (*PedersenCommitment, *PedersenOpening, error) {
+	blinding, err := pedersen.group.RandomExponent()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reduced := big.NewInt(0)
+	reduced.Mod(message, pedersen.group.Order())
+	opening := NewPedersenOpening(reduced, blinding)
+
+	return pedersen.commit(opening), opening, nil
+}
+
+// C = g ** m * h ** r mod p
+func (pedersen *Pedersen) commit(opening *PedersenOpening) *PedersenCommitment {
+	value := pedersen.group.Mul(pedersen.group.Exp(pedersen.group.Generator(), opening.message), pedersen.group.Exp(pedersen.h, opening.blinding))
+	return NewPedersenCommitment(value)
+}
+
+// checks that the opening matches the commitment
+func (pedersen *Pedersen) Open(commitment *PedersenCommitment, opening *PedersenOpening) bool {
+	order := pedersen.group.Order()
+
+	if opening.message.Sign() < 0 || opening.message.Cmp(order) >= 0 || opening.blinding.Sign() < 0 || opening.blinding.Cmp(order) >= 0 {
+		return false
+	}
+
+	return pedersen.commit(opening).value.Cmp(commitment.value) == 0
+}
+
+// combines two commitments into a commitment to the sum of their messages
+func (pedersen *Pedersen) Add(a, b *PedersenCommitment) *PedersenCommitment {
+	return NewPedersenCommitment(pedersen.group.Mul(a.value, b.value))
+}
+
+// combines two openings to open the commitment produced by Add
+func (pedersen *Pedersen) AddOpenings(a, b *PedersenOpening) *PedersenOpening {
+	message := big.NewInt(0)
+	message.Add(a.message, b.message)
+	message.Mod(message, pedersen.group.Order())
+
+	blinding := big.NewInt(0)
+	blinding.Add(a.blinding, b.blinding)
+	blinding.Mod(blinding, pedersen.group.Order())
+
+	return NewPedersenOpening(message, blinding)
+}
+
+// proof of knowledge of an opening for a commitment which reveals nothing about it
+type PedersenProof struct {
+	challenge *big.Int
+	message   *big.Int
+	blinding  *big.Int
+}
+
+func (proof *PedersenProof) Marshal() []byte {
+	output := enc.AppendField(nil, proof.challenge.Bytes())
+	output = enc.AppendField(output, proof.message.Bytes())
+
+	return enc.AppendField(output, proof.blinding.Bytes())
+}
+
+func ParsePedersenProof(data []byte) (*PedersenProof, error) {
+	reader := newFieldReader(data)
+	proof := new(PedersenProof)
+	proof.challenge = reader.bigInt()
+	proof.message = reader.bigInt()
+	proof.blinding = reader.bigInt()
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// proves knowledge of the opening of a commitment, the block is bound into the challenge
+func (pedersen *Pedersen) ProveOpening(commitment *PedersenCommitment, opening *PedersenOpening, challenger Challenger, block []byte) (*PedersenProof, error) {
+	message_nonce, err := pedersen.group.RandomExponent()
+
+	if err != nil {
+		return nil, err
+	}
+
+	blinding_nonce, err := pedersen.group.RandomExponent()
+
+	if err != nil {
+		return nil, err
+	}
+
+	order := pedersen.group.Order()
+	// t = g ** a * h ** b
+	t := pedersen.commit(NewPedersenOpening(message_nonce, blinding_nonce)).value
+
+	proof := new(PedersenProof)
+	proof.challenge = transcriptChallenge(challenger, order, t, []*big.Int{pedersen.group.Generator(), pedersen.h, commitment.value}, block)
+	// z1 = a + c * m, z2 = b + c * r mod q
+	proof.message = big.NewInt(0)
+	proof.message.Mul(proof.challenge, opening.message)
+	proof.message.Add(proof.message, message_nonce)
+	proof.message.Mod(proof.message, order)
+	proof.blinding = big.NewInt(0)
+	proof.blinding.Mul(proof.challenge, opening.blinding)
+	proof.blinding.Add(proof.blinding, blinding_nonce)
+	proof.blinding.Mod(proof.blinding, order)
+
+	return proof, nil
+}
+
+// verifies a proof of knowledge of an opening
+func (pedersen *Pedersen) VerifyOpening(commitment *PedersenCommitment, proof *PedersenProof, challenger Challenger, block []byte) bool {
+	if !pedersen.group.In(commitment.value) {
+		return false
+	}
+
+	order := pedersen.group.Order()
+
+	if proof.message.Cmp(order) >= 0 || proof.blinding.Cmp(order) >= 0 {
+		return false
+	}
+
+	// t = g ** z1 * h ** z2 * C ** -c
+	negated := big.NewInt(0)
+	negated.Sub(order, proof.challenge)
+	negated.Mod(negated, order)
+	t := pedersen.commit(NewPedersenOpening(proof.message, proof.blinding)).value
+	t = pedersen.group.Mul(t, pedersen.group.Exp(commitment.value, negated))
+
+	challenge := transcriptChallenge(challenger, order, t, []*big.Int{pedersen.group.Generator(), pedersen.h, commitment.value}, block)
+
+	return challenge.Cmp(proof.challenge) == 0
+}
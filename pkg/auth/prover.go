@@ -2,6 +2,8 @@ package auth
 
 import (
 	"math/big"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
 )
 
 // the proof holds the statement and the proof which the verifier will check
@@ -26,8 +28,9 @@ func NewProof(statement, proof *big.Int) *Proof {
 	return proof_obj
 }
 
+// a prover fails rather than produce a proof it can not stand behind, such as from a destroyed key
 type Prover interface {
-	ProofGen(*big.Int, []byte) *Proof
+	ProofGen(*big.Int, []byte) (*Proof, error)
 }
 
 // the challenger produces a deterministic challenge given a statement
@@ -35,3 +38,20 @@ type Challenger interface {
 	Update([]byte) // commits latest verified block
 	Challenge(*big.Int, []byte) []byte
 }
+
+// applies the challenger to a sigma protocol transcript, the first commitment is passed as the statement
+// and the remaining values are bound ahead of the block. the challenge is reduced modulo the group order
+func transcriptChallenge(challenger Challenger, order *big.Int, commitment *big.Int, transcript []*big.Int, block []byte) *big.Int {
+	encoded := make([]byte, 0)
+
+	for i := 0; i < len(transcript); i++ {
+		encoded = enc.AppendField(encoded, transcript[i].Bytes())
+	}
+
+	encoded = enc.AppendField(encoded, block)
+
+	challenge := big.NewInt(0)
+	challenge.SetBytes(challenger.Challenge(commitment, encoded))
+
+	return challenge.Mod(challenge, order)
+}
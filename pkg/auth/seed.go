@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// size of a key seed in bytes, 256 bits
+const SeedSize = 32
+
+// iterations used when stretching a passphrase into a seed
+const PassphraseIterations = 600000
+
+var (
+	ErrSeedSize = errors.New("auth: seed must be 32 bytes")
+	ErrChecksum = errors.New("auth: seed checksum mismatch")
+	ErrSeedWord = errors.New("auth: invalid seed word")
+)
+
+var (
+	seedNonce      = []byte("zerocat seed drbg")
+	passphraseSalt = []byte("zerocat ffs passphrase seed")
+	seedEncoding   = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// proquint alphabets, every word is consonant vowel consonant vowel consonant and holds 16 bits
+const (
+	seedConsonants = "bdfghjklmnprstvz"
+	seedVowels     = "aiou"
+)
+
+// draws a fresh seed from crypto/rand
+func NewSeed() ([]byte, error) {
+	seed := make([]byte, SeedSize)
+
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+
+	return seed, nil
+}
+
+// stretches a passphrase into a seed, the salt is fixed so the same passphrase always gives the same seed
+func SeedFromPassphrase(passphrase string) []byte {
+	return enc.PBKDF2([]byte(passphrase), passphraseSalt, PassphraseIterations, SeedSize)
+}
+
+// a deterministic random stream for one purpose, different labels give independent streams from the same seed
+func SeedRandom(seed []byte, label []byte) (io.Reader, error) {
+	if len(seed) != SeedSize {
+		return nil, ErrSeedSize
+	}
+
+	return enc.NewHMACDRBG(seed, seedNonce, label)
+}
+
+// derives the group and a feige-fiat-shamir key pair from a seed, the prime search and the key elements
+// are both driven by a DRBG so the same seed, k and size always rebuild the same key
+func FFSKeyPairFromSeed(seed []byte, k, size int) (*gt.CompositeMulGroup, []*big.Int, []*big.Int, error) {
+	label := []byte("ffs key pair")
+	label = binary.BigEndian.AppendUint32(label, uint32(k))
+	label = binary.BigEndian.AppendUint32(label, uint32(size))
+
+	random, err := SeedRandom(seed, label)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	group := gt.SetupCompGroupFrom(random, size)
+
+	public, private, err := FFSKeyPairFrom(random, k, group)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return group, public, private, nil
+}
+
+// the seed followed by the first two bytes of its hash
+func seedWithChecksum(seed []byte) []byte {
+	digest := sha256.Sum256(seed)
+	output := make([]byte, 0, len(seed)+2)
+	output = append(output, seed...)
+
+	return append(output, digest[:2]...)
+}
+
+func checkSeed(data []byte) ([]byte, error) {
+	if len(data) != SeedSize+2 {
+		return nil, ErrSeedSize
+	}
+
+	seed := data[:SeedSize]
+	digest := sha256.Sum256(seed)
+
+	if data[SeedSize] != digest[0] || data[SeedSize+1] != digest[1] {
+		return nil, ErrChecksum
+	}
+
+	return seed, nil
+}
+
+// exports a seed as 17 dash separated proquint words, the last word is a checksum
+func SeedToWords(seed []byte) (string, error) {
+	if len(seed) != SeedSize {
+		return "", ErrSeedSize
+	}
+
+	data := seedWithChecksum(seed)
+	words := make([]string, 0, len(data)/2)
+
+	for i := 0; i < len(data); i += 2 {
+		value := binary.BigEndian.Uint16(data[i:])
+		word := []byte{
+			seedConsonants[value>>12&0xf],
+			seedVowels[value>>10&0x3],
+			seedConsonants[value>>6&0xf],
+			seedVowels[value>>4&0x3],
+			seedConsonants[value&0xf],
+		}
+		words = append(words, string(word))
+	}
+
+	return strings.Join(words, "-"), nil
+}
+
+// imports a seed from its words, separated by dashes or whitespace
+func SeedFromWords(words string) ([]byte, error) {
+	fields := strings.FieldsFunc(strings.ToLower(words), func(r rune) bool {
+		return r == '-' || r == ' ' || r == '\t' || r == '\n'
+	})
+	data := make([]byte, 0, len(fields)*2)
+
+	for _, field := range fields {
+		if len(field) != 5 {
+			return nil, ErrSeedWord
+		}
+
+		value := uint16(0)
+
+		for i := 0; i < len(field); i++ {
+			alphabet, bits := seedConsonants, 4
+
+			if i%2 == 1 {
+				alphabet, bits = seedVowels, 2
+			}
+
+			index := strings.IndexByte(alphabet, field[i])
+
+			if index < 0 {
+				return nil, ErrSeedWord
+			}
+
+			value = value<<bits | uint16(index)
+		}
+
+		data = binary.BigEndian.AppendUint16(data, value)
+	}
+
+	return checkSeed(data)
+}
+
+// exports a seed as an unpadded base32 string carrying the same checksum as the words
+func SeedToString(seed []byte) (string, error) {
+	if len(seed) != SeedSize {
+		return "", ErrSeedSize
+	}
+
+	return seedEncoding.EncodeToString(seedWithChecksum(seed)), nil
+}
+
+// imports a seed from its base32 string
+func SeedFromString(encoded string) ([]byte, error) {
+	data, err := seedEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(encoded)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return checkSeed(data)
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// parameters for WOTS+ with SHA256 and winternitz parameter 16 (RFC 8391, WOTSP-SHA2_256)
+const (
+	wotsN    = 32
+	wotsW    = 16
+	wotsLogW = 4
+	wotsLen1 = 8 * wotsN / wotsLogW
+	wotsLen2 = 3
+	wotsLen  = wotsLen1 + wotsLen2
+)
+
+// address types used to separate every hash call in the scheme
+const (
+	addressOTS   uint32 = 0
+	addressLTree uint32 = 1
+	addressTree  uint32 = 2
+)
+
+// padding prefixes selecting the hash function (RFC 8391 section 5.1)
+const (
+	hashF   = 0
+	hashH   = 1
+	hashMsg = 2
+	hashPRF = 3
+)
+
+// the 32 byte hash address, eight big endian words
+type hashAddress [8]uint32
+
+func (address *hashAddress) setType(kind uint32) {
+	address[3] = kind
+	address[4] = 0
+	address[5] = 0
+	address[6] = 0
+	address[7] = 0
+}
+
+// ots address, l-tree address and (unused) padding share the same word
+func (address *hashAddress) setLeaf(leaf uint32) {
+	address[4] = leaf
+}
+
+// chain address or tree height
+func (address *hashAddress) setChain(chain uint32) {
+	address[5] = chain
+}
+
+// hash address or tree index
+func (address *hashAddress) setHash(hash uint32) {
+	address[6] = hash
+}
+
+func (address *hashAddress) setKeyAndMask(mask uint32) {
+	address[7] = mask
+}
+
+func (address *hashAddress) bytes() []byte {
+	output := make([]byte, 32)
+
+	for i := 0; i < 8; i++ {
+		binary.BigEndian.PutUint32(output[i*4:], address[i])
+	}
+
+	return output
+}
+
+// SHA256(toByte(prefix, 32) || key || message)
+func keyedHash(prefix byte, key []byte, message ...[]byte) []byte {
+	padding := make([]byte, wotsN)
+	padding[wotsN-1] = prefix
+
+	hash := sha256.New()
+	hash.Write(padding)
+	hash.Write(key)
+
+	for i := 0; i < len(message); i++ {
+		hash.Write(message[i])
+	}
+
+	return hash.Sum(nil)
+}
+
+func prf(key []byte, address *hashAddress) []byte {
+	return keyedHash(hashPRF, key, address.bytes())
+}
+
+func xorBytes(a, b []byte) []byte {
+	output := make([]byte, len(a))
+
+	for i := 0; i < len(a); i++ {
+		output[i] = a[i] ^ b[i]
+	}
+
+	return output
+}
+
+// iterates the chaining function steps times starting at position start
+func wotsChain(input []byte, start, steps int, seed []byte, address *hashAddress) []byte {
+	output := make([]byte, wotsN)
+	copy(output, input)
+
+	for i := start; i < start+steps && i < wotsW; i++ {
+		address.setHash(uint32(i))
+		address.setKeyAndMask(0)
+		key := prf(seed, address)
+		address.setKeyAndMask(1)
+		mask := prf(seed, address)
+		output = keyedHash(hashF, key, xorBytes(output, mask))
+	}
+
+	return output
+}
+
+// splits a digest into base w digits followed by the base w checksum
+func wotsDigits(digest []byte) []int {
+	digits := make([]int, 0, wotsLen)
+
+	for i := 0; i < len(digest); i++ {
+		digits = append(digits, int(digest[i]>>4), int(digest[i]&15))
+	}
+
+	checksum := 0
+
+	for i := 0; i < wotsLen1; i++ {
+		checksum += wotsW - 1 - digits[i]
+	}
+
+	// left align the 12 bit checksum in two bytes
+	checksum <<= 4
+	digits = append(digits, (checksum>>12)&15, (checksum>>8)&15, (checksum>>4)&15)
+
+	return digits
+}
+
+// derives the secret chain starts for the one-time key at the address
+func wotsSecret(secret_seed []byte, address *hashAddress) [][]byte {
+	secret := make([][]byte, wotsLen)
+
+	for i := 0; i < wotsLen; i++ {
+		address.setChain(uint32(i))
+		address.setHash(0)
+		address.setKeyAndMask(0)
+		secret[i] = prf(secret_seed, address)
+	}
+
+	return secret
+}
+
+// computes the chain ends that make up the one-time public key
+func wotsPublic(secret_seed, public_seed []byte, address *hashAddress) [][]byte {
+	secret := wotsSecret(secret_seed, address)
+	public := make([][]byte, wotsLen)
+
+	for i := 0; i < wotsLen; i++ {
+		address.setChain(uint32(i))
+		public[i] = wotsChain(secret[i], 0, wotsW-1, public_seed, address)
+	}
+
+	return public
+}
+
+// signs a 32 byte digest with the one-time key at the address
+func wotsSign(digest, secret_seed, public_seed []byte, address *hashAddress) [][]byte {
+	digits := wotsDigits(digest)
+	secret := wotsSecret(secret_seed, address)
+	signature := make([][]byte, wotsLen)
+
+	for i := 0; i < wotsLen; i++ {
+		address.setChain(uint32(i))
+		signature[i] = wotsChain(secret[i], 0, digits[i], public_seed, address)
+	}
+
+	return signature
+}
+
+// recovers the one-time public key implied by a signature, which only matches if the signature is valid
+func wotsPublicFromSignature(signature [][]byte, digest, public_seed []byte, address *hashAddress) [][]byte {
+	digits := wotsDigits(digest)
+	public := make([][]byte, wotsLen)
+
+	for i := 0; i < wotsLen; i++ {
+		address.setChain(uint32(i))
+		public[i] = wotsChain(signature[i], digits[i], wotsW-1-digits[i], public_seed, address)
+	}
+
+	return public
+}
+
+// hashes two nodes together with masks and key taken from the address
+func randomHash(left, right, public_seed []byte, address *hashAddress) []byte {
+	address.setKeyAndMask(0)
+	key := prf(public_seed, address)
+	address.setKeyAndMask(1)
+	left_mask := prf(public_seed, address)
+	address.setKeyAndMask(2)
+	right_mask := prf(public_seed, address)
+
+	return keyedHash(hashH, key, xorBytes(left, left_mask), xorBytes(right, right_mask))
+}
+
+// compresses a one-time public key into a single leaf
+func lTree(public [][]byte, public_seed []byte, address *hashAddress) []byte {
+	nodes := make([][]byte, len(public))
+	copy(nodes, public)
+	height := uint32(0)
+
+	for len(nodes) > 1 {
+		address.setChain(height)
+		next := make([][]byte, 0, (len(nodes)+1)/2)
+
+		for i := 0; i+1 < len(nodes); i += 2 {
+			address.setHash(uint32(i / 2))
+			next = append(next, randomHash(nodes[i], nodes[i+1], public_seed, address))
+		}
+
+		// an odd node is carried up unchanged
+		if len(nodes)%2 == 1 {
+			next = append(next, nodes[len(nodes)-1])
+		}
+
+		nodes = next
+		height++
+	}
+
+	return nodes[0]
+}
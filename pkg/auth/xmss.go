@@ -0,0 +1,555 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrHeight       = errors.New("auth: tree height must be between 1 and 16")
+	ErrKeyExhausted = errors.New("auth: every one-time key has been used")
+	ErrKeyReuse     = errors.New("auth: refusing to reuse a one-time key")
+)
+
+// the tree leaves are WOTS+ keys (RFC 8391, XMSS-SHA2_h_256)
+type XMSSPublicKey struct {
+	root   []byte
+	seed   []byte
+	height int
+}
+
+func (public *XMSSPublicKey) Height() int {
+	return public.height
+}
+
+// encodes the key as height || root || public seed
+func (public *XMSSPublicKey) Marshal() []byte {
+	output := []byte{byte(public.height)}
+	output = append(output, public.root...)
+
+	return append(output, public.seed...)
+}
+
+// decodes a public key produced by Marshal
+func ParseXMSSPublicKey(data []byte) (*XMSSPublicKey, error) {
+	if len(data) != 1+2*wotsN {
+		return nil, ErrMalformed
+	}
+
+	if data[0] < 1 || data[0] > MaxXMSSHeight {
+		return nil, ErrHeight
+	}
+
+	public := new(XMSSPublicKey)
+	public.height = int(data[0])
+	public.root = append([]byte(nil), data[1:1+wotsN]...)
+	public.seed = append([]byte(nil), data[1+wotsN:]...)
+
+	return public, nil
+}
+
+// the tallest tree accepted, building it takes 2**16 WOTS+ key generations
+const MaxXMSSHeight = 16
+
+// the tree is split into subtrees of this height, only the one currently signing is kept in full
+const xmssSubtreeHeight = 8
+
+// holds the seeds every one-time key is derived from along with the cached parts of the merkle tree
+type XMSSPrivateKey struct {
+	secret_seed []byte
+	prf_seed    []byte
+	public_seed []byte
+	height      int
+	// top[level][index] counted from the subtree roots up, the last level holds the root
+	top [][][]byte
+	// the subtree that signatures are currently drawn from, lower[level][index] within it
+	subtree int
+	lower   [][][]byte
+	mutex   sync.Mutex
+}
+
+// rebuilds a private key and its tree from the three seeds
+func NewXMSSPrivateKey(secret_seed, prf_seed, public_seed []byte, height int) (*XMSSPrivateKey, error) {
+	private, err := newXMSSPrivateKey(secret_seed, prf_seed, public_seed, height)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// treehash over one subtree at a time keeps only the subtree roots in memory
+	roots := make([][]byte, 1<<(height-private.subtreeHeight()))
+
+	for i := range roots {
+		lower := private.buildSubtree(i)
+		roots[i] = lower[len(lower)-1][0]
+	}
+
+	private.buildTop(roots)
+
+	return private, nil
+}
+
+func newXMSSPrivateKey(secret_seed, prf_seed, public_seed []byte, height int) (*XMSSPrivateKey, error) {
+	if height < 1 || height > MaxXMSSHeight {
+		return nil, ErrHeight
+	}
+
+	if len(secret_seed) != wotsN || len(prf_seed) != wotsN || len(public_seed) != wotsN {
+		return nil, ErrMalformed
+	}
+
+	private := new(XMSSPrivateKey)
+	private.secret_seed = secret_seed
+	private.prf_seed = prf_seed
+	private.public_seed = public_seed
+	private.height = height
+	private.subtree = -1
+
+	return private, nil
+}
+
+func (private *XMSSPrivateKey) subtreeHeight() int {
+	if private.height < xmssSubtreeHeight {
+		return private.height
+	}
+
+	return xmssSubtreeHeight
+}
+
+// hashes pairs of nodes at level-1 into the nodes of level, first is the index of the first node
+// within the whole level so the addresses match those of a tree built in one piece
+func (private *XMSSPrivateKey) hashLevel(children [][]byte, level int, first uint32) [][]byte {
+	address := new(hashAddress)
+	address.setType(addressTree)
+	address.setChain(uint32(level - 1))
+	nodes := make([][]byte, len(children)/2)
+
+	for i := 0; i < len(nodes); i++ {
+		address.setHash(first + uint32(i))
+		nodes[i] = randomHash(children[2*i], children[2*i+1], private.public_seed, address)
+	}
+
+	return nodes
+}
+
+// computes every node of subtree i, from its leaves up to its root
+func (private *XMSSPrivateKey) buildSubtree(i int) [][][]byte {
+	height := private.subtreeHeight()
+	nodes := make([][][]byte, height+1)
+	nodes[0] = make([][]byte, 1<<height)
+
+	for j := range nodes[0] {
+		nodes[0][j] = private.leaf(uint32(i<<height + j))
+	}
+
+	for level := 1; level <= height; level++ {
+		nodes[level] = private.hashLevel(nodes[level-1], level, uint32(i<<(height-level)))
+	}
+
+	return nodes
+}
+
+// computes the levels above the subtree roots
+func (private *XMSSPrivateKey) buildTop(roots [][]byte) {
+	height := private.subtreeHeight()
+	private.top = [][][]byte{roots}
+
+	for level := height + 1; level <= private.height; level++ {
+		private.top = append(private.top, private.hashLevel(private.top[len(private.top)-1], level, 0))
+	}
+}
+
+// the authentication path for a one-time key, rebuilding the subtree holding it when it is not cached
+func (private *XMSSPrivateKey) path(index uint32) [][]byte {
+	private.mutex.Lock()
+	defer private.mutex.Unlock()
+
+	height := private.subtreeHeight()
+
+	if subtree := int(index >> height); subtree != private.subtree {
+		private.lower = private.buildSubtree(subtree)
+		private.subtree = subtree
+	}
+
+	path := make([][]byte, private.height)
+
+	for level := 0; level < private.height; level++ {
+		sibling := (index >> level) ^ 1
+
+		if level < height {
+			path[level] = private.lower[level][sibling&(1<<(height-level)-1)]
+		} else {
+			path[level] = private.top[level-height][sibling]
+		}
+	}
+
+	return path
+}
+
+// generates a fresh key pair with 2**height one-time keys
+func XMSSKeyPair(height int) (*XMSSPublicKey, *XMSSPrivateKey, error) {
+	seeds := make([]byte, 3*wotsN)
+
+	if _, err := rand.Read(seeds); err != nil {
+		return nil, nil, err
+	}
+
+	private, err := NewXMSSPrivateKey(seeds[:wotsN], seeds[wotsN:2*wotsN], seeds[2*wotsN:], height)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return private.Public(), private, nil
+}
+
+// computes the leaf for one-time key i
+func (private *XMSSPrivateKey) leaf(i uint32) []byte {
+	address := new(hashAddress)
+	address.setType(addressOTS)
+	address.setLeaf(i)
+	public := wotsPublic(private.secret_seed, private.public_seed, address)
+
+	address.setType(addressLTree)
+	address.setLeaf(i)
+
+	return lTree(public, private.public_seed, address)
+}
+
+func (private *XMSSPrivateKey) Public() *XMSSPublicKey {
+	public := new(XMSSPublicKey)
+	public.root = private.top[len(private.top)-1][0]
+	public.seed = private.public_seed
+	public.height = private.height
+
+	return public
+}
+
+// the number of one-time keys in the tree
+func (private *XMSSPrivateKey) Leaves() uint32 {
+	return 1 << private.height
+}
+
+// encodes the key as height || secret seed || prf seed || public seed || subtree roots, the roots are
+// cached state that saves rebuilding the tree when the key is loaded. the usage index is not included
+func (private *XMSSPrivateKey) Marshal() []byte {
+	output := []byte{byte(private.height)}
+	output = append(output, private.secret_seed...)
+	output = append(output, private.prf_seed...)
+	output = append(output, private.public_seed...)
+
+	for _, root := range private.top[0] {
+		output = append(output, root...)
+	}
+
+	return output
+}
+
+// decodes a private key produced by Marshal, the tree is rebuilt when the subtree roots are left out
+func ParseXMSSPrivateKey(data []byte) (*XMSSPrivateKey, error) {
+	if len(data) < 1+3*wotsN {
+		return nil, ErrMalformed
+	}
+
+	seeds := append([]byte(nil), data[1:1+3*wotsN]...)
+	roots := data[1+3*wotsN:]
+
+	if len(roots) == 0 {
+		return NewXMSSPrivateKey(seeds[:wotsN], seeds[wotsN:2*wotsN], seeds[2*wotsN:], int(data[0]))
+	}
+
+	private, err := newXMSSPrivateKey(seeds[:wotsN], seeds[wotsN:2*wotsN], seeds[2*wotsN:], int(data[0]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roots) != wotsN<<(private.height-private.subtreeHeight()) {
+		return nil, ErrMalformed
+	}
+
+	cached := make([][]byte, 0)
+
+	for i := 0; i < len(roots); i += wotsN {
+		cached = append(cached, append([]byte(nil), roots[i:i+wotsN]...))
+	}
+
+	private.buildTop(cached)
+
+	return private, nil
+}
+
+func indexBytes(index uint32) []byte {
+	output := make([]byte, wotsN)
+	binary.BigEndian.PutUint32(output[wotsN-4:], index)
+
+	return output
+}
+
+// H_msg(r || root || index, message)
+func xmssDigest(randomness, root []byte, index uint32, message []byte) []byte {
+	key := make([]byte, 0, 3*wotsN)
+	key = append(key, randomness...)
+	key = append(key, root...)
+	key = append(key, indexBytes(index)...)
+
+	return keyedHash(hashMsg, key, message)
+}
+
+// signs with the one-time key at index, the output is r || wots signature || authentication path
+func (private *XMSSPrivateKey) sign(index uint32, message []byte) []byte {
+	root := private.top[len(private.top)-1][0]
+	randomness := keyedHash(hashPRF, private.prf_seed, indexBytes(index))
+	digest := xmssDigest(randomness, root, index, message)
+
+	address := new(hashAddress)
+	address.setType(addressOTS)
+	address.setLeaf(index)
+
+	output := make([]byte, 0, xmssSignatureSize(private.height))
+	output = append(output, randomness...)
+
+	for _, chain := range wotsSign(digest, private.secret_seed, private.public_seed, address) {
+		output = append(output, chain...)
+	}
+
+	for _, node := range private.path(index) {
+		output = append(output, node...)
+	}
+
+	return output
+}
+
+func xmssSignatureSize(height int) int {
+	return wotsN + wotsLen*wotsN + height*wotsN
+}
+
+// recomputes the root from a signature and compares it against the public key
+func (public *XMSSPublicKey) verify(index uint32, message, signature []byte) bool {
+	if len(signature) != xmssSignatureSize(public.height) || uint64(index) >= uint64(1)<<public.height {
+		return false
+	}
+
+	randomness := signature[:wotsN]
+	chains := make([][]byte, wotsLen)
+
+	for i := 0; i < wotsLen; i++ {
+		chains[i] = signature[wotsN*(i+1) : wotsN*(i+2)]
+	}
+
+	path := signature[wotsN*(wotsLen+1):]
+	digest := xmssDigest(randomness, public.root, index, message)
+
+	address := new(hashAddress)
+	address.setType(addressOTS)
+	address.setLeaf(index)
+	one_time := wotsPublicFromSignature(chains, digest, public.seed, address)
+
+	address.setType(addressLTree)
+	address.setLeaf(index)
+	node := lTree(one_time, public.seed, address)
+
+	address.setType(addressTree)
+
+	for level := 0; level < public.height; level++ {
+		sibling := path[level*wotsN : (level+1)*wotsN]
+		address.setChain(uint32(level))
+		address.setHash(index >> (level + 1))
+
+		if (index>>level)&1 == 0 {
+			node = randomHash(node, sibling, public.seed, address)
+		} else {
+			node = randomHash(sibling, node, public.seed, address)
+		}
+	}
+
+	return subtle.ConstantTimeCompare(node, public.root) == 1
+}
+
+// records which one-time keys have been handed out, Reserve must make the new index durable before
+// returning so that a crash can never cause the same key to be used twice
+type KeyState interface {
+	Reserve() (uint32, error)
+}
+
+// keeps the next unused index in memory, suitable for tests and short lived keys
+type CounterKeyState struct {
+	next  uint32
+	mutex sync.Mutex
+}
+
+func NewCounterKeyState(next uint32) *CounterKeyState {
+	state := new(CounterKeyState)
+	state.next = next
+
+	return state
+}
+
+func (state *CounterKeyState) Reserve() (uint32, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.next == ^uint32(0) {
+		return 0, ErrKeyExhausted
+	}
+
+	index := state.next
+	state.next++
+
+	return index, nil
+}
+
+// keeps the next unused index in a file, which is rewritten and synced before each index is released
+type FileKeyState struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileKeyState(path string) *FileKeyState {
+	state := new(FileKeyState)
+	state.path = path
+
+	return state
+}
+
+func (state *FileKeyState) Reserve() (uint32, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	index := uint64(0)
+	data, err := os.ReadFile(state.path)
+
+	if err == nil {
+		index, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+
+		if err != nil {
+			return 0, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	if index == uint64(^uint32(0)) {
+		return 0, ErrKeyExhausted
+	}
+
+	temp_path := state.path + ".tmp"
+	temp_file, err := os.Create(temp_path)
+
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = temp_file.WriteString(strconv.FormatUint(index+1, 10) + "\n")
+
+	if err == nil {
+		err = temp_file.Sync()
+	}
+
+	if close_err := temp_file.Close(); err == nil {
+		err = close_err
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(temp_path, state.path); err != nil {
+		return 0, err
+	}
+
+	return uint32(index), nil
+}
+
+// stateful hash-based prover, each proof consumes one of the 2**height one-time keys
+type XMSSProver struct {
+	private *XMSSPrivateKey
+	state   KeyState
+	next    uint32
+	mutex   sync.Mutex
+}
+
+// setup a hash-based prover, the state decides which one-time key is used next
+func SetupXMSSProver(private *XMSSPrivateKey, state KeyState) *XMSSProver {
+	prover := new(XMSSProver)
+	prover.private = private
+	prover.state = state
+
+	return prover
+}
+
+// signs the block with the next one-time key, the statement of the proof carries the key index and the
+// proof carries the signature. the block is hashed with the randomised H_msg rather than a challenger,
+// so a collision in a shorter challenge digest can not be turned into a forgery
+func (prover *XMSSProver) Sign(block []byte) (*Proof, error) {
+	prover.mutex.Lock()
+	defer prover.mutex.Unlock()
+
+	index, err := prover.state.Reserve()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// guard against a state that hands back an index this prover has already used
+	if index < prover.next {
+		return nil, ErrKeyReuse
+	}
+
+	if index >= prover.private.Leaves() {
+		return nil, ErrKeyExhausted
+	}
+
+	prover.next = index + 1
+
+	statement := big.NewInt(int64(index))
+	signature := prover.private.sign(index, block)
+
+	proof := big.NewInt(0)
+	proof.SetBytes(signature)
+
+	return NewProof(statement, proof), nil
+}
+
+// generates a proof for the block, the randomness is unused since signing is deterministic
+func (prover *XMSSProver) ProofGen(randomness *big.Int, block []byte) (*Proof, error) {
+	return prover.Sign(block)
+}
+
+// hash-based verifier object
+type XMSSVerifier struct {
+	public *XMSSPublicKey
+}
+
+// setup a hash-based verifier object
+func SetupXMSSVerifier(public *XMSSPublicKey) *XMSSVerifier {
+	verifier := new(XMSSVerifier)
+	verifier.public = public
+
+	return verifier
+}
+
+// verifies a proof produced by XMSSProver
+func (verifier *XMSSVerifier) Verify(proof Proof, block []byte) bool {
+	if proof.statement == nil || proof.proof == nil || !proof.statement.IsUint64() || proof.statement.Uint64() > uint64(^uint32(0)) {
+		return false
+	}
+
+	size := xmssSignatureSize(verifier.public.height)
+
+	if proof.proof.Sign() < 0 || proof.proof.BitLen() > size*8 {
+		return false
+	}
+
+	signature := proof.proof.FillBytes(make([]byte, size))
+	index := uint32(proof.statement.Uint64())
+
+	return verifier.public.verify(index, block, signature)
+}
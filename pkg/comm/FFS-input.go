@@ -4,12 +4,14 @@ import (
 	"io"
 
 	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
 )
 
 // this wrapper wraps messages in blocks of 255 bytes into proofs
 type FFSInputWrapper struct {
-	prover *auth.FFSProver
-	input  io.Reader
+	prover   *auth.FFSProver
+	input    io.Reader
+	sequence uint64
 }
 
 // constructs a new input wrapper for feige-fiat-shamir
@@ -21,7 +23,12 @@ func NewFFSInputWrapper(prover *auth.FFSProver, input io.Reader) *FFSInputWrappe
 	return wrapper
 }
 
-// wraps the message block into a proof and outputs it
+// the sequence number of the next record
+func (wrapper *FFSInputWrapper) Sequence() uint64 {
+	return wrapper.sequence
+}
+
+// wraps the message block into a proof record whose fields are the statement, the proof and the block
 func (wrapper *FFSInputWrapper) Wrap() ([]byte, error) {
 	buf := make([]byte, 255)
 	n, err := wrapper.input.Read(buf)
@@ -32,21 +39,26 @@ func (wrapper *FFSInputWrapper) Wrap() ([]byte, error) {
 		return nil, nil
 	}
 
-	randomness, err := wrapper.prover.Group().Random()
+	randomness, err := wrapper.prover.Randomness(buf[:n])
+
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := wrapper.prover.ProofGen(randomness, buf[:n])
+	enc.WipeInt(randomness)
 
 	if err != nil {
 		return nil, err
 	}
 
-	proof := wrapper.prover.ProofGen(randomness, buf[:n])
 	proof_bytes := make([]byte, wrapper.prover.Group().Ring().Size()/8)
 	proof_bytes = proof.Proof().FillBytes(proof_bytes)
 	statement_bytes := make([]byte, wrapper.prover.Group().Ring().Size()/8)
 	statement_bytes = proof.Statement().FillBytes(statement_bytes)
-	output := make([]byte, 0)
-	output = append(output, statement_bytes...)
-	output = append(output, proof_bytes...)
-	output = append(output, buf[:n]...)
 
-	return output, nil
+	record := NewRecord(ContentProof, 0, 0, wrapper.sequence, statement_bytes, proof_bytes, buf[:n])
+	wrapper.sequence++
+
+	return record.Marshal(), nil
 }
@@ -11,6 +11,7 @@ import (
 type FFSOutputWrapper struct {
 	verifier *auth.FFSVerifier
 	input    io.Reader
+	sequence uint64
 }
 
 // constructs a new feige-fiat-shamir output wrapper
@@ -22,30 +23,39 @@ func NewFFSOutputWrapper(verifier *auth.FFSVerifier, input io.Reader) *FFSOutput
 	return wrapper
 }
 
-// parses the proof and verifies it before outputting the result and message
-func (wrapper *FFSOutputWrapper) Wrap() ([]byte, error) {
-
-	statement_bytes := make([]byte, wrapper.verifier.Modulus().BitLen()/8)
-	proof_bytes := make([]byte, wrapper.verifier.Modulus().BitLen()/8)
+// the sequence number expected of the next record
+func (wrapper *FFSOutputWrapper) Sequence() uint64 {
+	return wrapper.sequence
+}
 
-	_, err := io.ReadFull(wrapper.input, statement_bytes)
+// parses the proof record and verifies it before outputting the result and message
+func (wrapper *FFSOutputWrapper) Wrap() ([]byte, error) {
+	record, err := ReadRecord(wrapper.input)
 
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = io.ReadFull(wrapper.input, proof_bytes)
+	err = record.header().expect(ContentProof, 0, 0, wrapper.sequence)
 
 	if err != nil {
 		return nil, err
 	}
 
-	message, err := io.ReadAll(wrapper.input)
+	if len(record.fields) != 3 {
+		return nil, ErrRecord
+	}
 
-	if err != nil {
-		return nil, err
+	statement_bytes, proof_bytes, message := record.fields[0], record.fields[1], record.fields[2]
+	size := wrapper.verifier.Modulus().BitLen() / 8
+
+	// a proof over another modulus is rejected rather than reported as invalid
+	if len(statement_bytes) != size || len(proof_bytes) != size {
+		return nil, ErrParameters
 	}
 
+	wrapper.sequence++
+
 	statement := big.NewInt(0)
 	proof := big.NewInt(0)
 	statement.SetBytes(statement_bytes)
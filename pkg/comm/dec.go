@@ -1,69 +1,123 @@
 package comm
 
 import (
-	"encoding/binary"
 	"io"
 
 	"github.com/SimonHorrocks/Zerocat/pkg/enc"
 )
 
-// this wrapper decrypts proofs using the encapsulated key
+// this wrapper opens proofs sealed by an EncapsulationWrapper
 type DecryptionWrapper struct {
-	encapsulator *enc.AESEncapsulator
-	input        io.Reader
-	nonce_size   int
-	capsule_size int
+	sealer   enc.Sealer
+	input    io.Reader
+	kem      enc.KEMID
+	dem      enc.DEMID
+	sequence uint64
+	header   []byte
+	fields   [][]byte
+	capsule  *enc.Capsule
 }
 
-// constructs a new decryption wrapper
-func NewDecryptionWrapper(encapsulator *enc.AESEncapsulator, input io.Reader, nonce_size, capsule_size int) *DecryptionWrapper {
+// constructs a new decryption wrapper, records from other algorithms are rejected before they are opened
+// and the capsule and ciphertext sizes are checked by the sealer
+func NewDecryptionWrapper(sealer enc.Sealer, input io.Reader) *DecryptionWrapper {
 	wrapper := new(DecryptionWrapper)
-	wrapper.encapsulator = encapsulator
+	wrapper.sealer = sealer
 	wrapper.input = input
-	wrapper.nonce_size = nonce_size
-	wrapper.capsule_size = capsule_size
+	wrapper.kem, wrapper.dem = sealer.Algorithms()
+	wrapper.header = make([]byte, RecordHeaderSize)
+	wrapper.fields = make([][]byte, 0, 3)
+	wrapper.capsule = enc.NewCapsule(wrapper.kem, nil, nil)
 
 	return wrapper
 }
 
-// derives the key and decrypts the message and proof
-func (wrapper *DecryptionWrapper) Wrap() ([]byte, error) {
-	nonce := make([]byte, wrapper.nonce_size)
-	_, err := io.ReadFull(wrapper.input, nonce)
+// the sequence number expected of the next record
+func (wrapper *DecryptionWrapper) Sequence() uint64 {
+	return wrapper.sequence
+}
+
+// reads the next record, leaving its body in a pooled buffer
+func (wrapper *DecryptionWrapper) readRecord() ([]byte, error) {
+	_, err := io.ReadFull(wrapper.input, wrapper.header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := parseRecordHeader(wrapper.header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the body is read before the header is checked so the stream stays at a record boundary
+	body := grow(frames.get(), header.length)[:header.length]
+	_, err = io.ReadFull(wrapper.input, body)
+
+	if err != nil {
+		frames.put(body)
+		return nil, err
+	}
+
+	err = header.expect(ContentEncrypted, wrapper.kem, wrapper.dem, wrapper.sequence)
 
 	if err != nil {
+		frames.put(body)
 		return nil, err
 	}
 
-	capsule := make([]byte, wrapper.capsule_size)
-	_, err = io.ReadFull(wrapper.input, capsule)
+	return body, nil
+}
+
+// appends the plaintext to dst, copying it in when the sealer can not append itself
+func openTo(sealer enc.Sealer, dst []byte, capsule *enc.Capsule, ciphertext, additional []byte) ([]byte, error) {
+	if buffered, ok := sealer.(enc.BufferedSealer); ok {
+		return buffered.OpenTo(dst, capsule, ciphertext, additional)
+	}
+
+	plaintext, err := sealer.Open(capsule, ciphertext, additional)
 
 	if err != nil {
 		return nil, err
 	}
 
-	ciphertext_size := make([]byte, 4)
-	_, err = io.ReadFull(wrapper.input, ciphertext_size)
+	return append(dst, plaintext...), nil
+}
+
+// recovers the key from the capsule and decrypts the message and proof under the record header, the
+// plaintext may be handed back with Release
+func (wrapper *DecryptionWrapper) Wrap() ([]byte, error) {
+	body, err := wrapper.readRecord()
 
 	if err != nil {
 		return nil, err
 	}
 
-	ciphertext := make([]byte, binary.LittleEndian.Uint32(ciphertext_size))
-	_, err = io.ReadFull(wrapper.input, ciphertext)
+	fields, err := parseFields(body, wrapper.fields[:0])
+
+	if err == nil && len(fields) != 3 {
+		err = ErrRecord
+	}
 
 	if err != nil {
+		frames.put(body)
 		return nil, err
 	}
 
-	plaintext, err := wrapper.encapsulator.Decrypt(ciphertext, capsule, nonce)
+	wrapper.fields = fields
+	wrapper.capsule.Reset(wrapper.kem, fields[0], fields[1])
+
+	output := frames.get()
+	plaintext, err := openTo(wrapper.sealer, output, wrapper.capsule, fields[2], wrapper.header[:recordAdditionalSize])
+	frames.put(body)
 
 	if err != nil {
+		frames.put(output)
 		return nil, err
 	}
 
-	output := make([]byte, 0)
-	output = append(output, plaintext...)
+	wrapper.sequence++
 
-	return output, nil
+	return plaintext, nil
 }
@@ -1,48 +1,79 @@
 package comm
 
 import (
-	"encoding/binary"
 	"io"
 
 	"github.com/SimonHorrocks/Zerocat/pkg/enc"
 )
 
-// this wrapper uses KEM to encrypt proofs
+// this wrapper seals proofs with a KEM and DEM
 type EncapsulationWrapper struct {
-	encapsulator *enc.AESEncapsulator
-	input        io.Reader
+	sealer   enc.Sealer
+	input    io.Reader
+	kem      enc.KEMID
+	dem      enc.DEMID
+	sequence uint64
 }
 
 // constructs a new encapsulation wrapper
-func NewEncapsulationWrapper(encapsulator *enc.AESEncapsulator, input io.Reader) *EncapsulationWrapper {
+func NewEncapsulationWrapper(sealer enc.Sealer, input io.Reader) *EncapsulationWrapper {
 	wrapper := new(EncapsulationWrapper)
-	wrapper.encapsulator = encapsulator
+	wrapper.sealer = sealer
 	wrapper.input = input
+	wrapper.kem, wrapper.dem = sealer.Algorithms()
 
 	return wrapper
 }
 
-// wraps the proof in an encryption and appends the key material that encapsulates the key
+// the sequence number of the next record
+func (wrapper *EncapsulationWrapper) Sequence() uint64 {
+	return wrapper.sequence
+}
+
+// appends the ciphertext to dst, copying it in when the sealer can not append itself
+func sealTo(sealer enc.Sealer, dst, plaintext, additional []byte) (*enc.Capsule, []byte, error) {
+	if buffered, ok := sealer.(enc.BufferedSealer); ok {
+		return buffered.SealTo(dst, plaintext, additional)
+	}
+
+	capsule, ciphertext, err := sealer.Seal(plaintext, additional)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return capsule, append(dst, ciphertext...), nil
+}
+
+// wraps the proof in an encrypted record whose fields are the capsule's key id and data and the
+// ciphertext. the record header is the additional data, so a body can not be moved under another
+// sequence number or algorithm. the record may be handed back with Release
 func (wrapper *EncapsulationWrapper) Wrap() ([]byte, error) {
-	buf, err := io.ReadAll(wrapper.input)
+	plaintext, err := readAll(wrapper.input, frames.get())
 
 	if err != nil {
+		frames.put(plaintext)
 		return nil, err
 	}
 
-	ciphertext, randomness, nonce, err := wrapper.encapsulator.Encapsulate(buf)
+	output := appendRecordHeader(frames.get(), ContentEncrypted, wrapper.kem, wrapper.dem, wrapper.sequence, 0)
+	capsule, ciphertext, err := sealTo(wrapper.sealer, frames.get(), plaintext, output[:recordAdditionalSize])
+	frames.put(plaintext)
 
 	if err != nil {
+		frames.put(output)
 		return nil, err
 	}
 
-	output := make([]byte, 0)
-	output = append(output, nonce...)
-	output = append(output, randomness...)
-	ciphertext_size := make([]byte, 4)
-	binary.LittleEndian.PutUint32(ciphertext_size, uint32(len(ciphertext)))
-	output = append(output, ciphertext_size...)
-	output = append(output, ciphertext...)
+	output = grow(output, 1+12+len(capsule.KeyID())+len(capsule.Data())+len(ciphertext))
+	output = append(output, 3)
+	output = enc.AppendField(output, capsule.KeyID())
+	output = enc.AppendField(output, capsule.Data())
+	output = enc.AppendField(output, ciphertext)
+	frames.put(ciphertext)
+
+	setBodyLength(output, len(output)-RecordHeaderSize)
+	wrapper.sequence++
 
 	return output, nil
 }
@@ -0,0 +1,84 @@
+package comm
+
+import (
+	"io"
+	"sync"
+)
+
+// frames kept for reuse, and the largest frame worth keeping
+const (
+	maxPooledFrames   = 64
+	maxPooledFrameCap = 1 << 20
+)
+
+// a free list of frame buffers shared by every wrapper. unlike sync.Pool, returning a buffer does not
+// allocate, so a steady stream of frames runs without allocating
+type framePool struct {
+	lock   sync.Mutex
+	frames [][]byte
+}
+
+var frames = &framePool{frames: make([][]byte, 0, maxPooledFrames)}
+
+// an empty buffer, with spare capacity if one was released earlier
+func (pool *framePool) get() []byte {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if len(pool.frames) == 0 {
+		return nil
+	}
+
+	frame := pool.frames[len(pool.frames)-1]
+	pool.frames = pool.frames[:len(pool.frames)-1]
+
+	return frame[:0]
+}
+
+func (pool *framePool) put(frame []byte) {
+	if cap(frame) == 0 || cap(frame) > maxPooledFrameCap {
+		return
+	}
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if len(pool.frames) < maxPooledFrames {
+		pool.frames = append(pool.frames, frame[:0])
+	}
+}
+
+// hands a frame returned by Wrap back for reuse, the frame must not be used afterwards. frames that
+// are never released are simply collected
+func Release(frame []byte) {
+	frames.put(frame)
+}
+
+// grows the buffer to hold n more bytes
+func grow(buf []byte, n int) []byte {
+	if cap(buf)-len(buf) >= n {
+		return buf
+	}
+
+	grown := make([]byte, len(buf), 2*cap(buf)+n)
+	copy(grown, buf)
+
+	return grown
+}
+
+// reads until EOF, appending to buf
+func readAll(input io.Reader, buf []byte) ([]byte, error) {
+	for {
+		buf = grow(buf, 512)
+		n, err := input.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		if err == io.EOF {
+			return buf, nil
+		}
+
+		if err != nil {
+			return buf, err
+		}
+	}
+}
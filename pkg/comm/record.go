@@ -0,0 +1,274 @@
+package comm
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// version of the record layer written by this package
+const RecordVersion = 1
+
+// the fixed header: version, content type, KEM and DEM ids, sequence number and body length
+const RecordHeaderSize = 18
+
+// the header up to the body length is the additional data of an encrypted record, the length is
+// covered by the ciphertext itself
+const recordAdditionalSize = 14
+
+// largest record body accepted from a peer
+const MaxRecordSize = 1 << 24
+
+var (
+	ErrVersion     = errors.New("comm: unsupported record version")
+	ErrContentType = errors.New("comm: unexpected record content type")
+	ErrAlgorithm   = errors.New("comm: peer uses a different algorithm")
+	ErrParameters  = errors.New("comm: peer uses different parameters")
+	ErrSequence    = errors.New("comm: record out of sequence")
+	ErrRecord      = errors.New("comm: malformed record")
+	ErrRecordSize  = errors.New("comm: record too large")
+)
+
+// what a record carries
+type ContentType uint8
+
+const (
+	// a feige-fiat-shamir proof, its KEM and DEM ids are zero
+	ContentProof     ContentType = 0x01
+	ContentEncrypted ContentType = 0x02
+)
+
+// a record: a versioned header followed by a body of length prefixed fields
+type Record struct {
+	version  uint8
+	content  ContentType
+	kem      enc.KEMID
+	dem      enc.DEMID
+	sequence uint64
+	fields   [][]byte
+}
+
+// builds a record of the current version
+func NewRecord(content ContentType, kem enc.KEMID, dem enc.DEMID, sequence uint64, fields ...[]byte) *Record {
+	record := new(Record)
+	record.version = RecordVersion
+	record.content = content
+	record.kem = kem
+	record.dem = dem
+	record.sequence = sequence
+	record.fields = fields
+
+	return record
+}
+
+func (record *Record) Version() uint8 {
+	return record.version
+}
+
+func (record *Record) Content() ContentType {
+	return record.content
+}
+
+func (record *Record) KEM() enc.KEMID {
+	return record.kem
+}
+
+func (record *Record) DEM() enc.DEMID {
+	return record.dem
+}
+
+func (record *Record) Sequence() uint64 {
+	return record.sequence
+}
+
+func (record *Record) Fields() [][]byte {
+	return record.fields
+}
+
+func (record *Record) header() recordHeader {
+	return recordHeader{content: record.content, kem: record.kem, dem: record.dem, sequence: record.sequence}
+}
+
+// the body is a field count followed by every field with a uint32 BE length prefix
+func bodySize(fields [][]byte) int {
+	size := 1
+
+	for _, field := range fields {
+		size += 4 + len(field)
+	}
+
+	return size
+}
+
+// appends the header of a record, the body length can be patched later with setBodyLength
+func appendRecordHeader(dst []byte, content ContentType, kem enc.KEMID, dem enc.DEMID, sequence uint64, body_length int) []byte {
+	dst = append(dst, RecordVersion, byte(content))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(kem))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(dem))
+	dst = binary.BigEndian.AppendUint64(dst, sequence)
+
+	return binary.BigEndian.AppendUint32(dst, uint32(body_length))
+}
+
+func setBodyLength(header []byte, body_length int) {
+	binary.BigEndian.PutUint32(header[recordAdditionalSize:], uint32(body_length))
+}
+
+// appends the encoded record to dst
+func (record *Record) AppendTo(dst []byte) []byte {
+	dst = appendRecordHeader(dst, record.content, record.kem, record.dem, record.sequence, bodySize(record.fields))
+	dst = append(dst, byte(len(record.fields)))
+
+	for _, field := range record.fields {
+		dst = enc.AppendField(dst, field)
+	}
+
+	return dst
+}
+
+func (record *Record) Marshal() []byte {
+	return record.AppendTo(nil)
+}
+
+// the header fields of a record
+type recordHeader struct {
+	content  ContentType
+	kem      enc.KEMID
+	dem      enc.DEMID
+	sequence uint64
+	length   int
+}
+
+// decodes a header, rejecting other versions and oversized bodies
+func parseRecordHeader(data []byte) (recordHeader, error) {
+	var header recordHeader
+
+	if len(data) < RecordHeaderSize {
+		return header, ErrRecord
+	}
+
+	if data[0] != RecordVersion {
+		return header, ErrVersion
+	}
+
+	header.content = ContentType(data[1])
+	header.kem = enc.KEMID(binary.BigEndian.Uint16(data[2:]))
+	header.dem = enc.DEMID(binary.BigEndian.Uint16(data[4:]))
+	header.sequence = binary.BigEndian.Uint64(data[6:])
+	length := binary.BigEndian.Uint32(data[recordAdditionalSize:])
+
+	if length > MaxRecordSize {
+		return header, ErrRecordSize
+	}
+
+	header.length = int(length)
+
+	return header, nil
+}
+
+// checks the header against what the reader expects
+func (header recordHeader) expect(content ContentType, kem enc.KEMID, dem enc.DEMID, sequence uint64) error {
+	if header.content != content {
+		return ErrContentType
+	}
+
+	if header.kem != kem || header.dem != dem {
+		return ErrAlgorithm
+	}
+
+	if header.sequence != sequence {
+		return ErrSequence
+	}
+
+	return nil
+}
+
+// splits a body into its fields, appending them to fields. the fields point into the body
+func parseFields(body []byte, fields [][]byte) ([][]byte, error) {
+	if len(body) < 1 {
+		return nil, ErrRecord
+	}
+
+	count := int(body[0])
+	body = body[1:]
+
+	for i := 0; i < count; i++ {
+		field, rest, err := enc.ReadField(body)
+
+		if err != nil {
+			return nil, ErrRecord
+		}
+
+		fields = append(fields, field)
+		body = rest
+	}
+
+	if len(body) != 0 {
+		return nil, ErrRecord
+	}
+
+	return fields, nil
+}
+
+// decodes one record that fills the data
+func ParseRecord(data []byte) (*Record, error) {
+	header, err := parseRecordHeader(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data)-RecordHeaderSize != header.length {
+		return nil, ErrRecord
+	}
+
+	fields, err := parseFields(data[RecordHeaderSize:], nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	record := NewRecord(header.content, header.kem, header.dem, header.sequence, fields...)
+
+	return record, nil
+}
+
+// reads exactly one record from a stream
+func ReadRecord(input io.Reader) (*Record, error) {
+	data := make([]byte, RecordHeaderSize)
+
+	if _, err := io.ReadFull(input, data); err != nil {
+		return nil, err
+	}
+
+	header, err := parseRecordHeader(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data = append(data, make([]byte, header.length)...)
+
+	if _, err := io.ReadFull(input, data[RecordHeaderSize:]); err != nil {
+		return nil, err
+	}
+
+	return ParseRecord(data)
+}
+
+// the message carried by a proof record produced by FFSInputWrapper
+func ProofMessage(frame []byte) ([]byte, error) {
+	record, err := ParseRecord(frame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if record.content != ContentProof || len(record.fields) != 3 {
+		return nil, ErrContentType
+	}
+
+	return record.fields[2], nil
+}
@@ -0,0 +1,297 @@
+package enc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	ErrDEM        = errors.New("enc: unknown DEM")
+	ErrOpen       = errors.New("enc: message authentication failed")
+	ErrCommitment = errors.New("enc: ciphertext is committed to a different key")
+)
+
+// identifies the DEM that produced a ciphertext
+type DEMID uint16
+
+const (
+	DEMAESGCM     DEMID = 0x0001
+	DEMAESCTRHMAC DEMID = 0x0002
+	// AES-GCM under a session's counter nonces, stateful so DEMByID does not know it
+	DEMSession DEMID = 0x0003
+	// the AEAD of the HPKE suite named by the capsule
+	DEMHPKE DEMID = 0x0004
+	// set on the identifier of a DEM wrapped by CommittingDEM
+	DEMCommitting DEMID = 0x8000
+)
+
+var (
+	ctrHMACInfo    = []byte("zerocat aes-ctr hmac-sha256 keys")
+	commitKeyInfo  = []byte("zerocat committed dem key")
+	commitmentInfo = []byte("zerocat key commitment")
+)
+
+// data encapsulation: authenticated encryption under a key from a KEM, the additional data is
+// authenticated but not encrypted
+type DEM interface {
+	Algorithm() DEMID
+	Seal(key, plaintext, additional []byte) ([]byte, error)
+	Open(key, ciphertext, additional []byte) ([]byte, error)
+}
+
+// looks up a DEM by its identifier
+func DEMByID(id DEMID) (DEM, error) {
+	if id&DEMCommitting != 0 {
+		inner, err := DEMByID(id &^ DEMCommitting)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return NewCommittingDEM(inner), nil
+	}
+
+	switch id {
+	case DEMAESGCM:
+		return NewGCMDEM(), nil
+	case DEMAESCTRHMAC:
+		return NewCTRHMACDEM(), nil
+	}
+
+	return nil, ErrDEM
+}
+
+// AES-GCM with a random nonce prefixed to the ciphertext. GCM is not key-committing, a ciphertext
+// can be crafted to open under two different keys
+type GCMDEM struct {
+	random io.Reader
+}
+
+func NewGCMDEM() *GCMDEM {
+	dem := new(GCMDEM)
+	dem.random = rand.Reader
+
+	return dem
+}
+
+func (dem *GCMDEM) Algorithm() DEMID {
+	return DEMAESGCM
+}
+
+// returns nonce || ciphertext, the plaintext is left untouched
+func (dem *GCMDEM) Seal(key, plaintext, additional []byte) ([]byte, error) {
+	return dem.sealTo(make([]byte, 0, gcmStandardNonceSize+len(plaintext)+gcmTagSize), key, plaintext, additional)
+}
+
+func (dem *GCMDEM) Open(key, ciphertext, additional []byte) ([]byte, error) {
+	return dem.openTo(nil, key, ciphertext, additional)
+}
+
+// like Seal but appends nonce || ciphertext to dst
+func (dem *GCMDEM) sealTo(dst, key, plaintext, additional []byte) ([]byte, error) {
+	stream, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := len(dst)
+	dst = append(dst, make([]byte, stream.NonceSize())...)
+	nonce := dst[start:]
+	_, err = io.ReadFull(dem.random, nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Seal(dst, nonce, plaintext, additional), nil
+}
+
+// like Open but appends the plaintext to dst
+func (dem *GCMDEM) openTo(dst, key, ciphertext, additional []byte) ([]byte, error) {
+	stream, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < stream.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := stream.Open(dst, ciphertext[:stream.NonceSize()], ciphertext[stream.NonceSize():], additional)
+
+	if err != nil {
+		return nil, ErrOpen
+	}
+
+	return plaintext, nil
+}
+
+// AES-CTR then HMAC-SHA256 over the additional data, iv and ciphertext. the encryption and MAC keys are
+// split from the key with HKDF, and the MAC commits to the key
+type CTRHMACDEM struct {
+	random io.Reader
+}
+
+func NewCTRHMACDEM() *CTRHMACDEM {
+	dem := new(CTRHMACDEM)
+	dem.random = rand.Reader
+
+	return dem
+}
+
+func (dem *CTRHMACDEM) Algorithm() DEMID {
+	return DEMAESCTRHMAC
+}
+
+func (dem *CTRHMACDEM) keys(key []byte) ([]byte, []byte, error) {
+	keys, err := HKDF(nil, key, ctrHMACInfo, 64)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keys[:32], keys[32:], nil
+}
+
+// HMAC(len(additional) || additional || iv || ciphertext)
+func ctrTag(mac_key, additional, body []byte) []byte {
+	mac := hmac.New(sha256.New, mac_key)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(additional))))
+	mac.Write(additional)
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+// returns iv || ciphertext || tag
+func (dem *CTRHMACDEM) Seal(key, plaintext, additional []byte) ([]byte, error) {
+	encryption_key, mac_key, err := dem.keys(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(encryption_key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, aes.BlockSize+len(plaintext), aes.BlockSize+len(plaintext)+sha256.Size)
+	_, err = io.ReadFull(dem.random, output[:aes.BlockSize])
+
+	if err != nil {
+		return nil, err
+	}
+
+	cipher.NewCTR(block, output[:aes.BlockSize]).XORKeyStream(output[aes.BlockSize:], plaintext)
+
+	return append(output, ctrTag(mac_key, additional, output)...), nil
+}
+
+func (dem *CTRHMACDEM) Open(key, ciphertext, additional []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize+sha256.Size {
+		return nil, ErrMalformed
+	}
+
+	encryption_key, mac_key, err := dem.keys(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, tag := ciphertext[:len(ciphertext)-sha256.Size], ciphertext[len(ciphertext)-sha256.Size:]
+
+	if !hmac.Equal(tag, ctrTag(mac_key, additional, body)) {
+		return nil, ErrOpen
+	}
+
+	block, err := aes.NewCipher(encryption_key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(body)-aes.BlockSize)
+	cipher.NewCTR(block, body[:aes.BlockSize]).XORKeyStream(plaintext, body[aes.BlockSize:])
+
+	return plaintext, nil
+}
+
+// makes any DEM key-committing by prefixing a commitment to the key and running the inner DEM under a
+// key derived separately from that commitment
+type CommittingDEM struct {
+	inner DEM
+}
+
+func NewCommittingDEM(inner DEM) *CommittingDEM {
+	dem := new(CommittingDEM)
+	dem.inner = inner
+
+	return dem
+}
+
+func (dem *CommittingDEM) Algorithm() DEMID {
+	return DEMCommitting | dem.inner.Algorithm()
+}
+
+func (dem *CommittingDEM) keys(key []byte) ([]byte, []byte, error) {
+	inner_key, err := HKDF(nil, key, commitKeyInfo, len(key))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commitment, err := HKDF(nil, key, commitmentInfo, sha256.Size)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return inner_key, commitment, nil
+}
+
+// returns commitment || inner ciphertext
+func (dem *CommittingDEM) Seal(key, plaintext, additional []byte) ([]byte, error) {
+	inner_key, commitment, err := dem.keys(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := dem.inner.Seal(inner_key, plaintext, additional)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(commitment, ciphertext...), nil
+}
+
+// checks the commitment before anything is decrypted
+func (dem *CommittingDEM) Open(key, ciphertext, additional []byte) ([]byte, error) {
+	if len(ciphertext) < sha256.Size {
+		return nil, ErrMalformed
+	}
+
+	inner_key, commitment, err := dem.keys(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(commitment, ciphertext[:sha256.Size]) != 1 {
+		return nil, ErrCommitment
+	}
+
+	return dem.inner.Open(inner_key, ciphertext[sha256.Size:], additional)
+}
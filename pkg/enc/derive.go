@@ -11,25 +11,38 @@ type Deriver interface {
 
 // object for a derivation function based on SHA256
 type Sha256Deriver struct {
-	master []byte
+	master *Secret
 }
 
-// constructor for the Sha256 deriver
+// constructor for the Sha256 deriver, the master is copied so the caller can wipe its own
 func NewSha256Deriver(master []byte) *Sha256Deriver {
 	deriver := new(Sha256Deriver)
-	deriver.master = master
+	deriver.master = NewSecretFrom(master)
 
 	return deriver
 }
 
-// Derivation method returning a hash: H(capsule||master) where || denotes concatenation
+// Derivation method returning a hash: H(capsule||master) where || denotes concatenation,
+// nil once the deriver has been destroyed
 func (deriver *Sha256Deriver) Derive(capsule []byte) []byte {
-	key_material := make([]byte, 0)
-	key_material = append(key_material, capsule...)
-	key_material = append(key_material, deriver.master...)
+	return deriver.DeriveTo(nil, capsule)
+}
+
+// like Derive but appends the key to dst, the capsule and master are hashed in turn so no copy of the
+// master is made
+func (deriver *Sha256Deriver) DeriveTo(dst, capsule []byte) []byte {
+	if deriver.master.Destroyed() {
+		return nil
+	}
 
 	hash := sha256.New()
-	hash.Write(key_material)
+	hash.Write(capsule)
+	hash.Write(deriver.master.Bytes())
+
+	return hash.Sum(dst)
+}
 
-	return hash.Sum(nil)
+// wipes the master, nothing can be derived afterwards
+func (deriver *Sha256Deriver) Destroy() {
+	deriver.master.Destroy()
 }
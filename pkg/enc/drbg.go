@@ -0,0 +1,194 @@
+package enc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	// largest single request allowed by SP 800-90A for HMAC_DRBG
+	maxRequestSize = 1 << 16
+	// number of requests allowed between reseeds
+	reseedInterval = 1 << 48
+	// bytes of entropy needed for 256 bits of security
+	minEntropySize = 32
+)
+
+var (
+	ErrEntropy        = errors.New("drbg: not enough entropy input")
+	ErrRequestSize    = errors.New("drbg: request too large")
+	ErrReseedRequired = errors.New("drbg: reseed required")
+	ErrNoSource       = errors.New("drbg: prediction resistance needs an entropy source")
+)
+
+// HMAC_DRBG from NIST SP 800-90A instantiated with SHA-256. it is safe for concurrent use, each
+// request runs under a lock so goroutines sharing it as an io.Reader never see the same output
+type HMACDRBG struct {
+	key        []byte
+	value      []byte
+	counter    uint64
+	source     io.Reader
+	prediction bool
+	lock       sync.Mutex
+}
+
+// instantiates the generator from entropy, a nonce and an optional personalization string
+func NewHMACDRBG(entropy, nonce, personalization []byte) (*HMACDRBG, error) {
+	if len(entropy) < minEntropySize {
+		return nil, ErrEntropy
+	}
+
+	drbg := new(HMACDRBG)
+	drbg.key = make([]byte, sha256.Size)
+	drbg.value = make([]byte, sha256.Size)
+
+	for i := 0; i < len(drbg.value); i++ {
+		drbg.value[i] = 0x01
+	}
+
+	seed := make([]byte, 0, len(entropy)+len(nonce)+len(personalization))
+	seed = append(seed, entropy...)
+	seed = append(seed, nonce...)
+	seed = append(seed, personalization...)
+
+	drbg.update(seed)
+	drbg.counter = 1
+
+	return drbg, nil
+}
+
+// sets an entropy source used to reseed automatically once the reseed interval runs out,
+// with prediction resistance every request is preceded by a reseed from the source
+func (drbg *HMACDRBG) SetSource(source io.Reader, prediction bool) {
+	drbg.lock.Lock()
+	defer drbg.lock.Unlock()
+
+	drbg.source = source
+	drbg.prediction = prediction
+}
+
+func (drbg *HMACDRBG) hmac(data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, drbg.key)
+
+	for _, part := range data {
+		mac.Write(part)
+	}
+
+	return mac.Sum(nil)
+}
+
+// the HMAC_DRBG update function
+func (drbg *HMACDRBG) update(provided []byte) {
+	drbg.key = drbg.hmac(drbg.value, []byte{0x00}, provided)
+	drbg.value = drbg.hmac(drbg.value)
+
+	if len(provided) == 0 {
+		return
+	}
+
+	drbg.key = drbg.hmac(drbg.value, []byte{0x01}, provided)
+	drbg.value = drbg.hmac(drbg.value)
+}
+
+// mixes fresh entropy and optional additional input into the state
+func (drbg *HMACDRBG) Reseed(entropy, additional []byte) error {
+	drbg.lock.Lock()
+	defer drbg.lock.Unlock()
+
+	return drbg.reseed(entropy, additional)
+}
+
+func (drbg *HMACDRBG) reseed(entropy, additional []byte) error {
+	if len(entropy) < minEntropySize {
+		return ErrEntropy
+	}
+
+	seed := make([]byte, 0, len(entropy)+len(additional))
+	seed = append(seed, entropy...)
+	seed = append(seed, additional...)
+
+	drbg.update(seed)
+	drbg.counter = 1
+
+	return nil
+}
+
+func (drbg *HMACDRBG) reseedFromSource(additional []byte) error {
+	if drbg.source == nil {
+		return ErrNoSource
+	}
+
+	entropy := make([]byte, minEntropySize)
+
+	if _, err := io.ReadFull(drbg.source, entropy); err != nil {
+		return err
+	}
+
+	return drbg.reseed(entropy, additional)
+}
+
+// fills output with pseudorandom bytes, additional input is optional
+func (drbg *HMACDRBG) Generate(output, additional []byte) error {
+	drbg.lock.Lock()
+	defer drbg.lock.Unlock()
+
+	return drbg.generate(output, additional)
+}
+
+func (drbg *HMACDRBG) generate(output, additional []byte) error {
+	if len(output) > maxRequestSize {
+		return ErrRequestSize
+	}
+
+	if drbg.prediction || drbg.counter > reseedInterval {
+		if drbg.source == nil && drbg.counter > reseedInterval {
+			return ErrReseedRequired
+		}
+
+		if err := drbg.reseedFromSource(additional); err != nil {
+			return err
+		}
+
+		// the additional input has been consumed by the reseed
+		additional = nil
+	}
+
+	if len(additional) != 0 {
+		drbg.update(additional)
+	}
+
+	for filled := 0; filled < len(output); {
+		drbg.value = drbg.hmac(drbg.value)
+		filled += copy(output[filled:], drbg.value)
+	}
+
+	drbg.update(additional)
+	drbg.counter++
+
+	return nil
+}
+
+// implements io.Reader so the generator can stand in for crypto/rand.Reader
+func (drbg *HMACDRBG) Read(p []byte) (int, error) {
+	drbg.lock.Lock()
+	defer drbg.lock.Unlock()
+
+	for read := 0; read < len(p); {
+		end := read + maxRequestSize
+
+		if end > len(p) {
+			end = len(p)
+		}
+
+		if err := drbg.generate(p[read:end], nil); err != nil {
+			return read, err
+		}
+
+		read = end
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,231 @@
+package enc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	ErrCurve        = errors.New("enc: unknown curve")
+	ErrNoPrivateKey = errors.New("enc: decapsulation needs the recipient's private key")
+)
+
+var ecdhInfo = []byte("zerocat ecdh kem")
+
+// looks up a curve by the name used in key files
+func CurveByName(name string) (ecdh.Curve, error) {
+	switch name {
+	case "x25519":
+		return ecdh.X25519(), nil
+	case "p256":
+		return ecdh.P256(), nil
+	}
+
+	return nil, ErrCurve
+}
+
+// the name used for a curve in key files
+func CurveName(curve ecdh.Curve) string {
+	switch curve {
+	case ecdh.X25519():
+		return "x25519"
+	case ecdh.P256():
+		return "p256"
+	}
+
+	return ""
+}
+
+// an asymmetric key encapsulator, every message gets a fresh ephemeral key pair whose public key is the
+// capsule and the key is derived from its diffie-hellman secret with the recipient's static key
+type ECDHEncapsulator struct {
+	recipient *ecdh.PublicKey
+	private   *ecdh.PrivateKey
+	random    io.Reader
+}
+
+// constructor for the sending side, which only needs the recipient's public key
+func NewECDHEncapsulator(recipient *ecdh.PublicKey) *ECDHEncapsulator {
+	return NewECDHEncapsulatorFrom(recipient, rand.Reader)
+}
+
+// constructor for the sending side drawing nonces and ephemeral keys from the given source of randomness
+func NewECDHEncapsulatorFrom(recipient *ecdh.PublicKey, random io.Reader) *ECDHEncapsulator {
+	encapsulator := new(ECDHEncapsulator)
+	encapsulator.recipient = recipient
+	encapsulator.random = random
+
+	return encapsulator
+}
+
+// constructor for the receiving side, which can also encapsulate to itself
+func NewECDHDecapsulator(private *ecdh.PrivateKey) *ECDHEncapsulator {
+	encapsulator := NewECDHEncapsulator(private.PublicKey())
+	encapsulator.private = private
+
+	return encapsulator
+}
+
+// generates a static key pair for a recipient
+func ECDHKeyPair(curve ecdh.Curve) (*ecdh.PrivateKey, error) {
+	return curve.GenerateKey(rand.Reader)
+}
+
+// identifies the recipient's public key
+func (encapsulator *ECDHEncapsulator) KeyID() []byte {
+	return publicKeyID(encapsulator.recipient.Bytes())
+}
+
+// key = HKDF(shared, info || capsule || recipient)
+func (encapsulator *ECDHEncapsulator) deriveKey(shared, capsule []byte) ([]byte, error) {
+	info := make([]byte, 0)
+	info = append(info, ecdhInfo...)
+	info = append(info, capsule...)
+	info = append(info, encapsulator.recipient.Bytes()...)
+
+	return HKDF(nil, shared, info, 32)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypts under the key with a random nonce, the plaintext is left untouched
+func sealGCM(key, plaintext []byte, random io.Reader) ([]byte, []byte, error) {
+	stream, err := newGCM(key)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, stream.NonceSize())
+	_, err = io.ReadFull(random, nonce)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stream.Seal(nil, nonce, plaintext, nil), nonce, nil
+}
+
+// decrypts under the key, the ciphertext is left untouched
+func openGCM(key, ciphertext, nonce []byte) ([]byte, error) {
+	stream, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Open(nil, nonce, ciphertext, nil)
+}
+
+// generates an ephemeral key pair and returns its diffie-hellman secret with the recipient and its public key
+func ecdhEncap(recipient *ecdh.PublicKey, random io.Reader) ([]byte, []byte, error) {
+	ephemeral, err := recipient.Curve().GenerateKey(random)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared, err := ephemeral.ECDH(recipient)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return shared, ephemeral.PublicKey().Bytes(), nil
+}
+
+// recovers the diffie-hellman secret from an ephemeral public key
+func ecdhDecap(private *ecdh.PrivateKey, capsule []byte) ([]byte, error) {
+	ephemeral, err := private.Curve().NewPublicKey(capsule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return private.ECDH(ephemeral)
+}
+
+func (encapsulator *ECDHEncapsulator) Algorithm() KEMID {
+	return KEMECDH
+}
+
+// generates an ephemeral key pair, the key comes from its secret with the recipient and the capsule is its public key
+func (encapsulator *ECDHEncapsulator) EncapsulateKey() ([]byte, *Capsule, error) {
+	shared, ephemeral, err := ecdhEncap(encapsulator.recipient, encapsulator.random)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := encapsulator.deriveKey(shared, ephemeral)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, NewCapsule(KEMECDH, publicKeyID(encapsulator.recipient.Bytes()), ephemeral), nil
+}
+
+// recovers the key from the ephemeral public key using the static private key
+func (encapsulator *ECDHEncapsulator) DecapsulateKey(capsule *Capsule) ([]byte, error) {
+	if encapsulator.private == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	if capsule.kem != KEMECDH {
+		return nil, ErrKEM
+	}
+
+	if !bytes.Equal(capsule.key_id, publicKeyID(encapsulator.recipient.Bytes())) {
+		return nil, ErrKeyID
+	}
+
+	shared, err := ecdhDecap(encapsulator.private, capsule.data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return encapsulator.deriveKey(shared, capsule.data)
+}
+
+// takes plaintext and outputs ciphertext along with the ephemeral public key and nonce
+func (encapsulator *ECDHEncapsulator) Encapsulate(plaintext []byte) ([]byte, []byte, []byte, error) {
+	key, capsule, err := encapsulator.EncapsulateKey()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ciphertext, nonce, err := sealGCM(key, plaintext, encapsulator.random)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ciphertext, capsule.data, nonce, nil
+}
+
+// recovers the key from the ephemeral public key using the static private key and decrypts the message
+func (encapsulator *ECDHEncapsulator) Decrypt(ciphertext []byte, capsule []byte, nonce []byte) ([]byte, error) {
+	key, err := encapsulator.DecapsulateKey(NewCapsule(KEMECDH, publicKeyID(encapsulator.recipient.Bytes()), capsule))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return openGCM(key, ciphertext, nonce)
+}
@@ -1,13 +1,22 @@
 package enc
 
 import (
-	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"io"
+)
+
+// the nonce and tag sizes of AES-GCM as used throughout this package
+const (
+	gcmStandardNonceSize = 12
+	gcmTagSize           = 16
 )
 
 // the heart of the Key Encapsulation Mechanism, this generates a cryptographically secure random number
 // and uses it to derive a key returning the material that encapsulates the key and the encrypted plaintext
+//
+// Deprecated: the four values carry no algorithm identifiers or additional data. use a Sealer such as
+// KEMDEM, the encapsulators implement KEM for it
 type Encapsulator interface {
 	Encapsulate([]byte) ([]byte, []byte, []byte, error)
 	Decrypt([]byte, []byte, []byte) ([]byte, error)
@@ -15,73 +24,206 @@ type Encapsulator interface {
 
 // An encapsulator that utilizes AES for encryption
 type AESEncapsulator struct {
-	deriver Deriver
+	deriver   Deriver
+	random    io.Reader
+	destroyed bool
 }
 
 // Constructor for AES key encapsulator, takes in any type of key derivation function
 func NewAESEncapsulator(deriver Deriver) *AESEncapsulator {
+	return NewAESEncapsulatorFrom(deriver, rand.Reader)
+}
+
+// Constructor for AES key encapsulator drawing capsules and nonces from the given source of randomness
+func NewAESEncapsulatorFrom(deriver Deriver, random io.Reader) *AESEncapsulator {
 	encapsulator := new(AESEncapsulator)
 	encapsulator.deriver = deriver
+	encapsulator.random = random
 
 	return encapsulator
 }
 
-// takes plaintext and outputs ciphertext along with material encapsulating the key
-func (encapsulator *AESEncapsulator) Encapsulate(plaintext []byte) ([]byte, []byte, []byte, error) {
-	randomness := make([]byte, 32)
-	_, err := rand.Read(randomness)
+// derivers that can append the key to a caller's buffer
+type bufferedDeriver interface {
+	DeriveTo(dst, capsule []byte) []byte
+}
 
-	if err != nil {
-		return nil, nil, nil, err
+// derives a key, failing once the encapsulator or its deriver has been destroyed
+func (encapsulator *AESEncapsulator) derive(capsule []byte) ([]byte, error) {
+	return encapsulator.deriveTo(nil, capsule)
+}
+
+// like derive but appends the key to dst
+func (encapsulator *AESEncapsulator) deriveTo(dst, capsule []byte) ([]byte, error) {
+	if encapsulator.destroyed {
+		return nil, ErrDestroyed
 	}
 
-	key := encapsulator.deriver.Derive(randomness)
+	var key []byte
 
-	block, err := aes.NewCipher(key)
+	if buffered, ok := encapsulator.deriver.(bufferedDeriver); ok {
+		key = buffered.DeriveTo(dst, capsule)
+	} else if derived := encapsulator.deriver.Derive(capsule); derived != nil {
+		key = append(dst, derived...)
+		Wipe(derived)
+	}
 
-	if err != nil {
-		return nil, nil, nil, err
+	if key == nil {
+		return nil, ErrDestroyed
 	}
 
-	stream, err := cipher.NewGCM(block)
+	return key, nil
+}
+
+// destroys the deriver if it holds key material, the encapsulator can not be used afterwards
+func (encapsulator *AESEncapsulator) Destroy() {
+	if deriver, ok := encapsulator.deriver.(Destroyer); ok {
+		deriver.Destroy()
+	}
+
+	encapsulator.destroyed = true
+}
+
+func (encapsulator *AESEncapsulator) Algorithm() KEMID {
+	return KEMSymmetric
+}
+
+// a capsule and the randomness it carries, allocated together
+type aesCapsule struct {
+	capsule    Capsule
+	randomness [AESCapsuleSize]byte
+}
+
+// draws a random capsule and derives the key from it
+func (encapsulator *AESEncapsulator) EncapsulateKey() ([]byte, *Capsule, error) {
+	return encapsulator.encapsulateKeyTo(nil)
+}
+
+// like EncapsulateKey but appends the key to dst
+func (encapsulator *AESEncapsulator) encapsulateKeyTo(dst []byte) ([]byte, *Capsule, error) {
+	if encapsulator.destroyed {
+		return nil, nil, ErrDestroyed
+	}
+
+	block := new(aesCapsule)
+	_, err := io.ReadFull(encapsulator.random, block.randomness[:])
 
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
-	nonce := make([]byte, stream.NonceSize())
-	_, err = rand.Read(nonce)
+	key, err := encapsulator.deriveTo(dst, block.randomness[:])
 
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, err
 	}
 
-	ciphertext := stream.Seal(plaintext[:0], nonce, plaintext, nil)
+	block.capsule.Reset(KEMSymmetric, nil, block.randomness[:])
 
-	return ciphertext, randomness, nonce, nil
+	return key, &block.capsule, nil
 }
 
-// takes ciphertext and key material to decrypt the message
-func (encapsulator *AESEncapsulator) Decrypt(ciphertext []byte, capsule []byte, nonce []byte) ([]byte, error) {
-	key := encapsulator.deriver.Derive(capsule)
+// derives the key from the capsule
+func (encapsulator *AESEncapsulator) DecapsulateKey(capsule *Capsule) ([]byte, error) {
+	return encapsulator.decapsulateKeyTo(nil, capsule)
+}
+
+// like DecapsulateKey but appends the key to dst
+func (encapsulator *AESEncapsulator) decapsulateKeyTo(dst []byte, capsule *Capsule) ([]byte, error) {
+	if capsule.kem != KEMSymmetric {
+		return nil, ErrKEM
+	}
+
+	if len(capsule.data) != AESCapsuleSize {
+		return nil, ErrCapsuleSize
+	}
 
-	block, err := aes.NewCipher(key)
+	return encapsulator.deriveTo(dst, capsule.data)
+}
+
+// size of the random capsule the AES encapsulator draws for every message
+const AESCapsuleSize = 32
+
+func (encapsulator *AESEncapsulator) CapsuleSize() int {
+	return AESCapsuleSize
+}
+
+func (encapsulator *AESEncapsulator) NonceSize() int {
+	return gcmStandardNonceSize
+}
+
+// builds the cipher for the key derived from the capsule
+func (encapsulator *AESEncapsulator) aead(capsule []byte) (cipher.AEAD, error) {
+	key, err := encapsulator.derive(capsule)
 
 	if err != nil {
 		return nil, err
 	}
 
-	stream, err := cipher.NewGCM(block)
+	defer Wipe(key)
+
+	return newGCM(key)
+}
+
+// fills the capsule and nonce with fresh randomness and appends the ciphertext to dst. capsule and nonce
+// must be CapsuleSize and NonceSize long and may point into dst[:len(dst)], plaintext may be dst[len(dst):]
+// to encrypt in place but must not otherwise overlap dst
+func (encapsulator *AESEncapsulator) SealTo(dst, capsule, nonce, plaintext []byte) ([]byte, error) {
+	if encapsulator.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	if len(capsule) != AESCapsuleSize || len(nonce) != gcmStandardNonceSize {
+		return nil, ErrCapsuleSize
+	}
+
+	if _, err := io.ReadFull(encapsulator.random, capsule); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(encapsulator.random, nonce); err != nil {
+		return nil, err
+	}
+
+	stream, err := encapsulator.aead(capsule)
 
 	if err != nil {
 		return nil, err
 	}
 
-	plaintext, err := stream.Open(ciphertext[:0], nonce, ciphertext, nil)
+	return stream.Seal(dst, nonce, plaintext, nil), nil
+}
+
+// appends the plaintext to dst. ciphertext[:0] may be passed as dst to decrypt in place, otherwise dst
+// must not overlap the ciphertext
+func (encapsulator *AESEncapsulator) OpenTo(dst, ciphertext, capsule, nonce []byte) ([]byte, error) {
+	if len(nonce) != gcmStandardNonceSize {
+		return nil, ErrCapsuleSize
+	}
+
+	stream, err := encapsulator.aead(capsule)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return plaintext, nil
+	return stream.Open(dst, nonce, ciphertext, nil)
+}
+
+// takes plaintext and outputs ciphertext along with material encapsulating the key, the plaintext is left untouched
+func (encapsulator *AESEncapsulator) Encapsulate(plaintext []byte) ([]byte, []byte, []byte, error) {
+	capsule := make([]byte, AESCapsuleSize)
+	nonce := make([]byte, gcmStandardNonceSize)
+	ciphertext, err := encapsulator.SealTo(nil, capsule, nonce, plaintext)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ciphertext, capsule, nonce, nil
+}
+
+// takes ciphertext and key material to decrypt the message, the ciphertext is left untouched
+func (encapsulator *AESEncapsulator) Decrypt(ciphertext []byte, capsule []byte, nonce []byte) ([]byte, error) {
+	return encapsulator.OpenTo(nil, ciphertext, capsule, nonce)
 }
@@ -0,0 +1,355 @@
+package enc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// size of the random content key that encrypts an envelope's body
+const contentKeySize = 32
+
+var (
+	ErrNoRecipient        = errors.New("enc: not a recipient of the envelope")
+	ErrDuplicateRecipient = errors.New("enc: recipient already holds the envelope")
+	ErrNotCommitting      = errors.New("enc: envelope body is not sealed with a committing DEM")
+)
+
+var envelopeWrapInfo = []byte("zerocat envelope key wrap")
+
+// a key holder for an envelope: a KEM and the id that tags its wrapped key
+type Recipient struct {
+	kem    KEM
+	key_id []byte
+}
+
+// builds a recipient, a nil key id is taken from the KEM's public key. symmetric KEMs have no public key
+// so they must be given one
+func NewRecipient(kem KEM, key_id []byte) (*Recipient, error) {
+	if key_id == nil {
+		identified, ok := kem.(interface{ KeyID() []byte })
+
+		if !ok {
+			return nil, ErrKeyID
+		}
+
+		key_id = identified.KeyID()
+	}
+
+	recipient := new(Recipient)
+	recipient.kem = kem
+	recipient.key_id = key_id
+
+	return recipient, nil
+}
+
+func (recipient *Recipient) KeyID() []byte {
+	return recipient.key_id
+}
+
+// the content key wrapped for one recipient
+type RecipientStanza struct {
+	key_id  []byte
+	capsule *Capsule
+	wrapped []byte
+}
+
+func (stanza *RecipientStanza) KeyID() []byte {
+	return stanza.key_id
+}
+
+// a payload encrypted once under a random content key, with that key wrapped separately for every
+// recipient. recipients can be added and removed without touching the body, but a removed recipient
+// that kept the content key can still read it. the body is always sealed with a CommittingDEM, without
+// one a dishonest sender could give recipients keys that open it to different plaintexts
+type Envelope struct {
+	dem        DEM
+	stanzas    []*RecipientStanza
+	ciphertext []byte
+}
+
+// the body's additional data is the DEM id followed by the caller's additional data
+func (envelope *Envelope) bodyAdditional(additional []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(envelope.dem.Algorithm())), additional...)
+}
+
+// a wrapped key is bound to its key id, its capsule and a digest of the body so it can not be moved
+// to another recipient or envelope
+func (envelope *Envelope) wrapAdditional(key_id []byte, capsule *Capsule) []byte {
+	digest := sha256.Sum256(envelope.ciphertext)
+	additional := make([]byte, 0)
+
+	for _, field := range [][]byte{envelopeWrapInfo, key_id, capsule.Marshal(), digest[:]} {
+		additional = AppendField(additional, field)
+	}
+
+	return additional
+}
+
+// encapsulates a fresh key to the recipient and encrypts the content key under it
+func (envelope *Envelope) wrap(recipient *Recipient, content_key []byte) (*RecipientStanza, error) {
+	key, capsule, err := recipient.kem.EncapsulateKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(key)
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	stanza := new(RecipientStanza)
+	stanza.key_id = recipient.key_id
+	stanza.capsule = capsule
+	stanza.wrapped = aead.Seal(nonce, nonce, content_key, envelope.wrapAdditional(recipient.key_id, capsule))
+
+	return stanza, nil
+}
+
+// recovers the content key from the recipient's stanza
+func (envelope *Envelope) unwrap(recipient *Recipient) ([]byte, error) {
+	for _, stanza := range envelope.stanzas {
+		if !bytes.Equal(stanza.key_id, recipient.key_id) {
+			continue
+		}
+
+		key, err := recipient.kem.DecapsulateKey(stanza.capsule)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer Wipe(key)
+
+		aead, err := newGCM(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(stanza.wrapped) < aead.NonceSize() {
+			return nil, ErrMalformed
+		}
+
+		nonce, wrapped := stanza.wrapped[:aead.NonceSize()], stanza.wrapped[aead.NonceSize():]
+		content_key, err := aead.Open(nil, nonce, wrapped, envelope.wrapAdditional(stanza.key_id, stanza.capsule))
+
+		if err != nil {
+			return nil, ErrOpen
+		}
+
+		return content_key, nil
+	}
+
+	return nil, ErrNoRecipient
+}
+
+// encrypts the plaintext once and wraps its key for every recipient, a DEM that is not key-committing
+// is wrapped in a CommittingDEM
+func SealEnvelope(dem DEM, plaintext, additional []byte, recipients ...*Recipient) (*Envelope, error) {
+	if dem.Algorithm()&DEMCommitting == 0 {
+		dem = NewCommittingDEM(dem)
+	}
+
+	content_key := make([]byte, contentKeySize)
+
+	if _, err := io.ReadFull(rand.Reader, content_key); err != nil {
+		return nil, err
+	}
+
+	defer Wipe(content_key)
+
+	envelope := new(Envelope)
+	envelope.dem = dem
+
+	ciphertext, err := dem.Seal(content_key, plaintext, envelope.bodyAdditional(additional))
+
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.ciphertext = ciphertext
+
+	for _, recipient := range recipients {
+		if envelope.holds(recipient.key_id) {
+			return nil, ErrDuplicateRecipient
+		}
+
+		stanza, err := envelope.wrap(recipient, content_key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		envelope.stanzas = append(envelope.stanzas, stanza)
+	}
+
+	return envelope, nil
+}
+
+func (envelope *Envelope) holds(key_id []byte) bool {
+	for _, stanza := range envelope.stanzas {
+		if bytes.Equal(stanza.key_id, key_id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the encrypted body
+func (envelope *Envelope) Ciphertext() []byte {
+	return envelope.ciphertext
+}
+
+// the stanzas, one per recipient
+func (envelope *Envelope) Recipients() []*RecipientStanza {
+	return envelope.stanzas
+}
+
+// decrypts the body with the content key wrapped for the recipient
+func (envelope *Envelope) Open(recipient *Recipient, additional []byte) ([]byte, error) {
+	content_key, err := envelope.unwrap(recipient)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(content_key)
+
+	return envelope.dem.Open(content_key, envelope.ciphertext, envelope.bodyAdditional(additional))
+}
+
+// wraps the content key for a new recipient, using an existing holder to recover it
+func (envelope *Envelope) AddRecipient(holder, recipient *Recipient) error {
+	if envelope.holds(recipient.key_id) {
+		return ErrDuplicateRecipient
+	}
+
+	content_key, err := envelope.unwrap(holder)
+
+	if err != nil {
+		return err
+	}
+
+	defer Wipe(content_key)
+
+	stanza, err := envelope.wrap(recipient, content_key)
+
+	if err != nil {
+		return err
+	}
+
+	envelope.stanzas = append(envelope.stanzas, stanza)
+
+	return nil
+}
+
+// drops the recipient's stanza. the stanzas are copied so a slice returned by Recipients is left as it was
+func (envelope *Envelope) RemoveRecipient(key_id []byte) error {
+	for i, stanza := range envelope.stanzas {
+		if bytes.Equal(stanza.key_id, key_id) {
+			stanzas := make([]*RecipientStanza, 0, len(envelope.stanzas)-1)
+			stanzas = append(stanzas, envelope.stanzas[:i]...)
+			envelope.stanzas = append(stanzas, envelope.stanzas[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return ErrNoRecipient
+}
+
+// encodes the envelope as the DEM id, the stanza count, each stanza's length prefixed key id, capsule
+// and wrapped key, then the length prefixed body
+func (envelope *Envelope) Marshal() []byte {
+	output := binary.BigEndian.AppendUint16(nil, uint16(envelope.dem.Algorithm()))
+	output = binary.BigEndian.AppendUint32(output, uint32(len(envelope.stanzas)))
+
+	for _, stanza := range envelope.stanzas {
+		output = AppendField(output, stanza.key_id)
+		output = AppendField(output, stanza.capsule.Marshal())
+		output = AppendField(output, stanza.wrapped)
+	}
+
+	return AppendField(output, envelope.ciphertext)
+}
+
+// decodes an envelope produced by Marshal
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	if len(data) < 6 {
+		return nil, ErrMalformed
+	}
+
+	id := DEMID(binary.BigEndian.Uint16(data))
+
+	if id&DEMCommitting == 0 {
+		return nil, ErrNotCommitting
+	}
+
+	dem, err := DEMByID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	count := binary.BigEndian.Uint32(data[2:])
+	data = data[6:]
+
+	envelope := new(Envelope)
+	envelope.dem = dem
+
+	for i := uint32(0); i < count; i++ {
+		fields := make([][]byte, 3)
+
+		for j := range fields {
+			fields[j], data, err = ReadField(data)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		capsule, err := ParseCapsule(fields[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		// a planted stanza ahead of a recipient's own would be the only one unwrap tries
+		if envelope.holds(fields[0]) {
+			return nil, ErrDuplicateRecipient
+		}
+
+		stanza := new(RecipientStanza)
+		stanza.key_id = fields[0]
+		stanza.capsule = capsule
+		stanza.wrapped = fields[2]
+		envelope.stanzas = append(envelope.stanzas, stanza)
+	}
+
+	envelope.ciphertext, data, err = ReadField(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) != 0 {
+		return nil, ErrMalformed
+	}
+
+	return envelope, nil
+}
@@ -0,0 +1,28 @@
+package enc
+
+import (
+	"encoding/binary"
+)
+
+// appends the field prefixed with its length as a big endian uint32, the encoding every length
+// prefixed structure in zerocat uses
+func AppendField(dst, field []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(field)))
+	return append(dst, field...)
+}
+
+// reads a field written by AppendField, returning it and the rest of the data. the field points into the data
+func ReadField(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, ErrMalformed
+	}
+
+	length := binary.BigEndian.Uint32(data)
+	data = data[4:]
+
+	if uint64(len(data)) < uint64(length) {
+		return nil, nil, ErrMalformed
+	}
+
+	return data[:length], data[length:], nil
+}
@@ -0,0 +1,127 @@
+package enc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrHKDFLength = errors.New("hkdf: requested output too long")
+
+// largest output HKDF-Expand can produce with SHA256
+const maxHKDFLength = 255 * sha256.Size
+
+// HKDF-Extract from RFC 5869, an empty salt is treated as a string of zeros
+func HKDFExtract(salt, secret []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+
+	return mac.Sum(nil)
+}
+
+// HKDF-Expand from RFC 5869
+func HKDFExpand(pseudorandom, info []byte, length int) ([]byte, error) {
+	if length < 0 || length > maxHKDFLength {
+		return nil, ErrHKDFLength
+	}
+
+	mac := hmac.New(sha256.New, pseudorandom)
+	output := make([]byte, 0, length)
+	previous := make([]byte, 0)
+
+	// T(i) = HMAC(PRK, T(i - 1) || info || i)
+	for counter := byte(1); len(output) < length; counter++ {
+		mac.Reset()
+		mac.Write(previous)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		previous = mac.Sum(previous[:0])
+		output = append(output, previous...)
+	}
+
+	return output[:length], nil
+}
+
+// extract then expand
+func HKDF(salt, secret, info []byte, length int) ([]byte, error) {
+	return HKDFExpand(HKDFExtract(salt, secret), info, length)
+}
+
+// a deriver that extracts a pseudorandom key from the master once and expands every capsule
+// into keys bound to the info string and a label, so keys for different purposes are independent
+type HKDFDeriver struct {
+	pseudorandom []byte
+	info         []byte
+}
+
+// constructor for the HKDF deriver, salt and info may be empty
+func NewHKDFDeriver(master, salt, info []byte) *HKDFDeriver {
+	deriver := new(HKDFDeriver)
+	deriver.pseudorandom = HKDFExtract(salt, master)
+	deriver.info = info
+
+	return deriver
+}
+
+// the expand info is length prefixed info, label and capsule so no two combinations collide
+func (deriver *HKDFDeriver) expandInfo(label, capsule []byte) []byte {
+	info := make([]byte, 0, 12+len(deriver.info)+len(label)+len(capsule))
+
+	for _, field := range [][]byte{deriver.info, label, capsule} {
+		info = AppendField(info, field)
+	}
+
+	return info
+}
+
+// derives a key of the given length for one purpose from the capsule
+func (deriver *HKDFDeriver) DeriveKey(capsule, label []byte, length int) ([]byte, error) {
+	return HKDFExpand(deriver.pseudorandom, deriver.expandInfo(label, capsule), length)
+}
+
+// derives a 32 byte encryption key, satisfying Deriver
+func (deriver *HKDFDeriver) Derive(capsule []byte) []byte {
+	key, _ := deriver.DeriveKey(capsule, []byte("key"), 32)
+	return key
+}
+
+// encryption and MAC keys for each direction of a session
+type SessionKeys struct {
+	initiator_key []byte
+	initiator_mac []byte
+	responder_key []byte
+	responder_mac []byte
+}
+
+// key protecting traffic sent by the initiator
+func (keys *SessionKeys) InitiatorKey() []byte {
+	return keys.initiator_key
+}
+
+func (keys *SessionKeys) InitiatorMAC() []byte {
+	return keys.initiator_mac
+}
+
+// key protecting traffic sent by the responder
+func (keys *SessionKeys) ResponderKey() []byte {
+	return keys.responder_key
+}
+
+func (keys *SessionKeys) ResponderMAC() []byte {
+	return keys.responder_mac
+}
+
+// derives independent 32 byte encryption and MAC keys for both directions from one capsule
+func (deriver *HKDFDeriver) DeriveSessionKeys(capsule []byte) *SessionKeys {
+	keys := new(SessionKeys)
+	keys.initiator_key, _ = deriver.DeriveKey(capsule, []byte("initiator key"), 32)
+	keys.initiator_mac, _ = deriver.DeriveKey(capsule, []byte("initiator mac"), 32)
+	keys.responder_key, _ = deriver.DeriveKey(capsule, []byte("responder key"), 32)
+	keys.responder_mac, _ = deriver.DeriveKey(capsule, []byte("responder mac"), 32)
+
+	return keys
+}
@@ -0,0 +1,69 @@
+package hpke
+
+import (
+	"crypto/ecdh"
+	"errors"
+)
+
+var ErrNoPrivateKey = errors.New("hpke: decryption needs the recipient's private key")
+
+// adapts single-shot base mode HPKE to enc.Encapsulator, the capsule is the encapsulated key
+// and the nonce is always empty because HPKE derives it from the key schedule
+//
+// Deprecated: use Sealer, which also authenticates additional data
+type Encapsulator struct {
+	suite     *Suite
+	recipient *ecdh.PublicKey
+	private   *ecdh.PrivateKey
+	info      []byte
+}
+
+// constructor for the sending side
+func NewEncapsulator(suite *Suite, recipient *ecdh.PublicKey, info []byte) *Encapsulator {
+	encapsulator := new(Encapsulator)
+	encapsulator.suite = suite
+	encapsulator.recipient = recipient
+	encapsulator.info = info
+
+	return encapsulator
+}
+
+// constructor for the receiving side
+func NewDecapsulator(suite *Suite, private *ecdh.PrivateKey, info []byte) *Encapsulator {
+	encapsulator := NewEncapsulator(suite, private.PublicKey(), info)
+	encapsulator.private = private
+
+	return encapsulator
+}
+
+// seals the plaintext to the recipient in a fresh context
+func (encapsulator *Encapsulator) Encapsulate(plaintext []byte) ([]byte, []byte, []byte, error) {
+	encapsulated, context, err := encapsulator.suite.SetupBaseS(encapsulator.recipient, encapsulator.info)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ciphertext, err := context.Seal(plaintext, nil)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ciphertext, encapsulated, []byte{}, nil
+}
+
+// opens a message sealed by Encapsulate, the nonce is ignored
+func (encapsulator *Encapsulator) Decrypt(ciphertext []byte, capsule []byte, nonce []byte) ([]byte, error) {
+	if encapsulator.private == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	context, err := encapsulator.suite.SetupBaseR(capsule, encapsulator.private, encapsulator.info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return context.Open(ciphertext, nil)
+}
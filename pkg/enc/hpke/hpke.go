@@ -0,0 +1,452 @@
+package hpke
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// identifiers from the RFC 9180 registries
+type KEM uint16
+type KDF uint16
+type AEAD uint16
+type Mode uint8
+
+const (
+	KEMP256   KEM = 0x0010
+	KEMX25519 KEM = 0x0020
+
+	KDFHKDFSHA256 KDF = 0x0001
+
+	AEADAES128GCM  AEAD = 0x0001
+	AEADAES256GCM  AEAD = 0x0002
+	AEADExportOnly AEAD = 0xffff
+
+	ModeBase    Mode = 0x00
+	ModePSK     Mode = 0x01
+	ModeAuth    Mode = 0x02
+	ModeAuthPSK Mode = 0x03
+)
+
+var (
+	ErrSuite        = errors.New("hpke: unsupported suite")
+	ErrPSKInputs    = errors.New("hpke: psk and psk id must be given together and only in psk modes")
+	ErrDeriveKey    = errors.New("hpke: could not derive a key pair")
+	ErrMessageLimit = errors.New("hpke: message limit reached")
+	ErrExportOnly   = errors.New("hpke: suite can only export secrets")
+	ErrExportLength = errors.New("hpke: export length too long")
+	ErrOpen         = errors.New("hpke: message authentication failed")
+)
+
+var versionLabel = []byte("HPKE-v1")
+
+// size of the hash output, and therefore of the kem shared secret, for HKDF-SHA256
+const hashSize = 32
+
+// a KEM, KDF and AEAD combination
+type Suite struct {
+	kem    KEM
+	kdf    KDF
+	aead   AEAD
+	curve  ecdh.Curve
+	random io.Reader
+}
+
+// constructs a suite, only the DHKEMs over HKDF-SHA256 and the AES-GCM AEADs are supported
+func NewSuite(kem KEM, kdf KDF, aead AEAD) (*Suite, error) {
+	return NewSuiteFrom(rand.Reader, kem, kdf, aead)
+}
+
+// constructs a suite drawing ephemeral keys from the given source of randomness
+func NewSuiteFrom(random io.Reader, kem KEM, kdf KDF, aead AEAD) (*Suite, error) {
+	suite := new(Suite)
+	suite.kem = kem
+	suite.kdf = kdf
+	suite.aead = aead
+	suite.random = random
+
+	switch kem {
+	case KEMX25519:
+		suite.curve = ecdh.X25519()
+	case KEMP256:
+		suite.curve = ecdh.P256()
+	default:
+		return nil, ErrSuite
+	}
+
+	if kdf != KDFHKDFSHA256 {
+		return nil, ErrSuite
+	}
+
+	if aead != AEADAES128GCM && aead != AEADAES256GCM && aead != AEADExportOnly {
+		return nil, ErrSuite
+	}
+
+	return suite, nil
+}
+
+func (suite *Suite) Curve() ecdh.Curve {
+	return suite.curve
+}
+
+// the "KEM" || kem_id suite id used inside the DHKEM
+func (suite *Suite) kemID() []byte {
+	return binary.BigEndian.AppendUint16([]byte("KEM"), uint16(suite.kem))
+}
+
+// the "HPKE" || kem_id || kdf_id || aead_id suite id used by the key schedule
+func (suite *Suite) hpkeID() []byte {
+	id := []byte("HPKE")
+	id = binary.BigEndian.AppendUint16(id, uint16(suite.kem))
+	id = binary.BigEndian.AppendUint16(id, uint16(suite.kdf))
+
+	return binary.BigEndian.AppendUint16(id, uint16(suite.aead))
+}
+
+func labeledExtract(id []byte, salt []byte, label string, secret []byte) []byte {
+	labeled := make([]byte, 0)
+	labeled = append(labeled, versionLabel...)
+	labeled = append(labeled, id...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, secret...)
+
+	return enc.HKDFExtract(salt, labeled)
+}
+
+func labeledExpand(id []byte, pseudorandom []byte, label string, info []byte, length int) ([]byte, error) {
+	if length > 0xffff {
+		return nil, enc.ErrHKDFLength
+	}
+
+	labeled := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeled = append(labeled, versionLabel...)
+	labeled = append(labeled, id...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, info...)
+
+	return enc.HKDFExpand(pseudorandom, labeled, length)
+}
+
+// derives a key pair from input keying material as in section 7.1.3
+func (suite *Suite) DeriveKeyPair(secret []byte) (*ecdh.PrivateKey, error) {
+	id := suite.kemID()
+	pseudorandom := labeledExtract(id, nil, "dkp_prk", secret)
+
+	if suite.kem == KEMX25519 {
+		private, err := labeledExpand(id, pseudorandom, "sk", nil, 32)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return suite.curve.NewPrivateKey(private)
+	}
+
+	// rejection sample a scalar below the group order
+	for counter := 0; counter < 256; counter++ {
+		candidate, err := labeledExpand(id, pseudorandom, "candidate", []byte{byte(counter)}, 32)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if private, err := suite.curve.NewPrivateKey(candidate); err == nil {
+			return private, nil
+		}
+	}
+
+	return nil, ErrDeriveKey
+}
+
+// generates a key pair from the suite's source of randomness
+func (suite *Suite) GenerateKeyPair() (*ecdh.PrivateKey, error) {
+	secret := make([]byte, 32)
+
+	if _, err := io.ReadFull(suite.random, secret); err != nil {
+		return nil, err
+	}
+
+	return suite.DeriveKeyPair(secret)
+}
+
+func (suite *Suite) extractAndExpand(shared, context []byte) ([]byte, error) {
+	id := suite.kemID()
+	pseudorandom := labeledExtract(id, nil, "eae_prk", shared)
+
+	return labeledExpand(id, pseudorandom, "shared_secret", context, hashSize)
+}
+
+// the DHKEM encap and auth encap, the sender key is nil for the unauthenticated modes
+func (suite *Suite) encap(recipient *ecdh.PublicKey, sender *ecdh.PrivateKey) ([]byte, []byte, error) {
+	ephemeral, err := suite.GenerateKeyPair()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared, err := ephemeral.ECDH(recipient)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encapsulated := ephemeral.PublicKey().Bytes()
+	context := make([]byte, 0)
+	context = append(context, encapsulated...)
+	context = append(context, recipient.Bytes()...)
+
+	if sender != nil {
+		static, err := sender.ECDH(recipient)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		shared = append(shared, static...)
+		context = append(context, sender.PublicKey().Bytes()...)
+	}
+
+	secret, err := suite.extractAndExpand(shared, context)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return secret, encapsulated, nil
+}
+
+// the DHKEM decap and auth decap, the sender key is nil for the unauthenticated modes
+func (suite *Suite) decap(encapsulated []byte, recipient *ecdh.PrivateKey, sender *ecdh.PublicKey) ([]byte, error) {
+	ephemeral, err := suite.curve.NewPublicKey(encapsulated)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := recipient.ECDH(ephemeral)
+
+	if err != nil {
+		return nil, err
+	}
+
+	context := make([]byte, 0)
+	context = append(context, encapsulated...)
+	context = append(context, recipient.PublicKey().Bytes()...)
+
+	if sender != nil {
+		static, err := recipient.ECDH(sender)
+
+		if err != nil {
+			return nil, err
+		}
+
+		shared = append(shared, static...)
+		context = append(context, sender.Bytes()...)
+	}
+
+	return suite.extractAndExpand(shared, context)
+}
+
+func (suite *Suite) keySize() int {
+	switch suite.aead {
+	case AEADAES128GCM:
+		return 16
+	case AEADAES256GCM:
+		return 32
+	}
+
+	return 0
+}
+
+// the key schedule from section 5.1
+func (suite *Suite) keySchedule(mode Mode, shared, info, psk, psk_id []byte) (*Context, error) {
+	has_psk := len(psk) != 0
+
+	if has_psk != (len(psk_id) != 0) || has_psk != (mode == ModePSK || mode == ModeAuthPSK) {
+		return nil, ErrPSKInputs
+	}
+
+	id := suite.hpkeID()
+	schedule := []byte{byte(mode)}
+	schedule = append(schedule, labeledExtract(id, nil, "psk_id_hash", psk_id)...)
+	schedule = append(schedule, labeledExtract(id, nil, "info_hash", info)...)
+	secret := labeledExtract(id, shared, "secret", psk)
+
+	context := new(Context)
+	context.suite = suite
+
+	exporter, err := labeledExpand(id, secret, "exp", schedule, hashSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	context.exporter = exporter
+
+	if suite.aead == AEADExportOnly {
+		return context, nil
+	}
+
+	key, err := labeledExpand(id, secret, "key", schedule, suite.keySize())
+
+	if err != nil {
+		return nil, err
+	}
+
+	context.base_nonce, err = labeledExpand(id, secret, "base_nonce", schedule, 12)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	context.aead, err = cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return context, nil
+}
+
+func (suite *Suite) setupSender(mode Mode, recipient *ecdh.PublicKey, info, psk, psk_id []byte, sender *ecdh.PrivateKey) ([]byte, *Context, error) {
+	shared, encapsulated, err := suite.encap(recipient, sender)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	context, err := suite.keySchedule(mode, shared, info, psk, psk_id)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encapsulated, context, nil
+}
+
+func (suite *Suite) setupRecipient(mode Mode, encapsulated []byte, recipient *ecdh.PrivateKey, info, psk, psk_id []byte, sender *ecdh.PublicKey) (*Context, error) {
+	shared, err := suite.decap(encapsulated, recipient, sender)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return suite.keySchedule(mode, shared, info, psk, psk_id)
+}
+
+// sets up a sending context to the recipient, returning the encapsulated key to send along
+func (suite *Suite) SetupBaseS(recipient *ecdh.PublicKey, info []byte) ([]byte, *Context, error) {
+	return suite.setupSender(ModeBase, recipient, info, nil, nil, nil)
+}
+
+// sets up the receiving context from the encapsulated key
+func (suite *Suite) SetupBaseR(encapsulated []byte, recipient *ecdh.PrivateKey, info []byte) (*Context, error) {
+	return suite.setupRecipient(ModeBase, encapsulated, recipient, info, nil, nil, nil)
+}
+
+// sets up a sending context that also authenticates a pre-shared key
+func (suite *Suite) SetupPSKS(recipient *ecdh.PublicKey, info, psk, psk_id []byte) ([]byte, *Context, error) {
+	return suite.setupSender(ModePSK, recipient, info, psk, psk_id, nil)
+}
+
+func (suite *Suite) SetupPSKR(encapsulated []byte, recipient *ecdh.PrivateKey, info, psk, psk_id []byte) (*Context, error) {
+	return suite.setupRecipient(ModePSK, encapsulated, recipient, info, psk, psk_id, nil)
+}
+
+// sets up a sending context authenticated by the sender's static key
+func (suite *Suite) SetupAuthS(recipient *ecdh.PublicKey, info []byte, sender *ecdh.PrivateKey) ([]byte, *Context, error) {
+	return suite.setupSender(ModeAuth, recipient, info, nil, nil, sender)
+}
+
+func (suite *Suite) SetupAuthR(encapsulated []byte, recipient *ecdh.PrivateKey, info []byte, sender *ecdh.PublicKey) (*Context, error) {
+	return suite.setupRecipient(ModeAuth, encapsulated, recipient, info, nil, nil, sender)
+}
+
+// sets up a sending context authenticated by both a pre-shared key and the sender's static key
+func (suite *Suite) SetupAuthPSKS(recipient *ecdh.PublicKey, info, psk, psk_id []byte, sender *ecdh.PrivateKey) ([]byte, *Context, error) {
+	return suite.setupSender(ModeAuthPSK, recipient, info, psk, psk_id, sender)
+}
+
+func (suite *Suite) SetupAuthPSKR(encapsulated []byte, recipient *ecdh.PrivateKey, info, psk, psk_id []byte, sender *ecdh.PublicKey) (*Context, error) {
+	return suite.setupRecipient(ModeAuthPSK, encapsulated, recipient, info, psk, psk_id, sender)
+}
+
+// an encryption context, messages must be opened in the order they were sealed since every
+// message uses the next sequence number in its nonce
+type Context struct {
+	suite      *Suite
+	aead       cipher.AEAD
+	base_nonce []byte
+	sequence   uint64
+	exporter   []byte
+}
+
+// base_nonce xor the big-endian sequence number
+func (context *Context) nonce() []byte {
+	nonce := make([]byte, len(context.base_nonce))
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], context.sequence)
+
+	for i := 0; i < len(nonce); i++ {
+		nonce[i] ^= context.base_nonce[i]
+	}
+
+	return nonce
+}
+
+// encrypts the next message, the plaintext is left untouched
+func (context *Context) Seal(plaintext, additional []byte) ([]byte, error) {
+	if context.aead == nil {
+		return nil, ErrExportOnly
+	}
+
+	if context.sequence == ^uint64(0) {
+		return nil, ErrMessageLimit
+	}
+
+	ciphertext := context.aead.Seal(nil, context.nonce(), plaintext, additional)
+	context.sequence++
+
+	return ciphertext, nil
+}
+
+// decrypts the next message
+func (context *Context) Open(ciphertext, additional []byte) ([]byte, error) {
+	if context.aead == nil {
+		return nil, ErrExportOnly
+	}
+
+	if context.sequence == ^uint64(0) {
+		return nil, ErrMessageLimit
+	}
+
+	plaintext, err := context.aead.Open(nil, context.nonce(), ciphertext, additional)
+
+	if err != nil {
+		return nil, ErrOpen
+	}
+
+	context.sequence++
+
+	return plaintext, nil
+}
+
+// derives a secret of the given length bound to the exporter context
+func (context *Context) Export(exporter_context []byte, length int) ([]byte, error) {
+	if length > 255*hashSize {
+		return nil, ErrExportLength
+	}
+
+	return labeledExpand(context.suite.hpkeID(), context.exporter, "sec", exporter_context, length)
+}
@@ -0,0 +1,82 @@
+package hpke
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+var ErrCapsuleSuite = errors.New("hpke: capsule is for a different suite")
+
+// adapts single-shot base mode HPKE to enc.Sealer. the capsule data is the encapsulated key and its key
+// id is the suite id, the additional data is authenticated by the suite's AEAD
+type Sealer struct {
+	suite     *Suite
+	recipient *ecdh.PublicKey
+	private   *ecdh.PrivateKey
+	info      []byte
+}
+
+// constructor for the sending side
+func NewSealer(suite *Suite, recipient *ecdh.PublicKey, info []byte) *Sealer {
+	sealer := new(Sealer)
+	sealer.suite = suite
+	sealer.recipient = recipient
+	sealer.info = info
+
+	return sealer
+}
+
+// constructor for the receiving side, which can also seal to itself
+func NewRecipientSealer(suite *Suite, private *ecdh.PrivateKey, info []byte) *Sealer {
+	sealer := NewSealer(suite, private.PublicKey(), info)
+	sealer.private = private
+
+	return sealer
+}
+
+func (sealer *Sealer) Algorithms() (enc.KEMID, enc.DEMID) {
+	return enc.KEMHPKE, enc.DEMHPKE
+}
+
+// seals the plaintext to the recipient in a fresh context
+func (sealer *Sealer) Seal(plaintext, additional []byte) (*enc.Capsule, []byte, error) {
+	encapsulated, context, err := sealer.suite.SetupBaseS(sealer.recipient, sealer.info)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ciphertext, err := context.Seal(plaintext, additional)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return enc.NewCapsule(enc.KEMHPKE, sealer.suite.hpkeID(), encapsulated), ciphertext, nil
+}
+
+// opens a message sealed by Seal under the same suite
+func (sealer *Sealer) Open(capsule *enc.Capsule, ciphertext, additional []byte) ([]byte, error) {
+	if sealer.private == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	if capsule.KEM() != enc.KEMHPKE {
+		return nil, enc.ErrKEM
+	}
+
+	if !bytes.Equal(capsule.KeyID(), sealer.suite.hpkeID()) {
+		return nil, ErrCapsuleSuite
+	}
+
+	context, err := sealer.suite.SetupBaseR(capsule.Data(), sealer.private, sealer.info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return context.Open(ciphertext, additional)
+}
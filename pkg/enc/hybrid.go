@@ -0,0 +1,161 @@
+package enc
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var ErrCapsuleSize = errors.New("enc: capsule has the wrong size")
+
+var hybridInfo = []byte("zerocat hybrid kem")
+
+// size of the random capsule given to the symmetric deriver
+const symmetricCapsuleSize = 32
+
+// combines the pre-shared master, through its deriver, with an ephemeral ECDH exchange. the session key is
+// derived from both secrets and both capsules so it stays safe while either one of the secrets does
+type HybridEncapsulator struct {
+	deriver   Deriver
+	recipient *ecdh.PublicKey
+	private   *ecdh.PrivateKey
+	random    io.Reader
+}
+
+// constructor for the sending side
+func NewHybridEncapsulator(deriver Deriver, recipient *ecdh.PublicKey) *HybridEncapsulator {
+	encapsulator := new(HybridEncapsulator)
+	encapsulator.deriver = deriver
+	encapsulator.recipient = recipient
+	encapsulator.random = rand.Reader
+
+	return encapsulator
+}
+
+// constructor for the receiving side
+func NewHybridDecapsulator(deriver Deriver, private *ecdh.PrivateKey) *HybridEncapsulator {
+	encapsulator := NewHybridEncapsulator(deriver, private.PublicKey())
+	encapsulator.private = private
+
+	return encapsulator
+}
+
+// identifies the recipient's public key
+func (encapsulator *HybridEncapsulator) KeyID() []byte {
+	return publicKeyID(encapsulator.recipient.Bytes())
+}
+
+// key = HKDF(symmetric || ecdh, info || symmetric capsule || ephemeral || recipient)
+func (encapsulator *HybridEncapsulator) deriveKey(symmetric_capsule, ecdh_shared, ephemeral []byte) ([]byte, error) {
+	symmetric := encapsulator.deriver.Derive(symmetric_capsule)
+
+	// a destroyed deriver must not leave the key resting on the ECDH secret alone
+	if symmetric == nil {
+		return nil, ErrDestroyed
+	}
+
+	secret := make([]byte, 0)
+	secret = append(secret, symmetric...)
+	secret = append(secret, ecdh_shared...)
+	defer Wipe(secret)
+	defer Wipe(symmetric)
+
+	info := make([]byte, 0)
+	info = append(info, hybridInfo...)
+
+	for _, field := range [][]byte{symmetric_capsule, ephemeral, encapsulator.recipient.Bytes()} {
+		info = AppendField(info, field)
+	}
+
+	return HKDF(nil, secret, info, 32)
+}
+
+func (encapsulator *HybridEncapsulator) Algorithm() KEMID {
+	return KEMHybrid
+}
+
+// the capsule data is the random symmetric capsule followed by the ephemeral public key
+func (encapsulator *HybridEncapsulator) EncapsulateKey() ([]byte, *Capsule, error) {
+	symmetric_capsule := make([]byte, symmetricCapsuleSize)
+	_, err := io.ReadFull(encapsulator.random, symmetric_capsule)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared, ephemeral, err := ecdhEncap(encapsulator.recipient, encapsulator.random)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := encapsulator.deriveKey(symmetric_capsule, shared, ephemeral)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := make([]byte, 0, len(symmetric_capsule)+len(ephemeral))
+	data = append(data, symmetric_capsule...)
+	data = append(data, ephemeral...)
+
+	return key, NewCapsule(KEMHybrid, publicKeyID(encapsulator.recipient.Bytes()), data), nil
+}
+
+// needs both the master and the static private key to recover the key
+func (encapsulator *HybridEncapsulator) DecapsulateKey(capsule *Capsule) ([]byte, error) {
+	if encapsulator.private == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	if capsule.kem != KEMHybrid {
+		return nil, ErrKEM
+	}
+
+	if !bytes.Equal(capsule.key_id, publicKeyID(encapsulator.recipient.Bytes())) {
+		return nil, ErrKeyID
+	}
+
+	if len(capsule.data) <= symmetricCapsuleSize {
+		return nil, ErrCapsuleSize
+	}
+
+	symmetric_capsule, ephemeral := capsule.data[:symmetricCapsuleSize], capsule.data[symmetricCapsuleSize:]
+	shared, err := ecdhDecap(encapsulator.private, ephemeral)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return encapsulator.deriveKey(symmetric_capsule, shared, ephemeral)
+}
+
+// the capsule is the random symmetric capsule followed by the ephemeral public key
+func (encapsulator *HybridEncapsulator) Encapsulate(plaintext []byte) ([]byte, []byte, []byte, error) {
+	key, capsule, err := encapsulator.EncapsulateKey()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ciphertext, nonce, err := sealGCM(key, plaintext, encapsulator.random)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ciphertext, capsule.data, nonce, nil
+}
+
+// needs both the master and the static private key to recover the session key
+func (encapsulator *HybridEncapsulator) Decrypt(ciphertext []byte, capsule []byte, nonce []byte) ([]byte, error) {
+	key, err := encapsulator.DecapsulateKey(NewCapsule(KEMHybrid, publicKeyID(encapsulator.recipient.Bytes()), capsule))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return openGCM(key, ciphertext, nonce)
+}
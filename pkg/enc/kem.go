@@ -0,0 +1,280 @@
+package enc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrMalformed = errors.New("enc: malformed encoding")
+	ErrKEM       = errors.New("enc: capsule belongs to a different KEM")
+	ErrKeyID     = errors.New("enc: capsule is for a different key")
+)
+
+// identifies the mechanism that produced a capsule
+type KEMID uint16
+
+const (
+	KEMSymmetric KEMID = 0x0001
+	KEMECDH      KEMID = 0x0002
+	KEMHybrid    KEMID = 0x0003
+	// keys agreed when a session was set up, the capsule is empty
+	KEMSession KEMID = 0x0004
+	// an HPKE encapsulated key, the key id names the suite
+	KEMHPKE KEMID = 0x0005
+)
+
+// the material a recipient needs to recover an encapsulated key, the key id names the recipient key
+type Capsule struct {
+	kem    KEMID
+	key_id []byte
+	data   []byte
+}
+
+func NewCapsule(kem KEMID, key_id, data []byte) *Capsule {
+	capsule := new(Capsule)
+	capsule.kem = kem
+	capsule.key_id = key_id
+	capsule.data = data
+
+	return capsule
+}
+
+// points the capsule at another message's fields, so a reader can use one capsule for a whole stream
+func (capsule *Capsule) Reset(kem KEMID, key_id, data []byte) {
+	capsule.kem = kem
+	capsule.key_id = key_id
+	capsule.data = data
+}
+
+func (capsule *Capsule) KEM() KEMID {
+	return capsule.kem
+}
+
+func (capsule *Capsule) KeyID() []byte {
+	return capsule.key_id
+}
+
+func (capsule *Capsule) Data() []byte {
+	return capsule.data
+}
+
+// encodes the capsule as the kem id followed by the length prefixed key id and data
+func (capsule *Capsule) Marshal() []byte {
+	return capsule.appendTo(make([]byte, 0, capsule.marshalledSize()))
+}
+
+func (capsule *Capsule) appendTo(dst []byte) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(capsule.kem))
+	dst = AppendField(dst, capsule.key_id)
+
+	return AppendField(dst, capsule.data)
+}
+
+func (capsule *Capsule) marshalledSize() int {
+	return 2 + 4 + len(capsule.key_id) + 4 + len(capsule.data)
+}
+
+// decodes a capsule produced by Marshal
+func ParseCapsule(data []byte) (*Capsule, error) {
+	if len(data) < 2 {
+		return nil, ErrMalformed
+	}
+
+	capsule := new(Capsule)
+	capsule.kem = KEMID(binary.BigEndian.Uint16(data))
+	data = data[2:]
+
+	fields := make([][]byte, 2)
+
+	for i := 0; i < len(fields); i++ {
+		var err error
+		fields[i], data, err = ReadField(data)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(data) != 0 {
+		return nil, ErrMalformed
+	}
+
+	capsule.key_id = fields[0]
+	capsule.data = fields[1]
+
+	return capsule, nil
+}
+
+// key encapsulation: produces a fresh key and the capsule that lets the recipient recover it
+type KEM interface {
+	Algorithm() KEMID
+	EncapsulateKey() ([]byte, *Capsule, error)
+	DecapsulateKey(*Capsule) ([]byte, error)
+}
+
+// a hybrid scheme built from a KEM and a DEM. the DEM id and marshalled capsule are bound into the
+// additional data so the algorithms and key id can not be swapped without detection
+type KEMDEM struct {
+	kem KEM
+	dem DEM
+}
+
+func NewKEMDEM(kem KEM, dem DEM) *KEMDEM {
+	scheme := new(KEMDEM)
+	scheme.kem = kem
+	scheme.dem = dem
+
+	return scheme
+}
+
+func (scheme *KEMDEM) Algorithms() (KEMID, DEMID) {
+	return scheme.kem.Algorithm(), scheme.dem.Algorithm()
+}
+
+// KEMs that can append the key to a caller's buffer
+type bufferedKEM interface {
+	encapsulateKeyTo(dst []byte) ([]byte, *Capsule, error)
+	decapsulateKeyTo(dst []byte, capsule *Capsule) ([]byte, error)
+}
+
+// DEMs that can append to a caller's buffer
+type bufferedDEM interface {
+	sealTo(dst, key, plaintext, additional []byte) ([]byte, error)
+	openTo(dst, key, ciphertext, additional []byte) ([]byte, error)
+}
+
+// space for the key and bound additional data of one message when both the KEM and DEM are buffered.
+// the pool holds pointers, so taking and returning scratch space does not allocate
+type kemdemScratch struct {
+	key   []byte
+	bound []byte
+}
+
+var kemdemScratchPool = sync.Pool{New: func() any { return new(kemdemScratch) }}
+
+// wipes the key before the scratch space goes back to the pool
+func (scratch *kemdemScratch) release() {
+	Wipe(scratch.key)
+	kemdemScratchPool.Put(scratch)
+}
+
+// appends the DEM id, the length prefixed marshalled capsule and the caller's additional data
+func (scheme *KEMDEM) bindTo(dst []byte, capsule *Capsule, additional []byte) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(scheme.dem.Algorithm()))
+	dst = binary.BigEndian.AppendUint32(dst, uint32(capsule.marshalledSize()))
+	dst = capsule.appendTo(dst)
+
+	return append(dst, additional...)
+}
+
+func (scheme *KEMDEM) bind(capsule *Capsule, additional []byte) []byte {
+	return scheme.bindTo(make([]byte, 0, 6+capsule.marshalledSize()+len(additional)), capsule, additional)
+}
+
+// encapsulates a fresh key and encrypts the plaintext under it
+func (scheme *KEMDEM) Seal(plaintext, additional []byte) (*Capsule, []byte, error) {
+	key, capsule, err := scheme.kem.EncapsulateKey()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer Wipe(key)
+
+	ciphertext, err := scheme.dem.Seal(key, plaintext, scheme.bind(capsule, additional))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return capsule, ciphertext, nil
+}
+
+// recovers the key from the capsule and decrypts the ciphertext
+func (scheme *KEMDEM) Open(capsule *Capsule, ciphertext, additional []byte) ([]byte, error) {
+	key, err := scheme.kem.DecapsulateKey(capsule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(key)
+
+	return scheme.dem.Open(key, ciphertext, scheme.bind(capsule, additional))
+}
+
+// like Seal but appends the ciphertext to dst. when the KEM and DEM are both buffered the key and
+// additional data are built in pooled scratch space, so only the capsule and whatever the DEM needs per
+// key are allocated
+func (scheme *KEMDEM) SealTo(dst, plaintext, additional []byte) (*Capsule, []byte, error) {
+	kem, kem_ok := scheme.kem.(bufferedKEM)
+	dem, dem_ok := scheme.dem.(bufferedDEM)
+
+	if !kem_ok || !dem_ok {
+		capsule, ciphertext, err := scheme.Seal(plaintext, additional)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return capsule, append(dst, ciphertext...), nil
+	}
+
+	scratch := kemdemScratchPool.Get().(*kemdemScratch)
+	defer scratch.release()
+
+	key, capsule, err := kem.encapsulateKeyTo(scratch.key[:0])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scratch.key = key
+	scratch.bound = scheme.bindTo(scratch.bound[:0], capsule, additional)
+	ciphertext, err := dem.sealTo(dst, key, plaintext, scratch.bound)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return capsule, ciphertext, nil
+}
+
+// like Open but appends the plaintext to dst, using pooled scratch space as SealTo does
+func (scheme *KEMDEM) OpenTo(dst []byte, capsule *Capsule, ciphertext, additional []byte) ([]byte, error) {
+	kem, kem_ok := scheme.kem.(bufferedKEM)
+	dem, dem_ok := scheme.dem.(bufferedDEM)
+
+	if !kem_ok || !dem_ok {
+		plaintext, err := scheme.Open(capsule, ciphertext, additional)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, plaintext...), nil
+	}
+
+	scratch := kemdemScratchPool.Get().(*kemdemScratch)
+	defer scratch.release()
+
+	key, err := kem.decapsulateKeyTo(scratch.key[:0], capsule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scratch.key = key
+	scratch.bound = scheme.bindTo(scratch.bound[:0], capsule, additional)
+
+	return dem.openTo(dst, key, ciphertext, scratch.bound)
+}
+
+// a short identifier for a public key
+func publicKeyID(public []byte) []byte {
+	digest := sha256.Sum256(public)
+	return digest[:8]
+}
@@ -0,0 +1,183 @@
+package enc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// cost of the passphrase KDF for newly encrypted key blocks
+const DefaultKeyFileIterations = 600000
+
+// bounds on the cost a key block may ask for, the upper bound stops a crafted file from stalling the reader
+const (
+	MinKeyFileIterations = 10000
+	MaxKeyFileIterations = 1 << 26
+)
+
+const keyFileSaltSize = 16
+
+// the algorithms recorded in the headers of an encrypted key block
+const (
+	keyFileKDF    = "pbkdf2-sha256"
+	keyFileCipher = "aes-256-gcm"
+)
+
+var (
+	ErrPassphrase   = errors.New("enc: wrong passphrase or corrupted key block")
+	ErrKeyFile      = errors.New("enc: unsupported key block encryption")
+	ErrIterations   = errors.New("enc: key block iterations out of range")
+	ErrNotEncrypted = errors.New("enc: key block is not encrypted")
+)
+
+// whether the block was produced by EncryptPEMBlock
+func IsEncryptedPEMBlock(block *pem.Block) bool {
+	_, ok := block.Headers["cipher"]
+	return ok
+}
+
+// the block type and every header, sorted and length prefixed, so none of them can be changed
+// without the block failing to decrypt
+func keyFileAdditional(block *pem.Block) []byte {
+	names := make([]string, 0, len(block.Headers))
+
+	for name := range block.Headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fields := []string{block.Type}
+
+	for _, name := range names {
+		fields = append(fields, name, block.Headers[name])
+	}
+
+	additional := make([]byte, 0)
+
+	for _, field := range fields {
+		additional = AppendField(additional, []byte(field))
+	}
+
+	return additional
+}
+
+// encrypts the bytes of a PEM block under a key derived from the passphrase. the type and headers are
+// kept in the clear, with the KDF, its cost, the salt, cipher and nonce recorded as further headers
+func EncryptPEMBlock(block *pem.Block, passphrase []byte, iterations int) (*pem.Block, error) {
+	if IsEncryptedPEMBlock(block) {
+		return nil, ErrKeyFile
+	}
+
+	if iterations < MinKeyFileIterations || iterations > MaxKeyFileIterations {
+		return nil, ErrIterations
+	}
+
+	salt := make([]byte, keyFileSaltSize)
+
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	key := PBKDF2(passphrase, salt, iterations, 32)
+	defer Wipe(key)
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	encrypted := new(pem.Block)
+	encrypted.Type = block.Type
+	encrypted.Headers = make(map[string]string)
+
+	for name, value := range block.Headers {
+		encrypted.Headers[name] = value
+	}
+
+	encrypted.Headers["kdf"] = keyFileKDF
+	encrypted.Headers["iterations"] = strconv.Itoa(iterations)
+	encrypted.Headers["salt"] = hex.EncodeToString(salt)
+	encrypted.Headers["cipher"] = keyFileCipher
+	encrypted.Headers["nonce"] = hex.EncodeToString(nonce)
+	encrypted.Bytes = aead.Seal(nil, nonce, block.Bytes, keyFileAdditional(encrypted))
+
+	return encrypted, nil
+}
+
+// recovers the original block from one produced by EncryptPEMBlock
+func DecryptPEMBlock(block *pem.Block, passphrase []byte) (*pem.Block, error) {
+	if !IsEncryptedPEMBlock(block) {
+		return nil, ErrNotEncrypted
+	}
+
+	if block.Headers["kdf"] != keyFileKDF || block.Headers["cipher"] != keyFileCipher {
+		return nil, ErrKeyFile
+	}
+
+	iterations, err := strconv.Atoi(block.Headers["iterations"])
+
+	if err != nil || iterations < MinKeyFileIterations || iterations > MaxKeyFileIterations {
+		return nil, ErrIterations
+	}
+
+	salt, err := hex.DecodeString(block.Headers["salt"])
+
+	if err != nil || len(salt) < keyFileSaltSize {
+		return nil, ErrKeyFile
+	}
+
+	nonce, err := hex.DecodeString(block.Headers["nonce"])
+
+	if err != nil {
+		return nil, ErrKeyFile
+	}
+
+	key := PBKDF2(passphrase, salt, iterations, 32)
+	defer Wipe(key)
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrKeyFile
+	}
+
+	plaintext, err := aead.Open(nil, nonce, block.Bytes, keyFileAdditional(block))
+
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+
+	decrypted := new(pem.Block)
+	decrypted.Type = block.Type
+	decrypted.Bytes = plaintext
+
+	for name, value := range block.Headers {
+		switch name {
+		case "kdf", "iterations", "salt", "cipher", "nonce":
+		default:
+			if decrypted.Headers == nil {
+				decrypted.Headers = make(map[string]string)
+			}
+
+			decrypted.Headers[name] = value
+		}
+	}
+
+	return decrypted, nil
+}
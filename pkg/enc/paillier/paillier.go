@@ -0,0 +1,351 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+var (
+	ErrTotient    = errors.New("paillier: group was created without its factors")
+	ErrRange      = errors.New("paillier: message out of range")
+	ErrCiphertext = errors.New("paillier: invalid ciphertext")
+	ErrNotBit     = errors.New("paillier: message is neither 0 nor 1")
+	ErrMalformed  = errors.New("paillier: malformed proof")
+)
+
+// size of the challenge space for bit proofs, well below the size of the factors of n
+const challengeBits = 128
+
+// paillier public key with generator g = n + 1
+type PublicKey struct {
+	modulus *big.Int
+	square  *big.Int
+	group   *gt.CompositeMulGroup
+}
+
+// constructs a public key from the modulus
+func NewPublicKey(modulus *big.Int) *PublicKey {
+	public := new(PublicKey)
+	public.modulus = modulus
+	public.square = big.NewInt(0)
+	public.square.Mul(modulus, modulus)
+	public.group = gt.NewCompGroup(gt.SetupModRing(modulus))
+
+	return public
+}
+
+func (public *PublicKey) Modulus() *big.Int {
+	return public.modulus
+}
+
+// paillier private key, lambda is the totient of n and mu its inverse modulo n
+type PrivateKey struct {
+	public *PublicKey
+	lambda *big.Int
+	mu     *big.Int
+}
+
+// derives a key pair from a composite group that still holds its totient
+func KeyPair(group *gt.CompositeMulGroup) (*PublicKey, *PrivateKey, error) {
+	if group.Totient().Sign() == 0 {
+		return nil, nil, ErrTotient
+	}
+
+	public := NewPublicKey(group.Modulus())
+	private := new(PrivateKey)
+	private.public = public
+	private.lambda = group.Totient()
+	private.mu = big.NewInt(0)
+
+	if private.mu.ModInverse(private.lambda, public.modulus) == nil {
+		return nil, nil, ErrTotient
+	}
+
+	return public, private, nil
+}
+
+func (private *PrivateKey) Public() *PublicKey {
+	return private.public
+}
+
+// c = (1 + m * n) * r ** n mod n ** 2
+func (public *PublicKey) encrypt(message, randomness *big.Int) *big.Int {
+	ciphertext := big.NewInt(0)
+	ciphertext.Mul(message, public.modulus)
+	ciphertext.Add(ciphertext, gt.One)
+
+	mask := big.NewInt(0)
+	mask.Exp(randomness, public.modulus, public.square)
+	ciphertext.Mul(ciphertext, mask)
+
+	return ciphertext.Mod(ciphertext, public.square)
+}
+
+func (public *PublicKey) encryptRandom(message *big.Int) (*big.Int, *big.Int, error) {
+	if message.Sign() < 0 || message.Cmp(public.modulus) >= 0 {
+		return nil, nil, ErrRange
+	}
+
+	randomness, err := public.group.Random()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return public.encrypt(message, randomness), randomness, nil
+}
+
+// encrypts a message between 0 and n - 1
+func (public *PublicKey) Encrypt(message *big.Int) (*big.Int, error) {
+	ciphertext, _, err := public.encryptRandom(message)
+	return ciphertext, err
+}
+
+// checks that a ciphertext is a unit modulo n ** 2
+func (public *PublicKey) valid(ciphertext *big.Int) bool {
+	if ciphertext.Sign() <= 0 || ciphertext.Cmp(public.square) >= 0 {
+		return false
+	}
+
+	gcd := big.NewInt(0)
+	gcd.GCD(nil, nil, ciphertext, public.modulus)
+
+	return gcd.Cmp(gt.One) == 0
+}
+
+// m = L(c ** lambda mod n ** 2) * mu mod n where L(x) = (x - 1) / n
+func (private *PrivateKey) Decrypt(ciphertext *big.Int) (*big.Int, error) {
+	public := private.public
+
+	if !public.valid(ciphertext) {
+		return nil, ErrCiphertext
+	}
+
+	message := big.NewInt(0)
+	message.Exp(ciphertext, private.lambda, public.square)
+	message.Sub(message, gt.One)
+	message.Div(message, public.modulus)
+	message.Mul(message, private.mu)
+
+	return message.Mod(message, public.modulus), nil
+}
+
+// produces a ciphertext of the sum of the two messages
+func (public *PublicKey) Add(a, b *big.Int) *big.Int {
+	sum := big.NewInt(0)
+	sum.Mul(a, b)
+
+	return sum.Mod(sum, public.square)
+}
+
+// produces a ciphertext of the message multiplied by a scalar
+func (public *PublicKey) ScalarMul(ciphertext, scalar *big.Int) *big.Int {
+	reduced := big.NewInt(0)
+	reduced.Mod(scalar, public.modulus)
+
+	product := big.NewInt(0)
+	return product.Exp(ciphertext, reduced, public.square)
+}
+
+// a disjunctive proof that a ciphertext encrypts 0 or 1. each branch proves that c * g ** -i is an n-th power,
+// only one of them honestly, and the two challenges must add up to the fiat-shamir challenge
+type BitProof struct {
+	commitments [2]*big.Int
+	challenges  [2]*big.Int
+	responses   [2]*big.Int
+}
+
+// encodes the proof as its six values
+func (proof *BitProof) Marshal() []byte {
+	output := make([]byte, 0)
+
+	for _, values := range [][2]*big.Int{proof.commitments, proof.challenges, proof.responses} {
+		for i := 0; i < 2; i++ {
+			output = enc.AppendField(output, values[i].Bytes())
+		}
+	}
+
+	return output
+}
+
+// decodes a proof produced by Marshal
+func ParseBitProof(data []byte) (*BitProof, error) {
+	values := make([]*big.Int, 6)
+
+	for i := 0; i < len(values); i++ {
+		field, rest, err := enc.ReadField(data)
+
+		if err != nil {
+			return nil, ErrMalformed
+		}
+
+		values[i] = big.NewInt(0)
+		values[i].SetBytes(field)
+		data = rest
+	}
+
+	if len(data) != 0 {
+		return nil, ErrMalformed
+	}
+
+	proof := new(BitProof)
+	proof.commitments = [2]*big.Int{values[0], values[1]}
+	proof.challenges = [2]*big.Int{values[2], values[3]}
+	proof.responses = [2]*big.Int{values[4], values[5]}
+
+	return proof, nil
+}
+
+// the values that must be n-th powers if the ciphertext encrypts 0 or 1 respectively
+func (public *PublicKey) branches(ciphertext *big.Int) [2]*big.Int {
+	generator := big.NewInt(0)
+	generator.Add(public.modulus, gt.One)
+	inverse := big.NewInt(0)
+	inverse.ModInverse(generator, public.square)
+
+	shifted := big.NewInt(0)
+	shifted.Mul(ciphertext, inverse)
+	shifted.Mod(shifted, public.square)
+
+	return [2]*big.Int{ciphertext, shifted}
+}
+
+// the fiat-shamir challenge over both commitments reduced into the challenge space
+func (public *PublicKey) challenge(challenger auth.Challenger, ciphertext *big.Int, commitments [2]*big.Int, block []byte) *big.Int {
+	transcript := make([]byte, 0)
+
+	for _, value := range []*big.Int{public.modulus, ciphertext, commitments[1]} {
+		transcript = enc.AppendField(transcript, value.Bytes())
+	}
+
+	transcript = append(transcript, block...)
+	digest := challenger.Challenge(commitments[0], transcript)
+
+	challenge := big.NewInt(0)
+	challenge.SetBytes(digest)
+
+	return challenge.Mod(challenge, challengeSpace())
+}
+
+func challengeSpace() *big.Int {
+	space := big.NewInt(0)
+	return space.Lsh(gt.One, challengeBits)
+}
+
+// encrypts a bit and proves that the ciphertext holds 0 or 1, the block is bound into the challenge
+func (public *PublicKey) EncryptBit(bit *big.Int, challenger auth.Challenger, block []byte) (*big.Int, *BitProof, error) {
+	if bit.Cmp(gt.Zero) != 0 && bit.Cmp(gt.One) != 0 {
+		return nil, nil, ErrNotBit
+	}
+
+	ciphertext, randomness, err := public.encryptRandom(bit)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	honest := int(bit.Int64())
+	simulated := 1 - honest
+	branches := public.branches(ciphertext)
+	proof := new(BitProof)
+
+	// simulate the false branch: a = z ** n * u ** -e
+	simulated_challenge, err := rand.Int(rand.Reader, challengeSpace())
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	simulated_response, err := public.group.Random()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof.challenges[simulated] = simulated_challenge
+	proof.responses[simulated] = simulated_response
+	proof.commitments[simulated] = public.simulate(branches[simulated], simulated_challenge, simulated_response)
+
+	// honest commitment for the true branch: a = rho ** n
+	nonce, err := public.group.Random()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof.commitments[honest] = big.NewInt(0)
+	proof.commitments[honest].Exp(nonce, public.modulus, public.square)
+
+	challenge := public.challenge(challenger, ciphertext, proof.commitments, block)
+
+	// e_true = e - e_false mod 2 ** 128, z = rho * r ** e_true mod n
+	proof.challenges[honest] = big.NewInt(0)
+	proof.challenges[honest].Sub(challenge, proof.challenges[simulated])
+	proof.challenges[honest].Mod(proof.challenges[honest], challengeSpace())
+
+	proof.responses[honest] = big.NewInt(0)
+	proof.responses[honest].Exp(randomness, proof.challenges[honest], public.modulus)
+	proof.responses[honest].Mul(proof.responses[honest], nonce)
+	proof.responses[honest].Mod(proof.responses[honest], public.modulus)
+
+	return ciphertext, proof, nil
+}
+
+// a = z ** n * u ** -e mod n ** 2
+func (public *PublicKey) simulate(branch, challenge, response *big.Int) *big.Int {
+	commitment := big.NewInt(0)
+	commitment.Exp(response, public.modulus, public.square)
+
+	inverse := big.NewInt(0)
+	inverse.ModInverse(branch, public.square)
+	inverse.Exp(inverse, challenge, public.square)
+	commitment.Mul(commitment, inverse)
+
+	return commitment.Mod(commitment, public.square)
+}
+
+// checks that the ciphertext encrypts 0 or 1
+func (public *PublicKey) VerifyBit(ciphertext *big.Int, proof *BitProof, challenger auth.Challenger, block []byte) bool {
+	if !public.valid(ciphertext) {
+		return false
+	}
+
+	challenge := public.challenge(challenger, ciphertext, proof.commitments, block)
+	space := challengeSpace()
+
+	sum := big.NewInt(0)
+	sum.Add(proof.challenges[0], proof.challenges[1])
+	sum.Mod(sum, space)
+
+	if sum.Cmp(challenge) != 0 {
+		return false
+	}
+
+	branches := public.branches(ciphertext)
+
+	// z ** n = a * u ** e mod n ** 2 for both branches
+	for i := 0; i < 2; i++ {
+		if proof.challenges[i].Cmp(space) >= 0 || !public.valid(proof.commitments[i]) {
+			return false
+		}
+
+		left := big.NewInt(0)
+		left.Exp(proof.responses[i], public.modulus, public.square)
+
+		right := big.NewInt(0)
+		right.Exp(branches[i], proof.challenges[i], public.square)
+		right.Mul(right, proof.commitments[i])
+		right.Mod(right, public.square)
+
+		if left.Cmp(right) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,42 @@
+package enc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// PBKDF2 from RFC 8018 with HMAC-SHA256 as the pseudorandom function
+func PBKDF2(password, salt []byte, iterations, length int) []byte {
+	mac := hmac.New(sha256.New, password)
+	output := make([]byte, 0, length)
+	block := make([]byte, 0, len(salt)+4)
+
+	for index := uint32(1); len(output) < length; index++ {
+		// U_1 = PRF(P, S || INT(i))
+		block = append(block[:0], salt...)
+		block = binary.BigEndian.AppendUint32(block, index)
+
+		mac.Reset()
+		mac.Write(block)
+		u := mac.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+
+		// T_i = U_1 xor U_2 xor ... xor U_c
+		for i := 1; i < iterations; i++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+
+			for j := 0; j < len(t); j++ {
+				t[j] ^= u[j]
+			}
+		}
+
+		output = append(output, t...)
+	}
+
+	return output[:length]
+}
@@ -0,0 +1,473 @@
+package enc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// most message keys a receiver will derive ahead and hold for messages that arrive late
+const MaxSkippedKeys = 1000
+
+var (
+	ErrMessageKey    = errors.New("enc: message key already used or deleted")
+	ErrTooManySkips  = errors.New("enc: too many skipped messages")
+	ErrEpoch         = errors.New("enc: ratchet epoch skipped")
+	ErrRatchetFull   = errors.New("enc: ratchet chain exhausted")
+	ErrNoRatchetKEM  = errors.New("enc: ratchet step without a KEM")
+	ErrRatchetHeader = errors.New("enc: malformed ratchet header")
+)
+
+var (
+	ratchetInfo        = []byte("zerocat ratchet")
+	ratchetRootInfo    = []byte("zerocat ratchet root")
+	ratchetMessageInfo = []byte("zerocat ratchet message")
+)
+
+// a symmetric KDF chain, every step hands out a message key and replaces the chain key with the next
+// one, the old chain key is wiped so earlier message keys can not be derived from the current state
+type KeyChain struct {
+	chain   []byte
+	counter uint32
+}
+
+// starts a chain from a 32 byte chain key, the key is copied
+func NewKeyChain(chain []byte) *KeyChain {
+	key_chain := new(KeyChain)
+	key_chain.chain = append([]byte{}, chain...)
+
+	return key_chain
+}
+
+// message key = HMAC(chain, 0x01), next chain = HMAC(chain, 0x02)
+func (key_chain *KeyChain) Next() ([]byte, error) {
+	if key_chain.counter == ^uint32(0) {
+		return nil, ErrRatchetFull
+	}
+
+	mac := hmac.New(sha256.New, key_chain.chain)
+	mac.Write([]byte{0x01})
+	message_key := mac.Sum(nil)
+
+	mac.Reset()
+	mac.Write([]byte{0x02})
+	next := mac.Sum(nil)
+
+	Wipe(key_chain.chain)
+	key_chain.chain = next
+	key_chain.counter++
+
+	return message_key, nil
+}
+
+// the number of message keys handed out so far
+func (key_chain *KeyChain) Counter() uint32 {
+	return key_chain.counter
+}
+
+// a copy of the current chain key, enough to derive every later message key and none of the earlier ones
+func (key_chain *KeyChain) State() []byte {
+	return append([]byte{}, key_chain.chain...)
+}
+
+func (key_chain *KeyChain) clone() *KeyChain {
+	clone := NewKeyChain(key_chain.chain)
+	clone.counter = key_chain.counter
+
+	return clone
+}
+
+func (key_chain *KeyChain) destroy() {
+	Wipe(key_chain.chain)
+}
+
+// one direction of a ratchet: a root key that is mixed with KEM keys to start a new epoch, and the
+// chain for the current epoch
+type ratchetDirection struct {
+	root  []byte
+	chain *KeyChain
+	epoch uint32
+}
+
+// root, chain = HKDF(root, kem key)
+func (direction *ratchetDirection) step(kem_key []byte) error {
+	output, err := HKDF(direction.root, kem_key, ratchetRootInfo, 64)
+
+	if err != nil {
+		return err
+	}
+
+	Wipe(direction.root)
+	direction.chain.destroy()
+	direction.root = output[:32]
+	direction.chain = NewKeyChain(output[32:])
+	Wipe(output[32:])
+	direction.epoch++
+
+	return nil
+}
+
+func (direction *ratchetDirection) clone() *ratchetDirection {
+	clone := new(ratchetDirection)
+	clone.root = append([]byte{}, direction.root...)
+	clone.chain = direction.chain.clone()
+	clone.epoch = direction.epoch
+
+	return clone
+}
+
+func (direction *ratchetDirection) destroy() {
+	Wipe(direction.root)
+	direction.chain.destroy()
+}
+
+// identifies a message key held for a message that has not arrived yet
+type skippedKey struct {
+	epoch   uint32
+	counter uint32
+}
+
+// sent in the clear with every message and authenticated as additional data. previous is the number of
+// messages sent in the epoch before this one, the capsule is the KEM output that started this epoch
+type RatchetHeader struct {
+	epoch    uint32
+	counter  uint32
+	previous uint32
+	capsule  *Capsule
+}
+
+func (header *RatchetHeader) Epoch() uint32 {
+	return header.epoch
+}
+
+func (header *RatchetHeader) Counter() uint32 {
+	return header.counter
+}
+
+// encodes the header as epoch, counter, previous and the length prefixed marshalled capsule
+func (header *RatchetHeader) Marshal() []byte {
+	output := binary.BigEndian.AppendUint32(nil, header.epoch)
+	output = binary.BigEndian.AppendUint32(output, header.counter)
+	output = binary.BigEndian.AppendUint32(output, header.previous)
+
+	if header.capsule == nil {
+		return AppendField(output, nil)
+	}
+
+	return AppendField(output, header.capsule.Marshal())
+}
+
+// splits a ratchet message into its header and ciphertext
+func parseRatchetMessage(message []byte) (*RatchetHeader, []byte, []byte, error) {
+	if len(message) < 16 {
+		return nil, nil, nil, ErrRatchetHeader
+	}
+
+	header := new(RatchetHeader)
+	header.epoch = binary.BigEndian.Uint32(message)
+	header.counter = binary.BigEndian.Uint32(message[4:])
+	header.previous = binary.BigEndian.Uint32(message[8:])
+	field, ciphertext, err := ReadField(message[12:])
+
+	if err != nil {
+		return nil, nil, nil, ErrRatchetHeader
+	}
+
+	if len(field) > 0 {
+		capsule, err := ParseCapsule(field)
+
+		if err != nil {
+			return nil, nil, nil, ErrRatchetHeader
+		}
+
+		header.capsule = capsule
+	}
+
+	return header, message[:len(message)-len(ciphertext)], ciphertext, nil
+}
+
+// a session ratchet. both peers start from the same secret and every message is encrypted under its own
+// key from a KDF chain that is advanced and wiped, so a compromise of the current state does not expose
+// earlier messages. given KEMs the sender also starts a new epoch every interval messages, mixing a
+// fresh encapsulated key into its root so a leaked state stops being useful once the next epoch begins.
+// all messages of an epoch carry its capsule, but losing every message of an epoch ends the session
+type Ratchet struct {
+	send      *ratchetDirection
+	receive   *ratchetDirection
+	previous  uint32
+	capsule   *Capsule
+	skipped   map[skippedKey][]byte
+	send_kem  KEM
+	recv_kem  KEM
+	interval  uint32
+	destroyed bool
+}
+
+// derives both directions from the shared session secret, the two peers must pass opposite roles
+func NewRatchet(secret []byte, initiator bool) (*Ratchet, error) {
+	output, err := HKDF(nil, secret, ratchetInfo, 128)
+
+	if err != nil {
+		return nil, err
+	}
+
+	directions := make([]*ratchetDirection, 2)
+
+	for i := range directions {
+		directions[i] = new(ratchetDirection)
+		directions[i].root = output[64*i : 64*i+32]
+		directions[i].chain = NewKeyChain(output[64*i+32 : 64*i+64])
+		Wipe(output[64*i+32 : 64*i+64])
+	}
+
+	ratchet := new(Ratchet)
+	ratchet.skipped = make(map[skippedKey][]byte)
+
+	if initiator {
+		ratchet.send, ratchet.receive = directions[0], directions[1]
+	} else {
+		ratchet.send, ratchet.receive = directions[1], directions[0]
+	}
+
+	return ratchet, nil
+}
+
+// enables KEM steps: send encapsulates to the peer and receive decapsulates what the peer sends us,
+// an interval of zero only receives steps
+func (ratchet *Ratchet) SetKEM(send, receive KEM, interval uint32) {
+	ratchet.send_kem = send
+	ratchet.recv_kem = receive
+	ratchet.interval = interval
+}
+
+// message key and nonce = HKDF-Expand(message key, info)
+func ratchetAEAD(message_key []byte) ([]byte, []byte, error) {
+	output, err := HKDFExpand(message_key, ratchetMessageInfo, 44)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return output[:32], output[32:], nil
+}
+
+// encrypts the plaintext under the next message key, returning the header followed by the ciphertext
+func (ratchet *Ratchet) Seal(plaintext, additional []byte) ([]byte, error) {
+	if ratchet.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	if ratchet.send_kem != nil && ratchet.interval > 0 && ratchet.send.chain.Counter() >= ratchet.interval {
+		kem_key, capsule, err := ratchet.send_kem.EncapsulateKey()
+
+		if err != nil {
+			return nil, err
+		}
+
+		ratchet.previous = ratchet.send.chain.Counter()
+
+		if err := ratchet.send.step(kem_key); err != nil {
+			return nil, err
+		}
+
+		Wipe(kem_key)
+		ratchet.capsule = capsule
+	}
+
+	header := new(RatchetHeader)
+	header.epoch = ratchet.send.epoch
+	header.counter = ratchet.send.chain.Counter()
+	header.previous = ratchet.previous
+	header.capsule = ratchet.capsule
+
+	message_key, err := ratchet.send.chain.Next()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(message_key)
+
+	key, nonce, err := ratchetAEAD(message_key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(key)
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	message := header.Marshal()
+
+	return aead.Seal(message, nonce, plaintext, append(append([]byte{}, message...), additional...)), nil
+}
+
+// derives and holds the keys of the chain up to the counter
+func skipKeys(direction *ratchetDirection, until uint32, skipped map[skippedKey][]byte, held int) error {
+	if until < direction.chain.Counter() {
+		return nil
+	}
+
+	if held+len(skipped)+int(until-direction.chain.Counter()) > MaxSkippedKeys {
+		return ErrTooManySkips
+	}
+
+	for direction.chain.Counter() < until {
+		counter := direction.chain.Counter()
+		message_key, err := direction.chain.Next()
+
+		if err != nil {
+			return err
+		}
+
+		skipped[skippedKey{direction.epoch, counter}] = message_key
+	}
+
+	return nil
+}
+
+func openRatchetMessage(message_key, header, ciphertext, additional []byte) ([]byte, error) {
+	key, nonce, err := ratchetAEAD(message_key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(key)
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, append(append([]byte{}, header...), additional...))
+
+	if err != nil {
+		return nil, ErrOpen
+	}
+
+	return plaintext, nil
+}
+
+// decrypts a message from Seal. messages may arrive out of order, each key is deleted once used and the
+// ratchet state only advances when the message authenticates
+func (ratchet *Ratchet) Open(message, additional []byte) ([]byte, error) {
+	if ratchet.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	header, header_bytes, ciphertext, err := parseRatchetMessage(message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	index := skippedKey{header.epoch, header.counter}
+
+	if message_key, ok := ratchet.skipped[index]; ok {
+		plaintext, err := openRatchetMessage(message_key, header_bytes, ciphertext, additional)
+
+		if err != nil {
+			return nil, err
+		}
+
+		Wipe(message_key)
+		delete(ratchet.skipped, index)
+
+		return plaintext, nil
+	}
+
+	if header.epoch < ratchet.receive.epoch {
+		return nil, ErrMessageKey
+	}
+
+	// work on a copy so a forged header can not move the real state
+	receive := ratchet.receive.clone()
+	skipped := make(map[skippedKey][]byte)
+
+	defer func() {
+		receive.destroy()
+
+		for _, key := range skipped {
+			Wipe(key)
+		}
+	}()
+
+	if header.epoch > receive.epoch {
+		if header.epoch != receive.epoch+1 {
+			return nil, ErrEpoch
+		}
+
+		if ratchet.recv_kem == nil || header.capsule == nil {
+			return nil, ErrNoRatchetKEM
+		}
+
+		if err := skipKeys(receive, header.previous, skipped, len(ratchet.skipped)); err != nil {
+			return nil, err
+		}
+
+		kem_key, err := ratchet.recv_kem.DecapsulateKey(header.capsule)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = receive.step(kem_key)
+		Wipe(kem_key)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if header.counter < receive.chain.Counter() {
+		return nil, ErrMessageKey
+	}
+
+	if err := skipKeys(receive, header.counter, skipped, len(ratchet.skipped)); err != nil {
+		return nil, err
+	}
+
+	message_key, err := receive.chain.Next()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(message_key)
+
+	plaintext, err := openRatchetMessage(message_key, header_bytes, ciphertext, additional)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// commit the new state, the deferred cleanup then wipes the old one
+	receive, ratchet.receive = ratchet.receive, receive
+
+	for index, key := range skipped {
+		ratchet.skipped[index] = key
+		delete(skipped, index)
+	}
+
+	return plaintext, nil
+}
+
+// wipes every key the ratchet holds, Seal and Open fail with ErrDestroyed afterwards
+func (ratchet *Ratchet) Destroy() {
+	ratchet.destroyed = true
+	ratchet.send.destroy()
+	ratchet.receive.destroy()
+
+	for index, key := range ratchet.skipped {
+		Wipe(key)
+		delete(ratchet.skipped, index)
+	}
+}
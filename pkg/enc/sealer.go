@@ -0,0 +1,18 @@
+package enc
+
+// seals messages with additional data under keys from a KEM. the capsule carries whatever the recipient
+// needs besides its own keys, and the algorithms identify the KEM and DEM so a reader can check them
+// before opening. KEMDEM is the general implementation
+type Sealer interface {
+	Algorithms() (KEMID, DEMID)
+	Seal(plaintext, additional []byte) (*Capsule, []byte, error)
+	Open(capsule *Capsule, ciphertext, additional []byte) ([]byte, error)
+}
+
+// a sealer that appends to caller supplied buffers, so a stream of messages can be sealed and opened
+// without allocating where the scheme allows it. dst must not overlap the plaintext or ciphertext
+type BufferedSealer interface {
+	Sealer
+	SealTo(dst, plaintext, additional []byte) (*Capsule, []byte, error)
+	OpenTo(dst []byte, capsule *Capsule, ciphertext, additional []byte) ([]byte, error)
+}
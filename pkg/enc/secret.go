@@ -0,0 +1,86 @@
+package enc
+
+import (
+	"errors"
+	"math/big"
+)
+
+var ErrDestroyed = errors.New("enc: key material has been destroyed")
+
+// anything holding key material that can be scrubbed once it is no longer needed
+type Destroyer interface {
+	Destroy()
+}
+
+// overwrites key material that is no longer needed
+func Wipe(key []byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
+
+// overwrites the words backing an integer, including any spare capacity, and sets it to zero
+func WipeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+
+	words := x.Bits()
+	words = words[:cap(words)]
+
+	for i := range words {
+		words[i] = 0
+	}
+
+	x.SetInt64(0)
+}
+
+// wipes every element of a key vector
+func WipeInts(xs []*big.Int) {
+	for _, x := range xs {
+		WipeInt(x)
+	}
+}
+
+// a buffer of key material that is wiped by Destroy. the garbage collector may still have moved or copied
+// the bytes before then, so this limits how long secrets linger rather than guaranteeing they are gone
+type Secret struct {
+	bytes     []byte
+	destroyed bool
+}
+
+// a zeroed secret of the given size
+func NewSecret(size int) *Secret {
+	secret := new(Secret)
+	secret.bytes = make([]byte, size)
+
+	return secret
+}
+
+// copies the data into a new secret, the caller remains responsible for wiping its own copy
+func NewSecretFrom(data []byte) *Secret {
+	secret := NewSecret(len(data))
+	copy(secret.bytes, data)
+
+	return secret
+}
+
+// the key material, nil once the secret is destroyed. the slice must not be kept beyond the secret's lifetime
+func (secret *Secret) Bytes() []byte {
+	return secret.bytes
+}
+
+func (secret *Secret) Len() int {
+	return len(secret.bytes)
+}
+
+func (secret *Secret) Destroyed() bool {
+	return secret.destroyed
+}
+
+// wipes the key material, it is safe to call more than once
+func (secret *Secret) Destroy() {
+	Wipe(secret.bytes)
+	secret.bytes = nil
+	secret.destroyed = true
+}
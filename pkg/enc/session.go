@@ -0,0 +1,363 @@
+package enc
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+// default usage limits for one key, in line with what TLS 1.3 allows per AES-GCM key
+// (RFC 8446 section 5.5) and the CFRG AEAD usage limits for counter nonces
+const (
+	DefaultMessageLimit = 1 << 24
+	DefaultByteLimit    = 1 << 38
+)
+
+// size of the record header, which is also the nonce: a 4 byte key epoch and an 8 byte counter
+const SessionHeaderSize = 12
+
+// largest plaintext GCM can encrypt in one message
+const maxGCMPlaintext = (1<<32 - 2) * 16
+
+var (
+	ErrNonceReuse       = errors.New("enc: nonce counter exhausted")
+	ErrReplay           = errors.New("enc: nonce already used")
+	ErrKeyLimit         = errors.New("enc: message exceeds the key usage limit")
+	ErrKeyEpoch         = errors.New("enc: record from an unknown key epoch")
+	ErrSessionExhausted = errors.New("enc: session can not be rekeyed any further")
+)
+
+var (
+	sessionInitiatorInfo = []byte("zerocat session initiator")
+	sessionResponderInfo = []byte("zerocat session responder")
+	sessionRekeyInfo     = []byte("zerocat session rekey")
+)
+
+// the key for one direction of a session and how much it has been used
+type sessionKey struct {
+	key      []byte
+	aead     cipher.AEAD
+	epoch    uint32
+	counter  uint64
+	messages uint64
+	bytes    uint64
+}
+
+func newSessionKey(key []byte, epoch uint32) (*sessionKey, error) {
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session_key := new(sessionKey)
+	session_key.key = key
+	session_key.aead = aead
+	session_key.epoch = epoch
+
+	return session_key, nil
+}
+
+// next key = HKDF-Expand(key, info || epoch), the old key is wiped by the caller once it is replaced
+func (session_key *sessionKey) next() (*sessionKey, error) {
+	if session_key.epoch == ^uint32(0) {
+		return nil, ErrSessionExhausted
+	}
+
+	epoch := session_key.epoch + 1
+	key, err := HKDFExpand(session_key.key, binary.BigEndian.AppendUint32(append([]byte{}, sessionRekeyInfo...), epoch), 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newSessionKey(key, epoch)
+}
+
+// writes the record header, which is also the nonce
+func (session_key *sessionKey) header(header []byte, counter uint64) {
+	binary.BigEndian.PutUint32(header, session_key.epoch)
+	binary.BigEndian.PutUint64(header[4:], counter)
+}
+
+// an AES-GCM session with deterministic nonces. each direction has its own key, every record is
+// sealed under the next counter so nonces never repeat, and a key is replaced with the next one from
+// a one way rekey before it reaches its message or byte limit. the peer follows a rekey when it sees
+// a record from the next epoch. records must arrive in order, as they do over a stream
+type GCMSession struct {
+	send          *sessionKey
+	receive       *sessionKey
+	message_limit uint64
+	byte_limit    uint64
+	destroyed     bool
+}
+
+// derives independent keys for both directions from the session key, the two peers must pass opposite roles
+func NewGCMSession(key []byte, initiator bool) (*GCMSession, error) {
+	initiator_key, err := HKDF(nil, key, sessionInitiatorInfo, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	responder_key, err := HKDF(nil, key, sessionResponderInfo, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !initiator {
+		initiator_key, responder_key = responder_key, initiator_key
+	}
+
+	session := new(GCMSession)
+	session.message_limit = DefaultMessageLimit
+	session.byte_limit = DefaultByteLimit
+	session.send, err = newSessionKey(initiator_key, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session.receive, err = newSessionKey(responder_key, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// lowers the number of messages and plaintext bytes one key may protect, limits above the defaults are ignored
+func (session *GCMSession) SetLimits(messages, bytes uint64) {
+	if messages > 0 && messages < DefaultMessageLimit {
+		session.message_limit = messages
+	}
+
+	if bytes > 0 && bytes < DefaultByteLimit {
+		session.byte_limit = bytes
+	}
+}
+
+// epoch of the current sending key
+func (session *GCMSession) Epoch() uint32 {
+	return session.send.epoch
+}
+
+// messages sealed under the current sending key
+func (session *GCMSession) Messages() uint64 {
+	return session.send.messages
+}
+
+// plaintext bytes sealed under the current sending key
+func (session *GCMSession) Bytes() uint64 {
+	return session.send.bytes
+}
+
+// replaces the sending key with the next one, the old key is wiped
+func (session *GCMSession) Rekey() error {
+	if session.destroyed {
+		return ErrDestroyed
+	}
+
+	next, err := session.send.next()
+
+	if err != nil {
+		return err
+	}
+
+	Wipe(session.send.key)
+	session.send = next
+
+	return nil
+}
+
+// SetLimits may lower the byte limit below what the key has already protected, so that is checked
+// before the subtraction
+func (session *GCMSession) exceeds(session_key *sessionKey, length uint64) bool {
+	if session_key.messages >= session.message_limit || session_key.bytes >= session.byte_limit {
+		return true
+	}
+
+	return session.byte_limit-session_key.bytes < length
+}
+
+// writes the next epoch and counter into header and appends the ciphertext to dst
+func (session *GCMSession) seal(dst, header, plaintext, additional []byte) ([]byte, error) {
+	length := uint64(len(plaintext))
+
+	if session.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	if len(header) != SessionHeaderSize {
+		return nil, ErrMalformed
+	}
+
+	if length > session.byte_limit || length > maxGCMPlaintext {
+		return nil, ErrKeyLimit
+	}
+
+	if session.exceeds(session.send, length) {
+		if err := session.Rekey(); err != nil {
+			return nil, err
+		}
+	}
+
+	if session.send.counter == ^uint64(0) {
+		return nil, ErrNonceReuse
+	}
+
+	session.send.header(header, session.send.counter)
+	session.send.counter++
+	session.send.messages++
+	session.send.bytes += length
+
+	return session.send.aead.Seal(dst, header, plaintext, additional), nil
+}
+
+// encrypts the plaintext, returning the epoch and counter header followed by the ciphertext.
+// rekeys first if the message would take the key past its limits
+func (session *GCMSession) Seal(plaintext, additional []byte) ([]byte, error) {
+	return session.SealTo(nil, plaintext, additional)
+}
+
+// appends the header and ciphertext to dst, which must not overlap the plaintext
+func (session *GCMSession) SealTo(dst, plaintext, additional []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, SessionHeaderSize)...)
+
+	return session.seal(dst, dst[start:], plaintext, additional)
+}
+
+// checks the header against the receiving key and appends the plaintext to dst
+func (session *GCMSession) open(dst, header, ciphertext, additional []byte) ([]byte, error) {
+	if session.destroyed {
+		return nil, ErrDestroyed
+	}
+
+	if len(header) != SessionHeaderSize || len(ciphertext) < session.receive.aead.Overhead() {
+		return nil, ErrMalformed
+	}
+
+	epoch := binary.BigEndian.Uint32(header)
+	counter := binary.BigEndian.Uint64(header[4:])
+	length := uint64(len(ciphertext) - session.receive.aead.Overhead())
+	receive := session.receive
+
+	// Seal never uses the last counter
+	if counter == ^uint64(0) {
+		return nil, ErrNonceReuse
+	}
+
+	switch {
+	case epoch == receive.epoch:
+		if counter < receive.counter {
+			return nil, ErrReplay
+		}
+
+		// the peer should have rekeyed before sending this
+		if session.exceeds(receive, length) {
+			return nil, ErrKeyLimit
+		}
+	case receive.epoch != ^uint32(0) && epoch == receive.epoch+1:
+		next, err := receive.next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		receive = next
+	default:
+		return nil, ErrKeyEpoch
+	}
+
+	plaintext, err := receive.aead.Open(dst, header, ciphertext, additional)
+
+	if err != nil {
+		return nil, ErrOpen
+	}
+
+	if receive != session.receive {
+		Wipe(session.receive.key)
+		session.receive = receive
+	}
+
+	receive.counter = counter + 1
+	receive.messages++
+	receive.bytes += length
+
+	return plaintext, nil
+}
+
+// decrypts a record from the peer's Seal, following the peer to its next key when it has rekeyed.
+// a record whose counter is not past the last one opened is rejected
+func (session *GCMSession) Open(record, additional []byte) ([]byte, error) {
+	return session.OpenTo(nil, record, additional)
+}
+
+// appends the plaintext of the record to dst. record[SessionHeaderSize:SessionHeaderSize] may be passed
+// as dst to decrypt in place, otherwise dst must not overlap the record
+func (session *GCMSession) OpenTo(dst, record, additional []byte) ([]byte, error) {
+	if len(record) < SessionHeaderSize {
+		return nil, ErrMalformed
+	}
+
+	return session.open(dst, record[:SessionHeaderSize], record[SessionHeaderSize:], additional)
+}
+
+// wipes both keys and drops their ciphers, every later Seal, Open or Rekey fails with ErrDestroyed
+func (session *GCMSession) Destroy() {
+	Wipe(session.send.key)
+	Wipe(session.receive.key)
+	session.send.aead = nil
+	session.receive.aead = nil
+	session.destroyed = true
+}
+
+// adapts a session to the Sealer interface. the keys were agreed when the session was set up so the
+// capsule is empty, and the epoch and counter header leads the ciphertext
+type SessionSealer struct {
+	session *GCMSession
+	capsule *Capsule
+}
+
+func NewSessionSealer(session *GCMSession) *SessionSealer {
+	sealer := new(SessionSealer)
+	sealer.session = session
+	sealer.capsule = NewCapsule(KEMSession, nil, nil)
+
+	return sealer
+}
+
+func (sealer *SessionSealer) Algorithms() (KEMID, DEMID) {
+	return KEMSession, DEMSession
+}
+
+func (sealer *SessionSealer) Seal(plaintext, additional []byte) (*Capsule, []byte, error) {
+	return sealer.SealTo(nil, plaintext, additional)
+}
+
+// appends the header and ciphertext to dst, the capsule is the same for every message
+func (sealer *SessionSealer) SealTo(dst, plaintext, additional []byte) (*Capsule, []byte, error) {
+	ciphertext, err := sealer.session.SealTo(dst, plaintext, additional)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sealer.capsule, ciphertext, nil
+}
+
+func (sealer *SessionSealer) Open(capsule *Capsule, ciphertext, additional []byte) ([]byte, error) {
+	return sealer.OpenTo(nil, capsule, ciphertext, additional)
+}
+
+// appends the plaintext to dst, with the aliasing rules of GCMSession.OpenTo
+func (sealer *SessionSealer) OpenTo(dst []byte, capsule *Capsule, ciphertext, additional []byte) ([]byte, error) {
+	if capsule.kem != KEMSession {
+		return nil, ErrKEM
+	}
+
+	return sealer.session.OpenTo(dst, ciphertext, additional)
+}
@@ -0,0 +1,474 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+var (
+	ErrThreshold   = errors.New("shamir: threshold must be between 1 and the number of shares")
+	ErrTooFew      = errors.New("shamir: not enough shares to reach the threshold")
+	ErrMismatch    = errors.New("shamir: shares belong to different secrets")
+	ErrBadShare    = errors.New("shamir: share does not match the commitments")
+	ErrMalformed   = errors.New("shamir: malformed encoding")
+	ErrSmallGroup  = errors.New("shamir: group order is too small to hold a chunk")
+	ErrDuplicateID = errors.New("shamir: duplicate share index")
+	ErrCommitments = errors.New("shamir: commitments do not match the threshold")
+)
+
+// reports which share caused a failure
+type ShareError struct {
+	Index uint32
+	Err   error
+}
+
+func (err *ShareError) Error() string {
+	return err.Err.Error() + " (share " + strconv.FormatUint(uint64(err.Index), 10) + ")"
+}
+
+func (err *ShareError) Unwrap() error {
+	return err.Err
+}
+
+// one holder's share of a secret, the secret is split into chunks that each fit below the
+// group order and every chunk is shared with its own polynomial. the blindings are the share's
+// points on the blinding polynomials, needed only to check it against the commitments
+type Share struct {
+	index     uint32
+	threshold uint32
+	length    uint32
+	values    []*big.Int
+	blindings []*big.Int
+}
+
+func (share *Share) Index() uint32 {
+	return share.index
+}
+
+func (share *Share) Threshold() uint32 {
+	return share.threshold
+}
+
+// pedersen commitments g ** a_j * h ** b_j to every coefficient of every chunk polynomial and
+// of a random blinding polynomial. they let a holder check their share, and unlike g ** a_0 alone
+// they hide the chunk however few bytes it holds
+type Commitments struct {
+	group  *gt.PrimeOrderGroup
+	h      *big.Int
+	values [][]*big.Int
+}
+
+func (commitments *Commitments) Group() *gt.PrimeOrderGroup {
+	return commitments.group
+}
+
+// the commitments to each chunk's coefficients, constant term first
+func (commitments *Commitments) Values() [][]*big.Int {
+	return commitments.values
+}
+
+// the second generator, derived from the group as for auth.Pedersen so nobody knows log_g(h)
+func pedersenGenerator(group *gt.PrimeOrderGroup) (*big.Int, error) {
+	pedersen, err := auth.SetupPedersen(group)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pedersen.H(), nil
+}
+
+// the number of whole bytes that always fit below the group order
+func chunkSize(group *gt.PrimeOrderGroup) int {
+	return (group.Order().BitLen() - 1) / 8
+}
+
+// splits a secret into count shares, any threshold of which can recover it
+func Split(group *gt.PrimeOrderGroup, secret []byte, threshold, count int) ([]*Share, *Commitments, error) {
+	return SplitFrom(rand.Reader, group, secret, threshold, count)
+}
+
+// splits a secret drawing the polynomial coefficients from the given source of randomness
+func SplitFrom(random io.Reader, group *gt.PrimeOrderGroup, secret []byte, threshold, count int) ([]*Share, *Commitments, error) {
+	if threshold < 1 || threshold > count || count >= 1<<31 {
+		return nil, nil, ErrThreshold
+	}
+
+	size := chunkSize(group)
+
+	if size < 1 {
+		return nil, nil, ErrSmallGroup
+	}
+
+	h, err := pedersenGenerator(group)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ring := gt.SetupModRing(group.Order())
+	shares := make([]*Share, count)
+
+	for i := 0; i < count; i++ {
+		shares[i] = new(Share)
+		shares[i].index = uint32(i + 1)
+		shares[i].threshold = uint32(threshold)
+		shares[i].length = uint32(len(secret))
+		shares[i].values = make([]*big.Int, 0)
+		shares[i].blindings = make([]*big.Int, 0)
+	}
+
+	commitments := new(Commitments)
+	commitments.group = group
+	commitments.h = h
+	commitments.values = make([][]*big.Int, 0)
+
+	for start := 0; start < len(secret) || start == 0; start += size {
+		end := start + size
+
+		if end > len(secret) {
+			end = len(secret)
+		}
+
+		constant := big.NewInt(0)
+		constant.SetBytes(secret[start:end])
+		polynomial, err := gt.RandomPolynomialFrom(random, ring, threshold-1, constant)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blinding_constant, err := ring.RandomFrom(random)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blinding, err := gt.RandomPolynomialFrom(random, ring, threshold-1, blinding_constant)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := 0; i < count; i++ {
+			x := big.NewInt(int64(shares[i].index))
+			shares[i].values = append(shares[i].values, polynomial.Evaluate(x))
+			shares[i].blindings = append(shares[i].blindings, blinding.Evaluate(x))
+		}
+
+		chunk_commitments := make([]*big.Int, 0)
+
+		for j, coefficient := range polynomial.Coefficients() {
+			chunk_commitments = append(chunk_commitments, commitments.commit(coefficient, blinding.Coefficients()[j]))
+		}
+
+		commitments.values = append(commitments.values, chunk_commitments)
+
+		if end == len(secret) {
+			break
+		}
+	}
+
+	return shares, commitments, nil
+}
+
+// g ** value * h ** blinding
+func (commitments *Commitments) commit(value, blinding *big.Int) *big.Int {
+	group := commitments.group
+	return group.Mul(group.Exp(group.Generator(), value), group.Exp(commitments.h, blinding))
+}
+
+// checks g ** y * h ** y' = prod C_j ** (x ** j) for every chunk of the share
+func (commitments *Commitments) Check(share *Share) bool {
+	group := commitments.group
+
+	if len(share.values) != len(commitments.values) || len(share.blindings) != len(share.values) {
+		return false
+	}
+
+	x := big.NewInt(int64(share.index))
+
+	for chunk := 0; chunk < len(share.values); chunk++ {
+		// a vector of another length commits to a polynomial of another degree
+		if len(commitments.values[chunk]) != int(share.threshold) {
+			return false
+		}
+
+		expected := big.NewInt(1)
+		power := big.NewInt(1)
+
+		for j := 0; j < len(commitments.values[chunk]); j++ {
+			expected = group.Mul(expected, group.Exp(commitments.values[chunk][j], power))
+			power.Mul(power, x)
+			power.Mod(power, group.Order())
+		}
+
+		if commitments.commit(share.values[chunk], share.blindings[chunk]).Cmp(expected) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recovers the secret from at least threshold shares. when commitments are given every share is
+// checked first and the first bad one is reported as a ShareError wrapping ErrBadShare
+func Combine(group *gt.PrimeOrderGroup, shares []*Share, commitments *Commitments) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrTooFew
+	}
+
+	first := shares[0]
+
+	if commitments != nil {
+		for chunk := 0; chunk < len(commitments.values); chunk++ {
+			if len(commitments.values[chunk]) != int(first.threshold) {
+				return nil, ErrCommitments
+			}
+		}
+	}
+
+	for i := 0; i < len(shares); i++ {
+		share := shares[i]
+
+		if share.threshold != first.threshold || share.length != first.length || len(share.values) != len(first.values) {
+			return nil, &ShareError{share.index, ErrMismatch}
+		}
+
+		for j := 0; j < i; j++ {
+			if shares[j].index == share.index {
+				return nil, &ShareError{share.index, ErrDuplicateID}
+			}
+		}
+
+		if commitments != nil && !commitments.Check(share) {
+			return nil, &ShareError{share.index, ErrBadShare}
+		}
+	}
+
+	if uint32(len(shares)) < first.threshold {
+		return nil, ErrTooFew
+	}
+
+	ring := gt.SetupModRing(group.Order())
+	used := shares[:first.threshold]
+	xs := make([]*big.Int, len(used))
+
+	for i := 0; i < len(used); i++ {
+		xs[i] = big.NewInt(int64(used[i].index))
+	}
+
+	size := chunkSize(group)
+	secret := make([]byte, 0, first.length)
+
+	for chunk := 0; chunk < len(first.values); chunk++ {
+		ys := make([]*big.Int, len(used))
+
+		for i := 0; i < len(used); i++ {
+			ys[i] = used[i].values[chunk]
+		}
+
+		constant, err := gt.Interpolate(ring, xs, ys, gt.Zero)
+
+		if err != nil {
+			return nil, err
+		}
+
+		length := int(first.length) - len(secret)
+
+		if length > size {
+			length = size
+		}
+
+		if length < 0 || constant.BitLen() > length*8 {
+			return nil, ErrMismatch
+		}
+
+		secret = append(secret, constant.FillBytes(make([]byte, length))...)
+	}
+
+	if len(secret) != int(first.length) {
+		return nil, ErrMismatch
+	}
+
+	return secret, nil
+}
+
+// reads back fields written by enc.AppendField, the first failure sticks so callers only check err once
+type fieldReader struct {
+	data []byte
+	err  error
+}
+
+// reads the next length prefixed field
+func (reader *fieldReader) field() []byte {
+	if reader.err != nil {
+		return nil
+	}
+
+	field, rest, err := enc.ReadField(reader.data)
+
+	if err != nil {
+		reader.err = ErrMalformed
+		return nil
+	}
+
+	reader.data = rest
+
+	return field
+}
+
+// reads the next field as a big integer
+func (reader *fieldReader) bigInt() *big.Int {
+	value := big.NewInt(0)
+	value.SetBytes(reader.field())
+
+	return value
+}
+
+// reads the next fixed width count
+func (reader *fieldReader) uint32() uint32 {
+	if reader.err != nil {
+		return 0
+	}
+
+	if len(reader.data) < 4 {
+		reader.err = ErrMalformed
+		return 0
+	}
+
+	value := binary.BigEndian.Uint32(reader.data)
+	reader.data = reader.data[4:]
+
+	return value
+}
+
+func (reader *fieldReader) finish() error {
+	if reader.err == nil && len(reader.data) != 0 {
+		reader.err = ErrMalformed
+	}
+
+	return reader.err
+}
+
+// encodes the share as index, threshold, length, its chunk values and then their blindings
+func (share *Share) Marshal() []byte {
+	output := make([]byte, 0)
+	output = binary.BigEndian.AppendUint32(output, share.index)
+	output = binary.BigEndian.AppendUint32(output, share.threshold)
+	output = binary.BigEndian.AppendUint32(output, share.length)
+	output = binary.BigEndian.AppendUint32(output, uint32(len(share.values)))
+
+	for i := 0; i < len(share.values); i++ {
+		output = enc.AppendField(output, share.values[i].Bytes())
+	}
+
+	for i := 0; i < len(share.blindings); i++ {
+		output = enc.AppendField(output, share.blindings[i].Bytes())
+	}
+
+	return output
+}
+
+// decodes a share produced by Marshal
+func ParseShare(data []byte) (*Share, error) {
+	reader := &fieldReader{data: data}
+	share := new(Share)
+	share.index = reader.uint32()
+	share.threshold = reader.uint32()
+	share.length = reader.uint32()
+	count := reader.uint32()
+	share.values = make([]*big.Int, 0)
+	share.blindings = make([]*big.Int, 0)
+
+	for i := uint32(0); i < count && reader.err == nil; i++ {
+		share.values = append(share.values, reader.bigInt())
+	}
+
+	for i := uint32(0); i < count && reader.err == nil; i++ {
+		share.blindings = append(share.blindings, reader.bigInt())
+	}
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	// the share at zero would be the secret itself
+	if share.index == 0 || share.threshold == 0 {
+		return nil, ErrMalformed
+	}
+
+	return share, nil
+}
+
+// encodes the group parameters followed by the commitments for every chunk
+func (commitments *Commitments) Marshal() []byte {
+	group := commitments.group
+	output := make([]byte, 0)
+	output = enc.AppendField(output, group.Modulus().Bytes())
+	output = enc.AppendField(output, group.Order().Bytes())
+	output = enc.AppendField(output, group.Generator().Bytes())
+	output = binary.BigEndian.AppendUint32(output, uint32(len(commitments.values)))
+
+	for i := 0; i < len(commitments.values); i++ {
+		output = binary.BigEndian.AppendUint32(output, uint32(len(commitments.values[i])))
+
+		for j := 0; j < len(commitments.values[i]); j++ {
+			output = enc.AppendField(output, commitments.values[i][j].Bytes())
+		}
+	}
+
+	return output
+}
+
+// decodes commitments produced by Marshal, validating the group parameters
+func ParseCommitments(data []byte) (*Commitments, error) {
+	reader := &fieldReader{data: data}
+	modulus := reader.bigInt()
+	order := reader.bigInt()
+	generator := reader.bigInt()
+	chunks := reader.uint32()
+	values := make([][]*big.Int, 0)
+
+	for i := uint32(0); i < chunks && reader.err == nil; i++ {
+		count := reader.uint32()
+		chunk := make([]*big.Int, 0)
+
+		for j := uint32(0); j < count && reader.err == nil; j++ {
+			chunk = append(chunk, reader.bigInt())
+		}
+
+		values = append(values, chunk)
+	}
+
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+
+	group, err := gt.NewPrimeOrderGroup(modulus, order, generator)
+
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := pedersenGenerator(group)
+
+	if err != nil {
+		return nil, err
+	}
+
+	commitments := new(Commitments)
+	commitments.group = group
+	commitments.h = h
+	commitments.values = values
+
+	return commitments, nil
+}
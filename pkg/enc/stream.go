@@ -0,0 +1,243 @@
+package enc
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// plaintext bytes in every chunk but the last
+const StreamChunkSize = 64 * 1024
+
+// size of the random salt that starts every stream
+const streamSaltSize = 16
+
+var (
+	ErrStreamClosed  = errors.New("enc: write to a closed stream")
+	ErrStreamTooLong = errors.New("enc: stream exceeds the chunk counter")
+	ErrTruncated     = errors.New("enc: stream was truncated")
+	ErrChunk         = errors.New("enc: chunk failed authentication")
+)
+
+var streamInfo = []byte("zerocat stream")
+
+// every stream gets its own key from the salt, so the counter nonces never repeat under one key
+func streamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	stream_key, err := HKDF(salt, key, streamInfo, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newGCM(stream_key)
+}
+
+// the STREAM nonce: zeros, a 32 bit chunk counter and a flag set only on the final chunk
+func streamNonce(counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint32(nonce[7:11], counter)
+
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// encrypts a stream in fixed size chunks, each authenticated with its position so chunks can not be
+// reordered, and the final one flagged so the stream can not be truncated. Close must be called
+type StreamWriter struct {
+	aead       cipher.AEAD
+	output     io.Writer
+	additional []byte
+	counter    uint32
+	buf        []byte
+	closed     bool
+}
+
+// writes the stream header to the output and returns a writer for the plaintext
+func NewStreamWriter(key, additional []byte, output io.Writer) (*StreamWriter, error) {
+	salt := make([]byte, streamSaltSize)
+
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := streamAEAD(key, salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := output.Write(salt); err != nil {
+		return nil, err
+	}
+
+	writer := new(StreamWriter)
+	writer.aead = aead
+	writer.output = output
+	writer.additional = additional
+	writer.buf = make([]byte, 0, StreamChunkSize+aead.Overhead())
+
+	return writer, nil
+}
+
+func (writer *StreamWriter) flush(last bool) error {
+	if writer.counter == ^uint32(0) && !last {
+		return ErrStreamTooLong
+	}
+
+	sealed := writer.aead.Seal(writer.buf[:0], streamNonce(writer.counter, last), writer.buf, writer.additional)
+
+	if _, err := writer.output.Write(sealed); err != nil {
+		return err
+	}
+
+	writer.buf = writer.buf[:0]
+	writer.counter++
+
+	return nil
+}
+
+// buffers at most one chunk, a full chunk is only sealed once more data shows it is not the last
+func (writer *StreamWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, ErrStreamClosed
+	}
+
+	written := 0
+
+	for len(p) > 0 {
+		if len(writer.buf) == StreamChunkSize {
+			if err := writer.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(writer.buf[len(writer.buf):StreamChunkSize], p)
+		writer.buf = writer.buf[:len(writer.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// seals the final chunk, the underlying writer is left open
+func (writer *StreamWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+
+	writer.closed = true
+
+	return writer.flush(true)
+}
+
+// decrypts a stream written by StreamWriter, no plaintext is returned before its chunk authenticates
+type StreamReader struct {
+	aead       cipher.AEAD
+	input      *bufio.Reader
+	additional []byte
+	counter    uint32
+	chunk      []byte
+	buf        []byte
+	plaintext  []byte
+	done       bool
+	err        error
+}
+
+// reads the stream header from the input and returns a reader for the plaintext
+func NewStreamReader(key, additional []byte, input io.Reader) (*StreamReader, error) {
+	salt := make([]byte, streamSaltSize)
+
+	if _, err := io.ReadFull(input, salt); err != nil {
+		return nil, ErrTruncated
+	}
+
+	aead, err := streamAEAD(key, salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader := new(StreamReader)
+	reader.aead = aead
+	reader.input = bufio.NewReader(input)
+	reader.additional = additional
+	reader.chunk = make([]byte, StreamChunkSize+aead.Overhead())
+	reader.buf = make([]byte, 0, StreamChunkSize)
+
+	return reader, nil
+}
+
+// reads and opens the next chunk, a short chunk or one followed by the end of input must be the last
+func (reader *StreamReader) next() error {
+	n, err := io.ReadFull(reader.input, reader.chunk)
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	} else if err != nil {
+		return err
+	}
+
+	last := n < len(reader.chunk)
+
+	if !last {
+		if _, err := reader.input.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if n < reader.aead.Overhead() {
+		return ErrTruncated
+	}
+
+	plaintext, err := reader.aead.Open(reader.buf[:0], streamNonce(reader.counter, last), reader.chunk[:n], reader.additional)
+
+	if err != nil {
+		// a chunk that was sealed as a middle chunk but ends the input means the rest was cut off
+		if _, middle := reader.aead.Open(reader.buf[:0], streamNonce(reader.counter, false), reader.chunk[:n], reader.additional); last && middle == nil {
+			return ErrTruncated
+		}
+
+		return ErrChunk
+	}
+
+	if !last && reader.counter == ^uint32(0) {
+		return ErrStreamTooLong
+	}
+
+	reader.plaintext = plaintext
+	reader.done = last
+	reader.counter++
+
+	return nil
+}
+
+func (reader *StreamReader) Read(p []byte) (int, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+
+	for len(reader.plaintext) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+
+		if err := reader.next(); err != nil {
+			reader.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, reader.plaintext)
+	reader.plaintext = reader.plaintext[n:]
+
+	return n, nil
+}
@@ -0,0 +1,205 @@
+package timelock
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+var ErrMalformed = errors.New("timelock: malformed puzzle")
+
+// size of the prime challenge in a wesolowski proof
+const challengeSize = 256
+
+var challengeLabel = []byte("zerocat wesolowski challenge")
+
+// an RSW time-lock puzzle: the payload is encapsulated under base ** (2 ** squarings) mod n,
+// which can only be computed quickly by somebody who knows the factors of n
+type Puzzle struct {
+	modulus    *big.Int
+	base       *big.Int
+	squarings  uint64
+	capsule    []byte
+	nonce      []byte
+	ciphertext []byte
+}
+
+// seals the payload so that opening it takes the given number of sequential squarings,
+// the group must have been set up with its factors for sealing to be fast
+func Seal(group *gt.CompositeMulGroup, squarings uint64, payload []byte) (*Puzzle, error) {
+	base, err := group.Random()
+
+	if err != nil {
+		return nil, err
+	}
+
+	puzzle := new(Puzzle)
+	puzzle.modulus = group.Modulus()
+	puzzle.base = base
+	puzzle.squarings = squarings
+
+	plaintext := make([]byte, len(payload))
+	copy(plaintext, payload)
+
+	encapsulator := puzzle.encapsulator(group.RepeatedSquare(base, squarings))
+	puzzle.ciphertext, puzzle.capsule, puzzle.nonce, err = encapsulator.Encapsulate(plaintext)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return puzzle, nil
+}
+
+// the solution is used as the master key for the encapsulation
+func (puzzle *Puzzle) encapsulator(solution *big.Int) *enc.AESEncapsulator {
+	master := make([]byte, (puzzle.modulus.BitLen()+7)/8)
+	solution.FillBytes(master)
+
+	return enc.NewAESEncapsulator(enc.NewSha256Deriver(master))
+}
+
+func (puzzle *Puzzle) Modulus() *big.Int {
+	return puzzle.modulus
+}
+
+func (puzzle *Puzzle) Base() *big.Int {
+	return puzzle.base
+}
+
+func (puzzle *Puzzle) Squarings() uint64 {
+	return puzzle.squarings
+}
+
+// performs the sequential squarings to find the solution
+func (puzzle *Puzzle) Solve() *big.Int {
+	group := gt.NewCompGroup(gt.SetupModRing(puzzle.modulus))
+	return group.RepeatedSquare(puzzle.base, puzzle.squarings)
+}
+
+// solves the puzzle and proves the solution is correct so others can skip the work
+func (puzzle *Puzzle) SolveWithProof() (*big.Int, *big.Int) {
+	solution := puzzle.Solve()
+	return solution, Prove(puzzle.modulus, puzzle.base, puzzle.squarings, solution)
+}
+
+// checks a claimed solution against its wesolowski proof
+func (puzzle *Puzzle) Verify(solution, proof *big.Int) bool {
+	return Verify(puzzle.modulus, puzzle.base, puzzle.squarings, solution, proof)
+}
+
+// decrypts the payload using the solution
+func (puzzle *Puzzle) Open(solution *big.Int) ([]byte, error) {
+	ciphertext := make([]byte, len(puzzle.ciphertext))
+	copy(ciphertext, puzzle.ciphertext)
+
+	return puzzle.encapsulator(solution).Decrypt(ciphertext, puzzle.capsule, puzzle.nonce)
+}
+
+// encodes the puzzle as length prefixed modulus, base, squarings, capsule, nonce and ciphertext
+func (puzzle *Puzzle) Marshal() []byte {
+	output := make([]byte, 0)
+	output = enc.AppendField(output, puzzle.modulus.Bytes())
+	output = enc.AppendField(output, puzzle.base.Bytes())
+	output = enc.AppendField(output, binary.BigEndian.AppendUint64(nil, puzzle.squarings))
+	output = enc.AppendField(output, puzzle.capsule)
+	output = enc.AppendField(output, puzzle.nonce)
+
+	return enc.AppendField(output, puzzle.ciphertext)
+}
+
+// decodes a puzzle produced by Marshal
+func ParsePuzzle(data []byte) (*Puzzle, error) {
+	fields := make([][]byte, 6)
+
+	for i := 0; i < len(fields); i++ {
+		var err error
+		fields[i], data, err = enc.ReadField(data)
+
+		if err != nil {
+			return nil, ErrMalformed
+		}
+	}
+
+	if len(data) != 0 || len(fields[2]) != 8 {
+		return nil, ErrMalformed
+	}
+
+	puzzle := new(Puzzle)
+	puzzle.modulus = big.NewInt(0)
+	puzzle.modulus.SetBytes(fields[0])
+	puzzle.base = big.NewInt(0)
+	puzzle.base.SetBytes(fields[1])
+	puzzle.squarings = binary.BigEndian.Uint64(fields[2])
+	puzzle.capsule = fields[3]
+	puzzle.nonce = fields[4]
+	puzzle.ciphertext = fields[5]
+
+	if puzzle.modulus.Sign() <= 0 {
+		return nil, ErrMalformed
+	}
+
+	return puzzle, nil
+}
+
+// the fiat-shamir prime l = H(n, x, y, t)
+func challenge(modulus, base *big.Int, squarings uint64, solution *big.Int) *big.Int {
+	transcript := make([]byte, 0)
+	transcript = enc.AppendField(transcript, modulus.Bytes())
+	transcript = enc.AppendField(transcript, base.Bytes())
+	transcript = enc.AppendField(transcript, solution.Bytes())
+	transcript = binary.BigEndian.AppendUint64(transcript, squarings)
+
+	return gt.HashToPrime(challengeLabel, transcript, challengeSize)
+}
+
+// computes the wesolowski proof pi = x ** floor(2 ** t / l) by long division, one squaring per step
+func Prove(modulus, base *big.Int, squarings uint64, solution *big.Int) *big.Int {
+	prime := challenge(modulus, base, squarings, solution)
+
+	proof := big.NewInt(1)
+	remainder := big.NewInt(1)
+
+	for i := uint64(0); i < squarings; i++ {
+		// next quotient bit of 2 ** t / l
+		remainder.Lsh(remainder, 1)
+		proof.Mul(proof, proof)
+
+		if remainder.Cmp(prime) >= 0 {
+			remainder.Sub(remainder, prime)
+			proof.Mul(proof, base)
+		}
+
+		proof.Mod(proof, modulus)
+	}
+
+	return proof
+}
+
+// checks pi ** l * x ** (2 ** t mod l) = y, which costs two small exponentiations instead of t squarings
+func Verify(modulus, base *big.Int, squarings uint64, solution, proof *big.Int) bool {
+	for _, element := range []*big.Int{base, solution, proof} {
+		if element.Sign() <= 0 || element.Cmp(modulus) >= 0 {
+			return false
+		}
+	}
+
+	prime := challenge(modulus, base, squarings, solution)
+
+	exponent := big.NewInt(0)
+	exponent.SetUint64(squarings)
+	remainder := big.NewInt(0)
+	remainder.Exp(gt.Two, exponent, prime)
+
+	check := big.NewInt(0)
+	check.Exp(proof, prime, modulus)
+	other := big.NewInt(0)
+	other.Exp(base, remainder, modulus)
+	check.Mul(check, other)
+	check.Mod(check, modulus)
+
+	return check.Cmp(solution) == 0
+}
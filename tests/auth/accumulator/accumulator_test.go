@@ -0,0 +1,102 @@
+package accumulator_test
+
+import (
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth/accumulator"
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+)
+
+func TestAccumulator(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	set, err := accumulator.Setup(group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modulus := set.Modulus()
+
+	if _, err := set.BatchAdd([][]byte{[]byte("alice"), []byte("bob")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := set.Add([]byte("bob")); err != accumulator.ErrMember {
+		t.Error("element added twice")
+	}
+
+	alice, err := set.MembershipWitness([]byte("alice"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !accumulator.VerifyMembership(modulus, set.Value(), []byte("alice"), alice) {
+		t.Error("valid membership witness rejected")
+	}
+
+	if accumulator.VerifyMembership(modulus, set.Value(), []byte("mallory"), alice) {
+		t.Error("membership witness accepted for another element")
+	}
+
+	// witnesses can be kept current without the manager
+	value, err := set.Add([]byte("carol"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice = accumulator.UpdateWitnessOnAdd(modulus, alice, [][]byte{[]byte("carol")})
+
+	if !accumulator.VerifyMembership(modulus, value, []byte("alice"), alice) {
+		t.Error("witness updated after addition rejected")
+	}
+
+	value, err = set.Delete([]byte("bob"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err = accumulator.UpdateWitnessOnDelete(modulus, alice, []byte("alice"), []byte("bob"), value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !accumulator.VerifyMembership(modulus, value, []byte("alice"), alice) {
+		t.Error("witness updated after deletion rejected")
+	}
+
+	// bob is no longer a member
+	if _, err := set.MembershipWitness([]byte("bob")); err != accumulator.ErrNotMember {
+		t.Error("witness produced for a deleted element")
+	}
+
+	bob, err := set.NonMembershipWitness([]byte("bob"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !accumulator.VerifyNonMembership(modulus, set.Base(), value, []byte("bob"), bob) {
+		t.Error("valid non-membership witness rejected")
+	}
+
+	if accumulator.VerifyNonMembership(modulus, set.Base(), value, []byte("carol"), bob) {
+		t.Error("non-membership witness accepted for a member")
+	}
+
+	if _, err := set.NonMembershipWitness([]byte("carol")); err != accumulator.ErrMember {
+		t.Error("non-membership witness produced for a member")
+	}
+}
+
+// accumulator values and witnesses depend on the prime mapping, so it must not change silently
+func TestHashToPrimeStable(t *testing.T) {
+	want := "9b9f3a90ed2fc3441906b36ceb36a431b287d40e92003c5434c59c3d81b815c9"
+
+	if got := accumulator.HashToPrime([]byte("zerocat")).Text(16); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+package auth_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+)
+
+type identity struct {
+	group   *grouptheory.CompositeMulGroup
+	private []*big.Int
+	public  *auth.FFSPublicKey
+}
+
+func newIdentity(t *testing.T) *identity {
+	group := grouptheory.SetupCompGroup(1024)
+	public, private, err := auth.FFSKeyPair(auth.MinCertificateKeys, group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &identity{group, private, auth.NewFFSPublicKey(public, group.Modulus())}
+}
+
+func TestCertificateChain(t *testing.T) {
+	root := newIdentity(t)
+	intermediate := newIdentity(t)
+	leaf := newIdentity(t)
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	intermediate_cert := auth.NewCertificate(1, "intermediate", intermediate.public, now, later, true)
+
+	if err := intermediate_cert.Sign(root.private, root.group, root.public); err != nil {
+		t.Fatal(err)
+	}
+
+	leaf_cert := auth.NewCertificate(2, "listener", leaf.public, now, later, false)
+
+	if err := leaf_cert.Sign(intermediate.private, intermediate.group, intermediate.public); err != nil {
+		t.Fatal(err)
+	}
+
+	// round trip both certificates through their encoding
+	chain := make([]*auth.Certificate, 0)
+
+	for _, certificate := range []*auth.Certificate{leaf_cert, intermediate_cert} {
+		encoded, err := certificate.Marshal()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := auth.ParseCertificate(encoded)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chain = append(chain, decoded)
+	}
+
+	if chain[0].Subject() != "listener" || chain[0].Serial() != 2 {
+		t.Error("decoded certificate does not match")
+	}
+
+	anchors := []*auth.FFSPublicKey{root.public}
+
+	if err := auth.VerifyChain(chain, anchors, now, nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := auth.VerifyChain(chain, []*auth.FFSPublicKey{leaf.public}, now, nil); err != auth.ErrUnknownIssuer {
+		t.Error("chain accepted without a trust anchor")
+	}
+
+	if err := auth.VerifyChain(chain, anchors, later.Add(time.Minute), nil); err != auth.ErrExpired {
+		t.Error("expired chain accepted")
+	}
+
+	// a leaf can not vouch for other keys
+	rogue := newIdentity(t)
+	rogue_cert := auth.NewCertificate(3, "rogue", rogue.public, now, later, false)
+	rogue_cert.Sign(leaf.private, leaf.group, leaf.public)
+
+	if err := auth.VerifyChain([]*auth.Certificate{rogue_cert, leaf_cert, intermediate_cert}, anchors, now, nil); err != auth.ErrNotAuthority {
+		t.Error("certificate issued by a leaf accepted")
+	}
+
+	// a certificate signed by anyone other than the next link is rejected
+	forged := auth.NewCertificate(2, "shell", leaf.public, now, later, false)
+	forged.Sign(leaf.private, leaf.group, leaf.public)
+	forged_bytes, _ := forged.Marshal()
+	forged, _ = auth.ParseCertificate(forged_bytes)
+
+	if err := auth.VerifyChain([]*auth.Certificate{forged, intermediate_cert}, anchors, now, nil); err != auth.ErrBadSignature {
+		t.Error("forged certificate accepted")
+	}
+}
+
+func TestWeakIssuerKey(t *testing.T) {
+	subject := newIdentity(t)
+	group := grouptheory.SetupCompGroup(1024)
+	public, private, err := auth.FFSKeyPair(32, group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weak := auth.NewFFSPublicKey(public, group.Modulus())
+	now := time.Now()
+
+	certificate := auth.NewCertificate(3, "weak", subject.public, now, now.Add(time.Hour), false)
+
+	if err := certificate.Sign(private, group, weak); !errors.Is(err, auth.ErrWeakKey) {
+		t.Error("certificate signed with a 32 element key")
+	}
+
+	list := auth.NewRevocationList([]uint64{3}, now, now.Add(time.Hour))
+
+	if err := list.Sign(private, group, weak); !errors.Is(err, auth.ErrWeakKey) {
+		t.Error("revocation list signed with a 32 element key")
+	}
+
+	// a strong signature is not accepted under a truncated copy of the key either
+	if err := certificate.Sign(subject.private, subject.group, subject.public); err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := auth.NewFFSPublicKey(subject.public.Elements()[:32], subject.public.Modulus())
+
+	if certificate.CheckSignature(truncated) {
+		t.Error("signature accepted under a 32 element key")
+	}
+}
+
+func TestRevocationList(t *testing.T) {
+	root := newIdentity(t)
+	leaf := newIdentity(t)
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	leaf_cert := auth.NewCertificate(7, "shell", leaf.public, now, later, false)
+	leaf_cert.Sign(root.private, root.group, root.public)
+
+	anchors := []*auth.FFSPublicKey{root.public}
+	chain := []*auth.Certificate{leaf_cert}
+
+	list := auth.NewRevocationList([]uint64{7}, now, later)
+
+	if err := list.Sign(root.private, root.group, root.public); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := list.Marshal()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = auth.ParseRevocationList(encoded)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := auth.VerifyChain(chain, anchors, now, []*auth.RevocationList{list}); err != auth.ErrRevoked {
+		t.Error("revoked certificate accepted")
+	}
+
+	// a list signed by anybody else must not be trusted
+	unrelated := auth.NewRevocationList([]uint64{7}, now, later)
+	unrelated.Sign(leaf.private, leaf.group, leaf.public)
+
+	if err := auth.VerifyChain(chain, anchors, now, []*auth.RevocationList{unrelated}); err != nil {
+		t.Error(err)
+	}
+
+	empty := auth.NewRevocationList(nil, now, later)
+	empty.Sign(root.private, root.group, root.public)
+
+	if err := auth.VerifyChain(chain, anchors, now, []*auth.RevocationList{empty}); err != nil {
+		t.Error(err)
+	}
+}
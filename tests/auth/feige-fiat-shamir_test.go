@@ -1,10 +1,16 @@
 package auth_test
 
 import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
 	"testing"
 
 	"github.com/SimonHorrocks/Zerocat/pkg/auth"
 	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
 )
 
 func TestNIZKFFS(t *testing.T) {
@@ -21,9 +27,190 @@ func TestNIZKFFS(t *testing.T) {
 	verifier := auth.SetupFFSVerifier(public, challenger, group.Modulus())
 
 	randomness, _ := group.Random()
-	proof := prover.ProofGen(randomness, []byte("Hello World!"))
+	proof, err := prover.ProofGen(randomness, []byte("Hello World!"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !verifier.Verify(proof, []byte("Hello World!")) {
 		t.Fail()
 	}
 }
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("entropy source failed")
+}
+
+func TestHedgedFFS(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	public, private, err := auth.FFSKeyPair(16, group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := [][]byte{[]byte("first message"), []byte("second message"), []byte("third message")}
+
+	// a source stuck on the same bytes and a source that always fails
+	for _, random := range []io.Reader{bytes.NewReader(make([]byte, 1024)), failingReader{}} {
+		challenger := auth.NewChainChallenger()
+		prover := auth.SetupFFSProver(private, challenger, group)
+		verifier := auth.SetupFFSVerifier(public, challenger, group.Modulus())
+		prover.Hedge(random)
+
+		seen := make(map[string]bool)
+
+		for _, message := range messages {
+			randomness, err := prover.Randomness(message)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if seen[randomness.String()] {
+				t.Error("commitment randomness reused across messages")
+			}
+
+			seen[randomness.String()] = true
+
+			proof, err := prover.ProofGen(randomness, message)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !verifier.Verify(proof, message) {
+				t.Error("hedged proof rejected")
+			}
+		}
+
+		// the same message after the chain has moved on must not reuse r either
+		challenger.Update(messages[0])
+		randomness, _ := prover.Randomness(messages[0])
+
+		if seen[randomness.String()] {
+			t.Error("commitment randomness reused after the challenger advanced")
+		}
+	}
+
+	// with a working source the same message gets fresh randomness each time
+	prover := auth.SetupFFSProver(private, auth.NewChainChallenger(), group)
+	prover.Hedge(rand.Reader)
+	first, _ := prover.Randomness(messages[0])
+	second, _ := prover.Randomness(messages[0])
+
+	if first.Cmp(second) == 0 {
+		t.Error("hedged randomness ignored the entropy source")
+	}
+
+	// a different key with the same broken source must not collide either
+	_, other, _ := auth.FFSKeyPair(16, group)
+	a := auth.SetupFFSProver(private, auth.NewChainChallenger(), group)
+	b := auth.SetupFFSProver(other, auth.NewChainChallenger(), group)
+	a.Hedge(failingReader{})
+	b.Hedge(failingReader{})
+	first, _ = a.Randomness(messages[0])
+	second, _ = b.Randomness(messages[0])
+
+	if first.Cmp(second) == 0 {
+		t.Error("different keys derived the same randomness")
+	}
+}
+
+func TestFFSProverDestroy(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	_, private, err := auth.FFSKeyPair(8, group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prover := auth.SetupFFSProver(private, auth.NewChainChallenger(), group)
+	randomness, _ := group.Random()
+	prover.Destroy()
+
+	for i, element := range private {
+		if element.Sign() != 0 {
+			t.Errorf("private element %d survived", i)
+		}
+	}
+
+	if _, err := prover.Randomness([]byte("block")); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed prover produced randomness")
+	}
+
+	if _, err := prover.ProofGen(randomness, []byte("block")); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed prover produced a proof")
+	}
+}
+
+// a challenger that always answers with the same challenge
+type fixedChallenger struct {
+	challenge []byte
+}
+
+func (challenger *fixedChallenger) Update(block []byte) {}
+
+func (challenger *fixedChallenger) Challenge(statement *big.Int, block []byte) []byte {
+	return challenger.challenge
+}
+
+func TestFFSChallengeBits(t *testing.T) {
+	group := grouptheory.SetupCompGroup(512)
+	public, private, err := auth.FFSKeyPair(32, group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prover := auth.SetupFFSProver(private, &fixedChallenger{make([]byte, 20)}, group)
+	randomness, _ := group.Random()
+	proof, _ := prover.ProofGen(randomness, []byte("block"))
+
+	// flipping any single challenge bit must change what the proof has to answer
+	for i := 0; i < len(public); i++ {
+		challenge := make([]byte, 20)
+		challenge[i/8] = 1 << (i % 8)
+		verifier := auth.SetupFFSVerifier(public, &fixedChallenger{challenge}, group.Modulus())
+
+		if verifier.Verify(proof, []byte("block")) {
+			t.Errorf("proof accepted for a challenge differing in bit %d", i)
+		}
+	}
+
+	// a proof for one block is not a proof for another
+	challenger := auth.NewChainChallenger()
+	prover = auth.SetupFFSProver(private, challenger, group)
+	verifier := auth.SetupFFSVerifier(public, challenger, group.Modulus())
+	proof, _ = prover.ProofGen(randomness, []byte("block"))
+
+	if verifier.Verify(proof, []byte("other block")) {
+		t.Error("proof accepted for a different block")
+	}
+
+	if _, _, err := auth.FFSKeyPair(auth.MaxFFSKeys+1, group); !errors.Is(err, auth.ErrFFSKeys) {
+		t.Error("key pair with more elements than challenge bits")
+	}
+
+	// a prover handed an oversized key fails rather than reading past the challenge
+	oversized := make([]*big.Int, auth.MaxFFSKeys+1)
+
+	for i := range oversized {
+		oversized[i] = private[i%len(private)]
+	}
+
+	prover = auth.SetupFFSProver(oversized, auth.NewChainChallenger(), group)
+
+	if _, err := prover.ProofGen(randomness, []byte("block")); !errors.Is(err, auth.ErrFFSKeys) {
+		t.Error("proof generated with more elements than challenge bits")
+	}
+
+	prover = auth.SetupFFSProver(private, &fixedChallenger{make([]byte, 2)}, group)
+
+	if _, err := prover.ProofGen(randomness, []byte("block")); !errors.Is(err, auth.ErrFFSKeys) {
+		t.Error("proof generated with a challenge shorter than the key")
+	}
+}
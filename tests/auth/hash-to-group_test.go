@@ -0,0 +1,122 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+)
+
+// RFC 9380 appendix K.1, expand_message_xmd(SHA-256)
+func TestExpandMessage(t *testing.T) {
+	domain := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	vectors := []struct {
+		message  string
+		length   int
+		expected string
+	}{
+		{"", 0x20, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", 0x20, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+		{"abcdef0123456789", 0x20, "eff31487c770a893cfb36f912fbfcbff40d5661771ca4b2cb4eafe524333f5c1"},
+		{"", 0x80, "af84c27ccfd45d41914fdff5df25293e221afc53d8ad2ac06d5e3e29485dadbee0d121587713a3e0dd4d5e69e93eb7cd4f5df4cd103e188cf60cb02edc3edf18eda8576c412b18ffb658e3dd6ec849469b979d444cf7b26911a08e63cf31f9dcc541708d3491184472c2c29bb749d4286b004ceb5ee6b9a7fa5b646c993f0ced"},
+	}
+
+	for _, vector := range vectors {
+		output, err := grouptheory.ExpandMessage([]byte(vector.message), domain, vector.length)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hex.EncodeToString(output) != vector.expected {
+			t.Errorf("expand_message_xmd(%q, %d) does not match", vector.message, vector.length)
+		}
+	}
+}
+
+func nextPrime(start int64, shift uint) *big.Int {
+	prime := big.NewInt(start)
+	prime.Lsh(prime, shift)
+
+	for !prime.ProbablyPrime(20) {
+		prime.Add(prime, grouptheory.One)
+	}
+
+	return prime
+}
+
+func TestHashToGroup(t *testing.T) {
+	// a fixed modulus so the outputs can be pinned
+	modulus := big.NewInt(0)
+	modulus.Mul(nextPrime(3, 255), nextPrime(5, 255))
+	group := grouptheory.NewCompGroup(grouptheory.SetupModRing(modulus))
+	domain := []byte("zerocat test")
+
+	vectors := []struct {
+		message string
+		element string
+		residue string
+	}{
+		{"", "332300779ef91e28463088bcd6d7573e0142a37f163504daeb5193abae3714c17fcad5204b21efbf21b8f9e4977fb152a2b4c66fce71a1bc38e7add937f7d080", "694f3072bcded8dd4f23adbc68464da5514bc1a9f3ae0626ecafdf2506aa242f82d60fd0a5639c001d4652272ba70f1a3aac3f3b82a86a0e20bea96420b1e6f7"},
+		{"abc", "356f40af0aaeadcae9dc58a3f23ce1572c8e30753474506918eac26b82b63c695e77b9f4da04867e82633d40b03af76f1173ee9325d993cee5ea968b779485a73", "35f7396091207e4492d4935b2fe6b136ea0339c41646612df0df89cf8440446cfec207ae8d8159161056f8ec593057dc60dddfff0fd0ef50a042f98beb2952c89"},
+	}
+
+	for _, vector := range vectors {
+		element, err := group.HashToElement(domain, []byte(vector.message))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		residue, err := group.HashToQR(domain, []byte(vector.message))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if element.Text(16) != vector.element || residue.Text(16) != vector.residue {
+			t.Errorf("hash of %q does not match", vector.message)
+		}
+	}
+
+	// a different domain gives an unrelated element
+	other, _ := group.HashToElement([]byte("zerocat other"), nil)
+	element, _ := group.HashToElement(domain, nil)
+
+	if other.Cmp(element) == 0 {
+		t.Error("domains are not separated")
+	}
+}
+
+func TestDeriveFFSKeyPair(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	seed := bytes.Repeat([]byte{7}, 32)
+
+	public, private, err := auth.DeriveFFSKeyPair(32, group, seed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _, _ := auth.DeriveFFSKeyPair(32, group, seed)
+
+	for i := 0; i < len(public); i++ {
+		if public[i].Cmp(again[i]) != 0 {
+			t.Fatal("key pair derivation is not deterministic")
+		}
+	}
+
+	challenger := auth.NewChainChallenger()
+	prover := auth.SetupFFSProver(private, challenger, group)
+	verifier := auth.SetupFFSVerifier(public, challenger, group.Modulus())
+
+	randomness, _ := group.Random()
+	proof, err := prover.ProofGen(randomness, []byte("Hello World!"))
+
+	if err != nil || !verifier.Verify(proof, []byte("Hello World!")) {
+		t.Error("proof from derived key rejected")
+	}
+}
@@ -0,0 +1,158 @@
+package auth_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+)
+
+func TestPedersen(t *testing.T) {
+	group, err := grouptheory.SetupPrimeOrderGroup(1024, 256)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pedersen, err := auth.SetupPedersen(group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, a_opening, err := pedersen.Commit(big.NewInt(40))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, b_opening, err := pedersen.Commit(big.NewInt(2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pedersen.Open(a, a_opening) {
+		t.Error("valid opening rejected")
+	}
+
+	if pedersen.Open(a, b_opening) {
+		t.Error("opening accepted for the wrong commitment")
+	}
+
+	// the sum of the commitments opens to the sum of the messages
+	sum := pedersen.Add(a, b)
+	sum_opening := pedersen.AddOpenings(a_opening, b_opening)
+
+	if sum_opening.Message().Int64() != 42 || !pedersen.Open(sum, sum_opening) {
+		t.Error("homomorphic addition failed")
+	}
+
+	// commitments, openings and proofs survive encoding
+	decoded, err := auth.ParsePedersenCommitment(sum.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded_opening, err := auth.ParsePedersenOpening(sum_opening.Marshal())
+
+	if err != nil || !pedersen.Open(decoded, decoded_opening) {
+		t.Error("decoded opening rejected")
+	}
+
+	proof, err := pedersen.ProveOpening(sum, sum_opening, auth.NewChainChallenger(), []byte("block"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof, err = auth.ParsePedersenProof(proof.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pedersen.VerifyOpening(sum, proof, auth.NewChainChallenger(), []byte("block")) {
+		t.Error("valid proof of opening rejected")
+	}
+
+	if pedersen.VerifyOpening(a, proof, auth.NewChainChallenger(), []byte("block")) {
+		t.Error("proof of opening accepted for the wrong commitment")
+	}
+
+	if pedersen.VerifyOpening(sum, proof, auth.NewChainChallenger(), []byte("other block")) {
+		t.Error("proof of opening accepted for the wrong block")
+	}
+}
+
+func TestChaumPedersen(t *testing.T) {
+	group, err := grouptheory.SetupPrimeOrderGroup(1024, 256)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := group.Generator()
+	h, err := group.DeriveGenerator([]byte("test generator"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := group.RandomExponent()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := group.Exp(g, secret)
+	b := group.Exp(h, secret)
+
+	proof, err := auth.ProveDLEQ(group, auth.NewChainChallenger(), g, h, secret, []byte("block"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof, err = auth.ParseDLEQProof(proof.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !auth.VerifyDLEQ(group, auth.NewChainChallenger(), g, a, h, b, proof, []byte("block")) {
+		t.Error("valid proof rejected")
+	}
+
+	// b under a different exponent must not verify
+	other := group.Exp(h, big.NewInt(7))
+
+	if auth.VerifyDLEQ(group, auth.NewChainChallenger(), g, a, h, other, proof, []byte("block")) {
+		t.Error("proof accepted for unequal logarithms")
+	}
+
+	if auth.VerifyDLEQ(group, auth.NewChainChallenger(), g, a, h, b, proof, []byte("other block")) {
+		t.Error("proof accepted for the wrong block")
+	}
+}
+
+// commitments made under one h can only be opened under the same h, so the derivation must not change
+func TestPedersenGeneratorStable(t *testing.T) {
+	group, err := grouptheory.NewPrimeOrderGroup(big.NewInt(1019), big.NewInt(509), big.NewInt(4))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pedersen, err := auth.SetupPedersen(group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pedersen.H().Int64() != 364 {
+		t.Errorf("got h = %v, want 364", pedersen.H())
+	}
+}
@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+)
+
+func TestSeedBackup(t *testing.T) {
+	seed, err := auth.NewSeed()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := auth.SeedToWords(seed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(strings.Split(words, "-")) != 17 {
+		t.Error("unexpected number of words")
+	}
+
+	recovered, err := auth.SeedFromWords(strings.ToUpper(strings.ReplaceAll(words, "-", " ")))
+
+	if err != nil || !bytes.Equal(recovered, seed) {
+		t.Error("seed did not survive the word list")
+	}
+
+	encoded, _ := auth.SeedToString(seed)
+	recovered, err = auth.SeedFromString(encoded)
+
+	if err != nil || !bytes.Equal(recovered, seed) {
+		t.Error("seed did not survive the encoded string")
+	}
+
+	// a mistyped word must be caught by the checksum
+	fields := strings.Split(words, "-")
+	if fields[3][0] == 'b' {
+		fields[3] = "d" + fields[3][1:]
+	} else {
+		fields[3] = "b" + fields[3][1:]
+	}
+
+	if _, err := auth.SeedFromWords(strings.Join(fields, "-")); !errors.Is(err, auth.ErrChecksum) {
+		t.Error("corrupted word list accepted")
+	}
+
+	if _, err := auth.SeedFromWords("hello-world"); err == nil {
+		t.Error("invalid words accepted")
+	}
+
+	// the last character carries padding bits, so the one before it is changed
+	corrupted := []byte(encoded)
+
+	if corrupted[len(corrupted)-2] == 'A' {
+		corrupted[len(corrupted)-2] = 'B'
+	} else {
+		corrupted[len(corrupted)-2] = 'A'
+	}
+
+	if _, err := auth.SeedFromString(string(corrupted)); err == nil {
+		t.Error("corrupted string accepted")
+	}
+
+	// the same seed rebuilds the same key, a different one does not
+	group, public, private, err := auth.FFSKeyPairFromSeed(seed, 8, 512)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, again_public, _, _ := auth.FFSKeyPairFromSeed(recovered, 8, 512)
+
+	if group.Modulus().Cmp(again.Modulus()) != 0 || !bytes.Equal(auth.NewFFSPublicKey(public, group.Modulus()).Marshal(), auth.NewFFSPublicKey(again_public, again.Modulus()).Marshal()) {
+		t.Error("seed did not reproduce the key")
+	}
+
+	other, _ := auth.NewSeed()
+	different, _, _, _ := auth.FFSKeyPairFromSeed(other, 8, 512)
+
+	if group.Modulus().Cmp(different.Modulus()) == 0 {
+		t.Error("different seeds produced the same key")
+	}
+
+	challenger := auth.NewChainChallenger()
+	prover := auth.SetupFFSProver(private, challenger, group)
+	verifier := auth.SetupFFSVerifier(public, challenger, group.Modulus())
+	randomness, _ := group.Random()
+	proof, err := prover.ProofGen(randomness, []byte("seeded"))
+
+	if err != nil || !verifier.Verify(proof, []byte("seeded")) {
+		t.Error("seeded key failed to prove")
+	}
+
+	if _, _, _, err := auth.FFSKeyPairFromSeed(seed[:16], 8, 512); !errors.Is(err, auth.ErrSeedSize) {
+		t.Error("short seed accepted")
+	}
+}
@@ -0,0 +1,157 @@
+package auth_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+)
+
+// a broken key state that always hands out the same index
+type stuckKeyState struct{}
+
+func (state stuckKeyState) Reserve() (uint32, error) {
+	return 3, nil
+}
+
+func TestXMSS(t *testing.T) {
+	public, private, err := auth.XMSSKeyPair(3)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prover auth.Prover = auth.SetupXMSSProver(private, auth.NewCounterKeyState(0))
+	var verifier auth.Verifier = auth.SetupXMSSVerifier(public)
+
+	for i := 0; i < 8; i++ {
+		proof, err := prover.ProofGen(nil, []byte("Hello World!"))
+
+		if err != nil || proof.Statement().Int64() != int64(i) {
+			t.Fatal("proof was not generated with the next one-time key")
+		}
+
+		if !verifier.Verify(*proof, []byte("Hello World!")) {
+			t.Error("valid proof rejected")
+		}
+
+		if verifier.Verify(*proof, []byte("Hello World?")) {
+			t.Error("proof accepted for a different block")
+		}
+	}
+
+	if _, err := prover.ProofGen(nil, []byte("Hello World!")); !errors.Is(err, auth.ErrKeyExhausted) {
+		t.Error("proof generated after every one-time key was used")
+	}
+
+	// the key can be restored from its encoding and still verifies
+	encoded_public, err := auth.ParseXMSSPublicKey(public.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := auth.ParseXMSSPrivateKey(private.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof, err := auth.SetupXMSSProver(restored, auth.NewCounterKeyState(5)).Sign([]byte("restored"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !auth.SetupXMSSVerifier(encoded_public).Verify(*proof, []byte("restored")) {
+		t.Error("proof from restored key rejected")
+	}
+
+	// claiming a different key index invalidates the signature
+	moved := auth.NewProof(proof.Statement(), proof.Proof())
+	moved.Statement().SetInt64(4)
+
+	if verifier.Verify(*moved, []byte("restored")) {
+		t.Error("proof accepted under a different key index")
+	}
+}
+
+func TestXMSSKeyReuse(t *testing.T) {
+	_, private, err := auth.XMSSKeyPair(2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prover := auth.SetupXMSSProver(private, stuckKeyState{})
+
+	if _, err := prover.Sign([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := prover.Sign([]byte("second")); err != auth.ErrKeyReuse {
+		t.Error("one-time key was reused")
+	}
+
+	// the file state survives restarts of the prover
+	path := filepath.Join(t.TempDir(), "xmss.state")
+
+	for i := 0; i < 4; i++ {
+		proof, err := auth.SetupXMSSProver(private, auth.NewFileKeyState(path)).Sign([]byte("block"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if proof.Statement().Int64() != int64(i) {
+			t.Error("file state handed out an index twice")
+		}
+	}
+
+	if _, err := auth.SetupXMSSProver(private, auth.NewFileKeyState(path)).Sign([]byte("block")); err != auth.ErrKeyExhausted {
+		t.Error("exhausted key was used")
+	}
+}
+
+func TestXMSSSubtrees(t *testing.T) {
+	// two subtrees, so the authentication paths cross from the cached subtree into the upper tree
+	public, private, err := auth.XMSSKeyPair(9)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seeds_only := private.Marshal()[:1+3*32]
+
+	for _, encoded := range [][]byte{private.Marshal(), seeds_only} {
+		restored, err := auth.ParseXMSSPrivateKey(encoded)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(restored.Public().Marshal(), public.Marshal()) {
+			t.Error("restored key has a different root")
+		}
+	}
+
+	verifier := auth.SetupXMSSVerifier(public)
+
+	for _, index := range []uint32{255, 256, 511, 0} {
+		proof, err := auth.SetupXMSSProver(private, auth.NewCounterKeyState(index)).Sign([]byte("block"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !verifier.Verify(*proof, []byte("block")) {
+			t.Errorf("proof with one-time key %d rejected", index)
+		}
+	}
+
+	if _, _, err := auth.XMSSKeyPair(auth.MaxXMSSHeight + 1); err != auth.ErrHeight {
+		t.Error("tree taller than the maximum accepted")
+	}
+}
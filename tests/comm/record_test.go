@@ -0,0 +1,191 @@
+package comm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/comm"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestRecordEncoding(t *testing.T) {
+	record := comm.NewRecord(comm.ContentEncrypted, enc.KEMSymmetric, enc.DEMAESGCM, 7, []byte("statement"), nil, []byte("block"))
+	data := record.Marshal()
+
+	parsed, err := comm.ParseRecord(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Version() != comm.RecordVersion || parsed.Content() != comm.ContentEncrypted || parsed.KEM() != enc.KEMSymmetric || parsed.DEM() != enc.DEMAESGCM || parsed.Sequence() != 7 {
+		t.Error("record header changed in transit")
+	}
+
+	if len(parsed.Fields()) != 3 || string(parsed.Fields()[0]) != "statement" || len(parsed.Fields()[1]) != 0 || string(parsed.Fields()[2]) != "block" {
+		t.Error("record fields changed in transit")
+	}
+
+	// records are read one at a time from a stream
+	stream := bytes.NewBuffer(append(append([]byte{}, data...), data...))
+
+	for i := 0; i < 2; i++ {
+		if _, err := comm.ReadRecord(stream); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if stream.Len() != 0 {
+		t.Error("records left bytes behind")
+	}
+
+	if _, err := comm.ParseRecord(data[:len(data)-1]); err != comm.ErrRecord {
+		t.Errorf("truncated record: got %v, want %v", err, comm.ErrRecord)
+	}
+
+	future := append([]byte{}, data...)
+	future[0] = comm.RecordVersion + 1
+
+	if _, err := comm.ParseRecord(future); err != comm.ErrVersion {
+		t.Errorf("future version: got %v, want %v", err, comm.ErrVersion)
+	}
+
+	if _, err := comm.ProofMessage(data); err != comm.ErrContentType {
+		t.Error("encrypted record read as a proof")
+	}
+
+	message, err := comm.ProofMessage(comm.NewRecord(comm.ContentProof, 0, 0, 0, nil, nil, []byte("block")).Marshal())
+
+	if err != nil || string(message) != "block" {
+		t.Error("proof message not recovered")
+	}
+}
+
+// seals a payload and returns the record
+func sealRecord(t *testing.T, wrapper *comm.EncapsulationWrapper, buffer *bytes.Buffer, payload string) []byte {
+	buffer.WriteString(payload)
+	record, err := wrapper.Wrap()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append([]byte{}, record...)
+}
+
+func TestRecordMismatch(t *testing.T) {
+	sealer := newAESSealer()
+	_, receiver := newSessionSealers(t)
+
+	buffer := new(bytes.Buffer)
+	aes_wrapper := comm.NewEncapsulationWrapper(sealer, buffer)
+	ctr_wrapper := comm.NewEncapsulationWrapper(enc.NewKEMDEM(enc.NewAESEncapsulator(enc.NewSha256Deriver([]byte("secret"))), enc.NewCTRHMACDEM()), buffer)
+
+	cases := []struct {
+		name    string
+		record  []byte
+		wrapper func(*bytes.Buffer) *comm.DecryptionWrapper
+		err     error
+	}{
+		{
+			"kem",
+			sealRecord(t, aes_wrapper, buffer, "hello"),
+			func(wire *bytes.Buffer) *comm.DecryptionWrapper {
+				return comm.NewDecryptionWrapper(receiver, wire)
+			},
+			comm.ErrAlgorithm,
+		},
+		{
+			"dem",
+			sealRecord(t, ctr_wrapper, buffer, "hello"),
+			func(wire *bytes.Buffer) *comm.DecryptionWrapper {
+				return comm.NewDecryptionWrapper(sealer, wire)
+			},
+			comm.ErrAlgorithm,
+		},
+		{
+			"sequence",
+			sealRecord(t, aes_wrapper, buffer, "hello"),
+			func(wire *bytes.Buffer) *comm.DecryptionWrapper {
+				return comm.NewDecryptionWrapper(sealer, wire)
+			},
+			comm.ErrSequence,
+		},
+	}
+
+	for _, c := range cases {
+		wire := bytes.NewBuffer(c.record)
+
+		if _, err := c.wrapper(wire).Wrap(); err != c.err {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+		}
+
+		if wire.Len() != 0 {
+			t.Errorf("%s: rejected record was not consumed", c.name)
+		}
+	}
+}
+
+func TestRecordBinding(t *testing.T) {
+	sealer := newAESSealer()
+
+	buffer := new(bytes.Buffer)
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(sealer, buffer)
+
+	first := sealRecord(t, encapsulation_wrapper, buffer, "hello")
+	second := sealRecord(t, encapsulation_wrapper, buffer, "world")
+
+	// the header is the additional data, so the second body does not open under the first header
+	spliced := append(append([]byte{}, first[:comm.RecordHeaderSize]...), second[comm.RecordHeaderSize:]...)
+	decryption_wrapper := comm.NewDecryptionWrapper(sealer, bytes.NewBuffer(spliced))
+
+	if _, err := decryption_wrapper.Wrap(); err != enc.ErrOpen {
+		t.Errorf("got %v, want %v", err, enc.ErrOpen)
+	}
+
+	// a capsule of another size is refused by the KEM
+	resized := comm.NewRecord(comm.ContentEncrypted, enc.KEMSymmetric, enc.DEMAESGCM, 0, nil, make([]byte, 16), make([]byte, 64))
+	decryption_wrapper = comm.NewDecryptionWrapper(sealer, bytes.NewBuffer(resized.Marshal()))
+
+	if _, err := decryption_wrapper.Wrap(); err != enc.ErrCapsuleSize {
+		t.Errorf("got %v, want %v", err, enc.ErrCapsuleSize)
+	}
+
+	decryption_wrapper = comm.NewDecryptionWrapper(sealer, bytes.NewBuffer(append(first, second...)))
+
+	for _, want := range []string{"hello", "world"} {
+		payload, err := decryption_wrapper.Wrap()
+
+		if err != nil || string(payload) != want {
+			t.Errorf("got %q, %v, want %q", payload, err, want)
+		}
+	}
+}
+
+func TestFFSRecordParameters(t *testing.T) {
+	group := gt.SetupCompGroup(512)
+	public, _, err := auth.FFSKeyPair(16, group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := auth.SetupFFSVerifier(public, auth.NewChainChallenger(), group.Modulus())
+
+	// a proof over a smaller modulus
+	record := comm.NewRecord(comm.ContentProof, 0, 0, 0, make([]byte, 32), make([]byte, 32), []byte("id"))
+	output_wrapper := comm.NewFFSOutputWrapper(verifier, bytes.NewBuffer(record.Marshal()))
+
+	if _, err := output_wrapper.Wrap(); err != comm.ErrParameters {
+		t.Errorf("got %v, want %v", err, comm.ErrParameters)
+	}
+
+	record = comm.NewRecord(comm.ContentEncrypted, 0, 0, 0, make([]byte, 64), make([]byte, 64), []byte("id"))
+	output_wrapper = comm.NewFFSOutputWrapper(verifier, bytes.NewBuffer(record.Marshal()))
+
+	if _, err := output_wrapper.Wrap(); err != comm.ErrContentType {
+		t.Errorf("got %v, want %v", err, comm.ErrContentType)
+	}
+}
@@ -8,8 +8,15 @@ import (
 	gt "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
 	"github.com/SimonHorrocks/Zerocat/pkg/comm"
 	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc/hpke"
 )
 
+// the sealer the listener and shell use, an AES KEM from the shared master key with AES-GCM
+func newAESSealer() *enc.KEMDEM {
+	encapsulator := enc.NewAESEncapsulator(enc.NewSha256Deriver([]byte("secret")))
+	return enc.NewKEMDEM(encapsulator, enc.NewGCMDEM())
+}
+
 func TestFFSWrappers(t *testing.T) {
 	group := gt.SetupCompGroup(3072)
 	public, private, err := auth.FFSKeyPair(128, group)
@@ -23,14 +30,13 @@ func TestFFSWrappers(t *testing.T) {
 	prover := auth.SetupFFSProver(private, challenger, group)
 	verifier := auth.SetupFFSVerifier(public, challenger, group.Modulus())
 
-	deriver := enc.NewSha256Deriver([]byte("secret"))
-	encapsulator := enc.NewAESEncapsulator(deriver)
+	sealer := newAESSealer()
 
 	buffer := new(bytes.Buffer)
 
 	input_wrapper := comm.NewFFSInputWrapper(prover, buffer)
-	encapsulation_wrapper := comm.NewEncapsulationWrapper(encapsulator, buffer)
-	decryption_wrapper := comm.NewDecryptionWrapper(encapsulator, buffer, 12, 32)
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(sealer, buffer)
+	decryption_wrapper := comm.NewDecryptionWrapper(sealer, buffer)
 	output_wrapper := comm.NewFFSOutputWrapper(verifier, buffer)
 
 	buffer.Write([]byte("Hello World!!"))
@@ -69,3 +75,157 @@ func TestFFSWrappers(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestHPKEWrappers(t *testing.T) {
+	suite, err := hpke.NewSuite(hpke.KEMX25519, hpke.KDFHKDFSHA256, hpke.AEADAES128GCM)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipient, err := suite.GenerateKeyPair()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := new(bytes.Buffer)
+
+	// the sending side only holds the public key
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(hpke.NewSealer(suite, recipient.PublicKey(), []byte("zerocat")), buffer)
+	decryption_wrapper := comm.NewDecryptionWrapper(hpke.NewRecipientSealer(suite, recipient, []byte("zerocat")), buffer)
+
+	buffer.Write([]byte("Hello World!!"))
+
+	wrapped, err := encapsulation_wrapper.Wrap()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer.Write(wrapped)
+
+	wrapped, err = decryption_wrapper.Wrap()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(wrapped, []byte("Hello World!!")) {
+		t.Fail()
+	}
+}
+
+func newSessionSealers(tb testing.TB) (*enc.SessionSealer, *enc.SessionSealer) {
+	key := bytes.Repeat([]byte{0x09}, 32)
+	sender, err := enc.NewGCMSession(key, true)
+
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	receiver, err := enc.NewGCMSession(key, false)
+
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return enc.NewSessionSealer(sender), enc.NewSessionSealer(receiver)
+}
+
+func TestSessionWrappers(t *testing.T) {
+	sender, receiver := newSessionSealers(t)
+	buffer := new(bytes.Buffer)
+
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(sender, buffer)
+	decryption_wrapper := comm.NewDecryptionWrapper(receiver, buffer)
+
+	for _, message := range []string{"Hello World!!", "", "a second, longer message that needs a larger frame"} {
+		buffer.WriteString(message)
+		wrapped, err := encapsulation_wrapper.Wrap()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffer.Write(wrapped)
+		comm.Release(wrapped)
+
+		wrapped, err = decryption_wrapper.Wrap()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(wrapped) != message {
+			t.Errorf("got %q, want %q", wrapped, message)
+		}
+
+		comm.Release(wrapped)
+	}
+
+	// a replayed frame is refused by the session
+	buffer.WriteString("replayed")
+	wrapped, _ := encapsulation_wrapper.Wrap()
+	frame := append([]byte{}, wrapped...)
+	buffer.Write(frame)
+	buffer.Write(frame)
+
+	if _, err := decryption_wrapper.Wrap(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decryption_wrapper.Wrap(); err == nil {
+		t.Error("replayed frame accepted")
+	}
+}
+
+// one frame through both wrappers per iteration
+func benchmarkWrappers(b *testing.B, sender, receiver enc.Sealer) {
+	message := bytes.Repeat([]byte{0x42}, 1024)
+	input := bytes.NewReader(message)
+	wire := new(bytes.Buffer)
+
+	encapsulation_wrapper := comm.NewEncapsulationWrapper(sender, input)
+	decryption_wrapper := comm.NewDecryptionWrapper(receiver, wire)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(message)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		input.Reset(message)
+		wire.Reset()
+
+		frame, err := encapsulation_wrapper.Wrap()
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		wire.Write(frame)
+		comm.Release(frame)
+
+		plaintext, err := decryption_wrapper.Wrap()
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		comm.Release(plaintext)
+	}
+}
+
+// every frame derives a fresh key, so the AES and GCM state are rebuilt on both sides and a capsule is
+// allocated for the sender. those five allocations are the floor for this sealer, everything else is
+// pooled
+func BenchmarkAESWrappers(b *testing.B) {
+	sealer := newAESSealer()
+	benchmarkWrappers(b, sealer, sealer)
+}
+
+// the session key is fixed between rekeys, so frames are sealed without allocating
+func BenchmarkSessionWrappers(b *testing.B) {
+	sender, receiver := newSessionSealers(b)
+	benchmarkWrappers(b, sender, receiver)
+}
@@ -0,0 +1,201 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+// multiplication in GF(2 ** 128) with the bit order used by GHASH
+func gfMul(x, y [16]byte) [16]byte {
+	var z [16]byte
+	v := y
+
+	for i := 0; i < 128; i++ {
+		if x[i/8]>>(7-i%8)&1 == 1 {
+			for j := 0; j < 16; j++ {
+				z[j] ^= v[j]
+			}
+		}
+
+		carry := v[15] & 1
+
+		for j := 15; j > 0; j-- {
+			v[j] = v[j]>>1 | v[j-1]<<7
+		}
+
+		v[0] >>= 1
+
+		if carry == 1 {
+			v[0] ^= 0xe1
+		}
+	}
+
+	return z
+}
+
+// x ** (2 ** 128 - 2)
+func gfInverse(x [16]byte) [16]byte {
+	result := [16]byte{0x80}
+	square := x
+
+	for i := 1; i < 128; i++ {
+		square = gfMul(square, square)
+		result = gfMul(result, square)
+	}
+
+	return result
+}
+
+func gfAdd(values ...[16]byte) [16]byte {
+	var sum [16]byte
+
+	for _, value := range values {
+		for j := 0; j < 16; j++ {
+			sum[j] ^= value[j]
+		}
+	}
+
+	return sum
+}
+
+func encryptBlock(t *testing.T, key []byte, input [16]byte) [16]byte {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output [16]byte
+	block.Encrypt(output[:], input[:])
+
+	return output
+}
+
+// builds a two block GCM ciphertext with a tag that is valid under both keys by solving
+// C1 * H ** 3 + C2 * H ** 2 + L * H + E for C1 so that both tags agree
+func salamander(t *testing.T, first, second []byte, nonce []byte) []byte {
+	var zero, counter, length, c2 [16]byte
+	copy(counter[:], nonce)
+	counter[15] = 1
+	length[14] = 1 // 256 bits of ciphertext, no additional data
+	copy(c2[:], "fixed second blk")
+
+	h1, h2 := encryptBlock(t, first, zero), encryptBlock(t, second, zero)
+	e1, e2 := encryptBlock(t, first, counter), encryptBlock(t, second, counter)
+	h1_2, h2_2 := gfMul(h1, h1), gfMul(h2, h2)
+	h1_3, h2_3 := gfMul(h1_2, h1), gfMul(h2_2, h2)
+
+	numerator := gfAdd(gfMul(c2, gfAdd(h1_2, h2_2)), gfMul(length, gfAdd(h1, h2)), e1, e2)
+	c1 := gfMul(numerator, gfInverse(gfAdd(h1_3, h2_3)))
+
+	tag := gfAdd(gfMul(c1, h1_3), gfMul(c2, h1_2), gfMul(length, h1), e1)
+
+	output := make([]byte, 0)
+	output = append(output, nonce...)
+	output = append(output, c1[:]...)
+	output = append(output, c2[:]...)
+
+	return append(output, tag[:]...)
+}
+
+func TestGCMIsNotCommitting(t *testing.T) {
+	first, second := bytes.Repeat([]byte{0x01}, 32), bytes.Repeat([]byte{0x02}, 32)
+	ciphertext := salamander(t, first, second, make([]byte, 12))
+	dem := enc.NewGCMDEM()
+
+	a, err := dem.Open(first, ciphertext, nil)
+
+	if err != nil {
+		t.Fatal("salamander did not open under the first key")
+	}
+
+	b, err := dem.Open(second, ciphertext, nil)
+
+	if err != nil {
+		t.Fatal("salamander did not open under the second key")
+	}
+
+	if bytes.Equal(a, b) {
+		t.Error("the two keys should give different plaintexts")
+	}
+
+	// the commitment prefix stops the same trick, the ciphertext is tied to the key it was made for
+	committing := enc.NewCommittingDEM(dem)
+	sealed, _ := committing.Seal(first, []byte("anything"), nil)
+	forged := append(append([]byte{}, sealed[:32]...), ciphertext...)
+
+	if _, err := committing.Open(second, forged, nil); !errors.Is(err, enc.ErrCommitment) {
+		t.Error("committing DEM opened under a key it was not committed to")
+	}
+}
+
+func TestDEMs(t *testing.T) {
+	key, other := bytes.Repeat([]byte{0x03}, 32), bytes.Repeat([]byte{0x04}, 32)
+	plaintext := []byte("the quick brown fox jumped over the lazy dog")
+	header := []byte("header")
+
+	ids := []enc.DEMID{enc.DEMAESGCM, enc.DEMAESCTRHMAC, enc.DEMCommitting | enc.DEMAESGCM, enc.DEMCommitting | enc.DEMAESCTRHMAC}
+
+	for _, id := range ids {
+		dem, err := enc.DEMByID(id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dem.Algorithm() != id {
+			t.Errorf("%#x: wrong algorithm id %#x", id, dem.Algorithm())
+		}
+
+		ciphertext, err := dem.Seal(key, plaintext, header)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opened, err := dem.Open(key, ciphertext, header)
+
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("%#x: round trip failed", id)
+		}
+
+		if _, err := dem.Open(other, ciphertext, header); err == nil {
+			t.Errorf("%#x: opened under another key", id)
+		}
+
+		if _, err := dem.Open(key, ciphertext, []byte("headex")); err == nil {
+			t.Errorf("%#x: altered header accepted", id)
+		}
+
+		tampered := append([]byte{}, ciphertext...)
+		tampered[len(tampered)/2] ^= 1
+
+		if _, err := dem.Open(key, tampered, header); err == nil {
+			t.Errorf("%#x: tampered ciphertext accepted", id)
+		}
+
+		if _, err := dem.Open(key, ciphertext[:4], header); err == nil {
+			t.Errorf("%#x: truncated ciphertext accepted", id)
+		}
+
+		// every DEM slots into the KEM/DEM scheme
+		scheme := enc.NewKEMDEM(enc.NewAESEncapsulator(enc.NewSha256Deriver([]byte("secret"))), dem)
+		capsule, sealed, err := scheme.Seal(plaintext, header)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opened, err := scheme.Open(capsule, sealed, header); err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("%#x: KEM/DEM round trip failed", id)
+		}
+	}
+
+	if _, err := enc.DEMByID(0x0042); !errors.Is(err, enc.ErrDEM) {
+		t.Error("unknown DEM accepted")
+	}
+}
@@ -0,0 +1,204 @@
+package enc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func newDRBG(t *testing.T, personalization string) *enc.HMACDRBG {
+	entropy := bytes.Repeat([]byte{0x42}, 32)
+	drbg, err := enc.NewHMACDRBG(entropy, []byte("nonce"), []byte(personalization))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return drbg
+}
+
+// CAVP HMAC_DRBG SHA-256, no prediction resistance, no personalization or additional input, COUNT = 0
+func TestHMACDRBGVector(t *testing.T) {
+	entropy := decodeHex(t, "ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488")
+	nonce := decodeHex(t, "659ba96c601dc69fc902940805ec0ca8")
+	expected := decodeHex(t, "e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89"+
+		"d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc1"+
+		"07694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668"+
+		"961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8")
+
+	drbg, err := enc.NewHMACDRBG(entropy, nonce, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := make([]byte, 128)
+
+	if err := drbg.Generate(output, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := drbg.Generate(output, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(output, expected) {
+		t.Errorf("got %x", output)
+	}
+}
+
+func TestHMACDRBG(t *testing.T) {
+	if _, err := enc.NewHMACDRBG(make([]byte, 16), nil, nil); !errors.Is(err, enc.ErrEntropy) {
+		t.Error("short entropy accepted")
+	}
+
+	a, b := newDRBG(t, ""), newDRBG(t, "")
+	first, second := make([]byte, 100), make([]byte, 100)
+	a.Read(first)
+	b.Read(second)
+
+	if !bytes.Equal(first, second) {
+		t.Error("equal seeds produced different output")
+	}
+
+	newDRBG(t, "other").Read(second)
+
+	if bytes.Equal(first, second) {
+		t.Error("personalization did not change the output")
+	}
+
+	// reseeding diverges from an identical generator
+	a.Reseed(bytes.Repeat([]byte{0x07}, 32), nil)
+	a.Read(first)
+	b.Read(second)
+
+	if bytes.Equal(first, second) {
+		t.Error("reseed did not change the output")
+	}
+
+	if err := a.Generate(make([]byte, 1<<17), nil); !errors.Is(err, enc.ErrRequestSize) {
+		t.Error("oversized request accepted")
+	}
+
+	// reads larger than one request are split up
+	if n, err := a.Read(make([]byte, 1<<17)); err != nil || n != 1<<17 {
+		t.Error("large read failed")
+	}
+
+	// prediction resistance draws from the source before every request
+	c := newDRBG(t, "")
+	c.SetSource(bytes.NewReader(nil), true)
+
+	if err := c.Generate(first, nil); err == nil {
+		t.Error("generated without entropy under prediction resistance")
+	}
+
+	c, d := newDRBG(t, ""), newDRBG(t, "")
+	c.SetSource(bytes.NewReader(bytes.Repeat([]byte{0x01}, 64)), true)
+	c.Generate(first, nil)
+	d.Generate(second, nil)
+
+	if bytes.Equal(first, second) {
+		t.Error("prediction resistance did not reseed")
+	}
+}
+
+// the same seed must reproduce groups, keys and capsules
+func TestHMACDRBGConcurrent(t *testing.T) {
+	drbg := newDRBG(t, "concurrent")
+	outputs := make([][]byte, 64)
+	var group sync.WaitGroup
+
+	for i := range outputs {
+		group.Add(1)
+
+		go func(i int) {
+			defer group.Done()
+
+			outputs[i] = make([]byte, 32)
+			drbg.Read(outputs[i])
+		}(i)
+	}
+
+	group.Wait()
+	seen := make(map[string]bool)
+
+	for _, output := range outputs {
+		if seen[string(output)] {
+			t.Fatal("two readers were handed the same output")
+		}
+
+		seen[string(output)] = true
+	}
+}
+
+func TestSeededFixtures(t *testing.T) {
+	groups := make([]*grouptheory.CompositeMulGroup, 2)
+	publics := make([][]byte, 2)
+	capsules := make([][]byte, 2)
+
+	for i := 0; i < 2; i++ {
+		drbg := newDRBG(t, "fixtures")
+		groups[i] = grouptheory.SetupCompGroupFrom(drbg, 512)
+
+		public, _, err := auth.FFSKeyPairFrom(drbg, 8, groups[i])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key := auth.NewFFSPublicKey(public, groups[i].Modulus())
+		publics[i] = key.Marshal()
+
+		encapsulator := enc.NewAESEncapsulatorFrom(enc.NewSha256Deriver([]byte("secret")), drbg)
+		_, capsules[i], _, err = encapsulator.Encapsulate([]byte("message"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if groups[0].Modulus().Cmp(groups[1].Modulus()) != 0 {
+		t.Error("seeded groups differ")
+	}
+
+	if groups[0].Modulus().BitLen() != 512 {
+		t.Error("seeded modulus has the wrong size")
+	}
+
+	if !bytes.Equal(publics[0], publics[1]) {
+		t.Error("seeded keys differ")
+	}
+
+	if !bytes.Equal(capsules[0], capsules[1]) {
+		t.Error("seeded capsules differ")
+	}
+
+	order, err := grouptheory.SetupPrimeOrderGroupFrom(newDRBG(t, "order"), 512, 128)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := grouptheory.SetupPrimeOrderGroupFrom(newDRBG(t, "order"), 512, 128)
+
+	if order.Modulus().Cmp(again.Modulus()) != 0 || order.Generator().Cmp(again.Generator()) != 0 {
+		t.Error("seeded prime order groups differ")
+	}
+}
@@ -0,0 +1,72 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestECDHKEM(t *testing.T) {
+	for _, curve := range []ecdh.Curve{ecdh.X25519(), ecdh.P256()} {
+		name := enc.CurveName(curve)
+
+		if found, err := enc.CurveByName(name); err != nil || found != curve {
+			t.Errorf("%s: curve name does not round trip", name)
+		}
+
+		private, err := enc.ECDHKeyPair(curve)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sender := enc.NewECDHEncapsulator(private.PublicKey())
+		receiver := enc.NewECDHDecapsulator(private)
+
+		ciphertext, capsule, nonce, err := sender.Encapsulate([]byte("the quick brown fox jumped over the lazy dog"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(capsule) != len(private.PublicKey().Bytes()) {
+			t.Errorf("%s: capsule is not an ephemeral public key", name)
+		}
+
+		// the sending side holds no secret that opens the message
+		if _, err := sender.Decrypt(append([]byte{}, ciphertext...), capsule, nonce); !errors.Is(err, enc.ErrNoPrivateKey) {
+			t.Errorf("%s: sender was able to decapsulate", name)
+		}
+
+		// nor does any other private key
+		other, _ := enc.ECDHKeyPair(curve)
+
+		if _, err := enc.NewECDHDecapsulator(other).Decrypt(append([]byte{}, ciphertext...), capsule, nonce); err == nil {
+			t.Errorf("%s: wrong private key decapsulated", name)
+		}
+
+		plaintext, err := receiver.Decrypt(ciphertext, capsule, nonce)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(plaintext, []byte("the quick brown fox jumped over the lazy dog")) {
+			t.Errorf("%s: round trip failed", name)
+		}
+
+		// every message uses a fresh ephemeral key
+		_, second, _, _ := sender.Encapsulate([]byte("again"))
+
+		if bytes.Equal(capsule, second) {
+			t.Errorf("%s: ephemeral key reused", name)
+		}
+	}
+
+	if _, err := enc.CurveByName("p384"); !errors.Is(err, enc.ErrCurve) {
+		t.Error("unknown curve accepted")
+	}
+}
@@ -0,0 +1,196 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestEnvelope(t *testing.T) {
+	master := enc.NewSha256Deriver([]byte("team master"))
+	alice_key, _ := enc.ECDHKeyPair(ecdh.X25519())
+	bob_key, _ := enc.ECDHKeyPair(ecdh.P256())
+	carol_key, _ := enc.ECDHKeyPair(ecdh.X25519())
+
+	recipient := func(kem enc.KEM, key_id []byte) *enc.Recipient {
+		r, err := enc.NewRecipient(kem, key_id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	team := recipient(enc.NewAESEncapsulator(master), []byte("team"))
+	alice := recipient(enc.NewECDHDecapsulator(alice_key), nil)
+	bob := recipient(enc.NewHybridDecapsulator(master, bob_key), nil)
+	carol := recipient(enc.NewECDHDecapsulator(carol_key), nil)
+	stranger_key, _ := enc.ECDHKeyPair(ecdh.X25519())
+	stranger := recipient(enc.NewECDHDecapsulator(stranger_key), nil)
+
+	if _, err := enc.NewRecipient(enc.NewAESEncapsulator(master), nil); !errors.Is(err, enc.ErrKeyID) {
+		t.Error("symmetric recipient without a key id accepted")
+	}
+
+	// senders only need public keys
+	alice_public := recipient(enc.NewECDHEncapsulator(alice_key.PublicKey()), nil)
+	bob_public := recipient(enc.NewHybridEncapsulator(master, bob_key.PublicKey()), nil)
+
+	if !bytes.Equal(alice_public.KeyID(), alice.KeyID()) {
+		t.Fatal("key id differs between the public and private sides")
+	}
+
+	plaintext := []byte("release artifact")
+	dem := enc.NewCommittingDEM(enc.NewGCMDEM())
+	envelope, err := enc.SealEnvelope(dem, plaintext, []byte("v1.2.0"), team, alice_public, bob_public)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := enc.SealEnvelope(dem, plaintext, nil, alice, alice_public); !errors.Is(err, enc.ErrDuplicateRecipient) {
+		t.Error("duplicate recipient accepted")
+	}
+
+	envelope, err = enc.ParseEnvelope(envelope.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(envelope.Recipients()) != 3 {
+		t.Fatalf("%d stanzas", len(envelope.Recipients()))
+	}
+
+	for name, holder := range map[string]*enc.Recipient{"team": team, "alice": alice, "bob": bob} {
+		opened, err := envelope.Open(holder, []byte("v1.2.0"))
+
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("%s could not open: %v", name, err)
+		}
+	}
+
+	if _, err := envelope.Open(alice, []byte("v1.2.1")); !errors.Is(err, enc.ErrOpen) {
+		t.Error("opened with different additional data")
+	}
+
+	if _, err := envelope.Open(carol, []byte("v1.2.0")); !errors.Is(err, enc.ErrNoRecipient) {
+		t.Error("stranger opened the envelope")
+	}
+
+	// adding and removing recipients leaves the body untouched
+	body := append([]byte{}, envelope.Ciphertext()...)
+
+	if err := envelope.AddRecipient(alice, carol); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := envelope.AddRecipient(alice, carol); !errors.Is(err, enc.ErrDuplicateRecipient) {
+		t.Error("recipient added twice")
+	}
+
+	if err := envelope.AddRecipient(stranger, stranger); !errors.Is(err, enc.ErrNoRecipient) {
+		t.Error("recipient added by a non holder")
+	}
+
+	if opened, err := envelope.Open(carol, []byte("v1.2.0")); err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("added recipient could not open: %v", err)
+	}
+
+	// alice's stanza follows the team's
+	held := envelope.Recipients()
+	removed := held[1]
+
+	if err := envelope.RemoveRecipient(alice.KeyID()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a slice handed out before the removal still shows what it did
+	if held[1] != removed || len(envelope.Recipients()) != len(held)-1 {
+		t.Error("removing a recipient changed a held slice of stanzas")
+	}
+
+	if err := envelope.RemoveRecipient(alice.KeyID()); !errors.Is(err, enc.ErrNoRecipient) {
+		t.Error("recipient removed twice")
+	}
+
+	if _, err := envelope.Open(alice, []byte("v1.2.0")); !errors.Is(err, enc.ErrNoRecipient) {
+		t.Error("removed recipient opened the envelope")
+	}
+
+	if !bytes.Equal(envelope.Ciphertext(), body) {
+		t.Error("body changed when recipients changed")
+	}
+}
+
+func TestEnvelopeTampering(t *testing.T) {
+	key, _ := enc.ECDHKeyPair(ecdh.X25519())
+	holder, _ := enc.NewRecipient(enc.NewECDHDecapsulator(key), nil)
+	dem := enc.NewCommittingDEM(enc.NewGCMDEM())
+
+	first, _ := enc.SealEnvelope(dem, []byte("first"), nil, holder)
+	second, _ := enc.SealEnvelope(dem, []byte("second"), nil, holder)
+
+	// a stanza moved onto another envelope's body does not unwrap
+	first_bytes, second_bytes := first.Marshal(), second.Marshal()
+	first_stanzas := first_bytes[:len(first_bytes)-4-len(first.Ciphertext())]
+	second_body := second_bytes[len(second_bytes)-4-len(second.Ciphertext()):]
+
+	spliced, err := enc.ParseEnvelope(append(append([]byte{}, first_stanzas...), second_body...))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := spliced.Open(holder, nil); !errors.Is(err, enc.ErrOpen) {
+		t.Error("stanza moved between envelopes")
+	}
+
+	tampered := append([]byte{}, first_bytes...)
+	tampered[len(tampered)-1] ^= 1
+	envelope, _ := enc.ParseEnvelope(tampered)
+
+	if _, err := envelope.Open(holder, nil); err == nil {
+		t.Error("tampered body opened")
+	}
+
+	// a second stanza for the same key id is refused rather than shadowing the first
+	stanza := first_bytes[6 : len(first_bytes)-4-len(first.Ciphertext())]
+	planted := append([]byte{}, first_bytes[:6]...)
+	planted[5] = 2
+	planted = append(append(append(planted, stanza...), stanza...), first_bytes[6+len(stanza):]...)
+
+	if _, err := enc.ParseEnvelope(planted); !errors.Is(err, enc.ErrDuplicateRecipient) {
+		t.Error("envelope with a duplicate key id parsed")
+	}
+
+	// a DEM that does not commit to its key is wrapped when sealing and refused when parsing
+	plain, err := enc.SealEnvelope(enc.NewGCMDEM(), []byte("first"), nil, holder)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain_bytes := plain.Marshal()
+
+	if enc.DEMID(binary.BigEndian.Uint16(plain_bytes))&enc.DEMCommitting == 0 {
+		t.Error("envelope sealed without a committing DEM")
+	}
+
+	plain_bytes[0] &^= 0x80
+
+	if _, err := enc.ParseEnvelope(plain_bytes); !errors.Is(err, enc.ErrNotCommitting) {
+		t.Error("envelope without a committing DEM parsed")
+	}
+
+	for _, malformed := range [][]byte{nil, first_bytes[:5], first_bytes[:len(first_bytes)-1], append(first_bytes, 0)} {
+		if _, err := enc.ParseEnvelope(malformed); !errors.Is(err, enc.ErrMalformed) {
+			t.Errorf("malformed envelope of %d bytes accepted", len(malformed))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package enc_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func byteRange(start, end int) []byte {
+	output := make([]byte, 0)
+
+	for i := start; i <= end; i++ {
+		output = append(output, byte(i))
+	}
+
+	return output
+}
+
+// RFC 5869 appendix A test cases 1 to 3
+func TestHKDFVectors(t *testing.T) {
+	vectors := []struct {
+		secret       []byte
+		salt         []byte
+		info         []byte
+		length       int
+		pseudorandom string
+		expected     string
+	}{
+		{
+			bytes.Repeat([]byte{0x0b}, 22), byteRange(0x00, 0x0c), byteRange(0xf0, 0xf9), 42,
+			"077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+			"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		},
+		{
+			byteRange(0x00, 0x4f), byteRange(0x60, 0xaf), byteRange(0xb0, 0xff), 82,
+			"06a6b88c5853361a06104c9ceb35b45cef760014904671014a193f40c15fc244",
+			"b11e398dc80327a1c8e7f78c596a49344f012eda2d4efad8a050cc4c19afa97c59045a99cac7827271cb41c65e590e09da3275600c2f09b8367793a9aca3db71cc30c58179ec3e87c14c01d5c1f3434f1d87",
+		},
+		{
+			bytes.Repeat([]byte{0x0b}, 22), nil, nil, 42,
+			"19ef24a32c717b167f33a91d6f648bdf96596776afdb6377ac434c1c293ccb04",
+			"8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+		},
+	}
+
+	for i, vector := range vectors {
+		pseudorandom := enc.HKDFExtract(vector.salt, vector.secret)
+
+		if !bytes.Equal(pseudorandom, decodeHex(t, vector.pseudorandom)) {
+			t.Errorf("case %d: got PRK %x", i+1, pseudorandom)
+		}
+
+		output, err := enc.HKDF(vector.salt, vector.secret, vector.info, vector.length)
+
+		if err != nil || !bytes.Equal(output, decodeHex(t, vector.expected)) {
+			t.Errorf("case %d: got OKM %x", i+1, output)
+		}
+	}
+
+	if _, err := enc.HKDFExpand(make([]byte, 32), nil, 255*32+1); !errors.Is(err, enc.ErrHKDFLength) {
+		t.Error("overlong output accepted")
+	}
+}
+
+func TestHKDFDeriver(t *testing.T) {
+	deriver := enc.NewHKDFDeriver([]byte("master"), []byte("salt"), []byte("zerocat test"))
+	capsule := []byte("capsule")
+
+	if !bytes.Equal(deriver.Derive(capsule), deriver.Derive(capsule)) {
+		t.Error("derivation is not deterministic")
+	}
+
+	keys := deriver.DeriveSessionKeys(capsule)
+	all := [][]byte{deriver.Derive(capsule), deriver.Derive([]byte("other")), keys.InitiatorKey(), keys.InitiatorMAC(), keys.ResponderKey(), keys.ResponderMAC()}
+
+	for i := 0; i < len(all); i++ {
+		for j := i + 1; j < len(all); j++ {
+			if bytes.Equal(all[i], all[j]) {
+				t.Errorf("keys %d and %d are equal", i, j)
+			}
+		}
+	}
+
+	// salt and info each separate the derived keys
+	for _, other := range []*enc.HKDFDeriver{
+		enc.NewHKDFDeriver([]byte("master"), []byte("pepper"), []byte("zerocat test")),
+		enc.NewHKDFDeriver([]byte("master"), []byte("salt"), []byte("zerocat other")),
+	} {
+		if bytes.Equal(other.Derive(capsule), deriver.Derive(capsule)) {
+			t.Error("salt or info did not change the key")
+		}
+	}
+
+	// usable wherever a Deriver is expected
+	encapsulator := enc.NewAESEncapsulator(deriver)
+	ciphertext, capsule, nonce, err := encapsulator.Encapsulate([]byte("the quick brown fox"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := encapsulator.Decrypt(ciphertext, capsule, nonce)
+
+	if err != nil || !bytes.Equal(plaintext, []byte("the quick brown fox")) {
+		t.Error("round trip through the HKDF deriver failed")
+	}
+}
@@ -0,0 +1,350 @@
+package hpke_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc/hpke"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+type vector struct {
+	name       string
+	kem        hpke.KEM
+	mode       hpke.Mode
+	ikm_e      string
+	ikm_r      string
+	ikm_s      string
+	enc        string
+	psk        string
+	psk_id     string
+	ciphertext []string
+	exports    map[string]string
+}
+
+const (
+	vectorInfo      = "4f6465206f6e2061204772656369616e2055726e"
+	vectorPlaintext = "4265617574792069732074727574682c20747275746820626561757479"
+)
+
+// RFC 9180 appendix A, AES-128-GCM with HKDF-SHA256, additional data "Count-<sequence>"
+var vectors = []vector{
+	{
+		name:  "A.1.1 X25519 base",
+		kem:   hpke.KEMX25519,
+		mode:  hpke.ModeBase,
+		ikm_e: "7268600d403fce431561aef583ee1613527cff655c1343f29812e66706df3234",
+		ikm_r: "6db9df30aa07dd42ee5e8181afdb977e538f5e1fec8a06223f33f7013e525037",
+		enc:   "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431",
+		ciphertext: []string{
+			"f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a",
+			"af2d7e9ac9ae7e270f46ba1f975be53c09f8d875bdc8535458c2494e8a6eab251c03d0c22a56b8ca42c2063b84",
+		},
+		exports: map[string]string{
+			"":                       "3853fe2b4035195a573ffc53856e77058e15d9ea064de3e59f4961d0095250ee",
+			"00":                     "2e8f0b54673c7029649d4eb9d5e33bf1872cf76d623ff164ac185da9e88c21a5",
+			"54657374436f6e74657874": "e9e43065102c3836401bed8c3c3c75ae46be1639869391d62c61f1ec7af54931",
+		},
+	},
+	{
+		name:   "A.1.2 X25519 psk",
+		kem:    hpke.KEMX25519,
+		mode:   hpke.ModePSK,
+		ikm_e:  "78628c354e46f3e169bd231be7b2ff1c77aa302460a26dbfa15515684c00130b",
+		ikm_r:  "d4a09d09f575fef425905d2ab396c1449141463f698f8efdb7accfaff8995098",
+		enc:    "0ad0950d9fb9588e59690b74f1237ecdf1d775cd60be2eca57af5a4b0471c91b",
+		psk:    "0247fd33b913760fa1fa51e1892d9f307fbe65eb171e8132c2af18555a738b82",
+		psk_id: "456e6e796e20447572696e206172616e204d6f726961",
+		ciphertext: []string{
+			"e52c6fed7f758d0cf7145689f21bc1be6ec9ea097fef4e959440012f4feb73fb611b946199e681f4cfc34db8ea",
+		},
+	},
+	{
+		name:  "A.1.3 X25519 auth",
+		kem:   hpke.KEMX25519,
+		mode:  hpke.ModeAuth,
+		ikm_e: "6e6d8f200ea2fb20c30b003a8b4f433d2f4ed4c2658d5bc8ce2fef718059c9f7",
+		ikm_r: "f1d4a30a4cef8d6d4e3b016e6fd3799ea057db4f345472ed302a67ce1c20cdec",
+		ikm_s: "94b020ce91d73fca4649006c7e7329a67b40c55e9e93cc907d282bbbff386f58",
+		enc:   "23fb952571a14a25e3d678140cd0e5eb47a0961bb18afcf85896e5453c312e76",
+		ciphertext: []string{
+			"5fd92cc9d46dbf8943e72a07e42f363ed5f721212cd90bcfd072bfd9f44e06b80fd17824947496e21b680c141b",
+		},
+		exports: map[string]string{
+			"": "28c70088017d70c896a8420f04702c5a321d9cbf0279fba899b59e51bac72c85",
+		},
+	},
+	{
+		name:   "A.1.4 X25519 auth psk",
+		kem:    hpke.KEMX25519,
+		mode:   hpke.ModeAuthPSK,
+		ikm_e:  "4303619085a20ebcf18edd22782952b8a7161e1dbae6e46e143a52a96127cf84",
+		ikm_r:  "4b16221f3b269a88e207270b5e1de28cb01f847841b344b8314d6a622fe5ee90",
+		ikm_s:  "62f77dcf5df0dd7eac54eac9f654f426d4161ec850cc65c54f8b65d2e0b4e345",
+		enc:    "820818d3c23993492cc5623ab437a48a0a7ca3e9639c140fe1e33811eb844b7c",
+		psk:    "0247fd33b913760fa1fa51e1892d9f307fbe65eb171e8132c2af18555a738b82",
+		psk_id: "456e6e796e20447572696e206172616e204d6f726961",
+		ciphertext: []string{
+			"a84c64df1e11d8fd11450039d4fe64ff0c8a99fca0bd72c2d4c3e0400bc14a40f27e45e141a24001697737533e",
+		},
+	},
+	{
+		name:  "A.3.1 P-256 base",
+		kem:   hpke.KEMP256,
+		mode:  hpke.ModeBase,
+		ikm_e: "4270e54ffd08d79d5928020af4686d8f6b7d35dbe470265f1f5aa22816ce860e",
+		ikm_r: "668b37171f1072f3cf12ea8a236a45df23fc13b82af3609ad1e354f6ef817550",
+		enc: "04a92719c6195d5085104f469a8b9814d5838ff72b60501e2c4466e5e67b325ac9" +
+			"8536d7b61a1af4b78e5b7f951c0900be863c403ce65c9bfcb9382657222d18c4",
+		ciphertext: []string{
+			"5ad590bb8baa577f8619db35a36311226a896e7342a6d836d8b7bcd2f20b6c7f9076ac232e3ab2523f39513434",
+		},
+		exports: map[string]string{
+			"": "5e9bc3d236e1911d95e65b576a8a86d478fb827e8bdfe77b741b289890490d4d",
+		},
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		suite, err := hpke.NewSuiteFrom(bytes.NewReader(decodeHex(t, v.ikm_e)), v.kem, hpke.KDFHKDFSHA256, hpke.AEADAES128GCM)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recipient, err := suite.DeriveKeyPair(decodeHex(t, v.ikm_r))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info := decodeHex(t, vectorInfo)
+		var encapsulated []byte
+		var sender, receiver *hpke.Context
+
+		psk, psk_id := decodeHex(t, v.psk), decodeHex(t, v.psk_id)
+		var authenticator *ecdh.PrivateKey
+
+		// the authenticated modes also derive the sender's static key
+		if v.ikm_s != "" {
+			authenticator, err = suite.DeriveKeyPair(decodeHex(t, v.ikm_s))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		switch v.mode {
+		case hpke.ModePSK:
+			encapsulated, sender, err = suite.SetupPSKS(recipient.PublicKey(), info, psk, psk_id)
+
+			if err == nil {
+				receiver, err = suite.SetupPSKR(encapsulated, recipient, info, psk, psk_id)
+			}
+		case hpke.ModeAuth:
+			encapsulated, sender, err = suite.SetupAuthS(recipient.PublicKey(), info, authenticator)
+
+			if err == nil {
+				receiver, err = suite.SetupAuthR(encapsulated, recipient, info, authenticator.PublicKey())
+			}
+		case hpke.ModeAuthPSK:
+			encapsulated, sender, err = suite.SetupAuthPSKS(recipient.PublicKey(), info, psk, psk_id, authenticator)
+
+			if err == nil {
+				receiver, err = suite.SetupAuthPSKR(encapsulated, recipient, info, psk, psk_id, authenticator.PublicKey())
+			}
+		default:
+			encapsulated, sender, err = suite.SetupBaseS(recipient.PublicKey(), info)
+
+			if err == nil {
+				receiver, err = suite.SetupBaseR(encapsulated, recipient, info)
+			}
+		}
+
+		if err != nil {
+			t.Fatal(v.name, err)
+		}
+
+		if !bytes.Equal(encapsulated, decodeHex(t, v.enc)) {
+			t.Errorf("%s: got enc %x", v.name, encapsulated)
+		}
+
+		for sequence, expected := range v.ciphertext {
+			additional := []byte("Count-" + string(rune('0'+sequence)))
+			ciphertext, err := sender.Seal(decodeHex(t, vectorPlaintext), additional)
+
+			if err != nil || !bytes.Equal(ciphertext, decodeHex(t, expected)) {
+				t.Errorf("%s: sequence %d got %x", v.name, sequence, ciphertext)
+			}
+
+			plaintext, err := receiver.Open(ciphertext, additional)
+
+			if err != nil || !bytes.Equal(plaintext, decodeHex(t, vectorPlaintext)) {
+				t.Errorf("%s: sequence %d did not open", v.name, sequence)
+			}
+		}
+
+		for context, expected := range v.exports {
+			for _, ctx := range []*hpke.Context{sender, receiver} {
+				exported, err := ctx.Export(decodeHex(t, context), 32)
+
+				if err != nil || !bytes.Equal(exported, decodeHex(t, expected)) {
+					t.Errorf("%s: export %q got %x", v.name, context, exported)
+				}
+			}
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	suite, err := hpke.NewSuite(hpke.KEMX25519, hpke.KDFHKDFSHA256, hpke.AEADAES256GCM)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipient, _ := suite.GenerateKeyPair()
+	sender, _ := suite.GenerateKeyPair()
+	impostor, _ := suite.GenerateKeyPair()
+	info := []byte("zerocat test")
+	psk, psk_id := bytes.Repeat([]byte{0x11}, 32), []byte("psk id")
+
+	encapsulated, sending, err := suite.SetupAuthPSKS(recipient.PublicKey(), info, psk, psk_id, sender)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiving, err := suite.SetupAuthPSKR(encapsulated, recipient, info, psk, psk_id, sender.PublicKey())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a context carries many messages, each under the next nonce
+	messages := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	ciphertexts := make([][]byte, 0)
+
+	for _, message := range messages {
+		ciphertext, err := sending.Seal(message, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+
+	// skipping a message desynchronises the receiver
+	if _, err := receiving.Open(ciphertexts[1], nil); !errors.Is(err, hpke.ErrOpen) {
+		t.Error("message opened out of order")
+	}
+
+	receiving, _ = suite.SetupAuthPSKR(encapsulated, recipient, info, psk, psk_id, sender.PublicKey())
+
+	for i, ciphertext := range ciphertexts {
+		plaintext, err := receiving.Open(ciphertext, nil)
+
+		if err != nil || !bytes.Equal(plaintext, messages[i]) {
+			t.Errorf("message %d did not open", i)
+		}
+	}
+
+	// auth mode binds the sender's static key
+	encapsulated, sending, _ = suite.SetupAuthS(recipient.PublicKey(), info, sender)
+	ciphertext, _ := sending.Seal([]byte("authenticated"), nil)
+
+	if receiving, err := suite.SetupAuthR(encapsulated, recipient, info, impostor.PublicKey()); err != nil {
+		t.Fatal(err)
+	} else if _, err := receiving.Open(ciphertext, nil); err == nil {
+		t.Error("opened under the wrong sender key")
+	}
+
+	if receiving, err := suite.SetupAuthR(encapsulated, recipient, info, sender.PublicKey()); err != nil {
+		t.Fatal(err)
+	} else if _, err := receiving.Open(ciphertext, nil); err != nil {
+		t.Error("auth mode failed to open")
+	}
+
+	// the psk must match and may only be used in the psk modes
+	encapsulated, sending, _ = suite.SetupPSKS(recipient.PublicKey(), info, psk, psk_id)
+	ciphertext, _ = sending.Seal([]byte("pre-shared"), nil)
+	receiving, _ = suite.SetupPSKR(encapsulated, recipient, info, bytes.Repeat([]byte{0x22}, 32), psk_id)
+
+	if _, err := receiving.Open(ciphertext, nil); err == nil {
+		t.Error("opened under the wrong psk")
+	}
+
+	if _, _, err := suite.SetupPSKS(recipient.PublicKey(), info, psk, nil); !errors.Is(err, hpke.ErrPSKInputs) {
+		t.Error("psk accepted without an id")
+	}
+
+	// export only suites refuse to seal
+	exporting, _ := hpke.NewSuite(hpke.KEMP256, hpke.KDFHKDFSHA256, hpke.AEADExportOnly)
+	recipient, _ = exporting.GenerateKeyPair()
+	encapsulated, sending, _ = exporting.SetupBaseS(recipient.PublicKey(), info)
+	receiving, _ = exporting.SetupBaseR(encapsulated, recipient, info)
+
+	if _, err := sending.Seal([]byte("message"), nil); !errors.Is(err, hpke.ErrExportOnly) {
+		t.Error("export only suite sealed a message")
+	}
+
+	first, _ := sending.Export([]byte("context"), 64)
+	second, _ := receiving.Export([]byte("context"), 64)
+
+	if len(first) != 64 || !bytes.Equal(first, second) {
+		t.Error("exported secrets differ")
+	}
+
+	if _, err := hpke.NewSuite(0x0012, hpke.KDFHKDFSHA256, hpke.AEADAES128GCM); !errors.Is(err, hpke.ErrSuite) {
+		t.Error("unsupported kem accepted")
+	}
+}
+
+func TestSealer(t *testing.T) {
+	suite, _ := hpke.NewSuite(hpke.KEMX25519, hpke.KDFHKDFSHA256, hpke.AEADAES256GCM)
+	other, _ := hpke.NewSuite(hpke.KEMX25519, hpke.KDFHKDFSHA256, hpke.AEADAES128GCM)
+	recipient, _ := suite.GenerateKeyPair()
+	info := []byte("zerocat test")
+
+	sender := hpke.NewSealer(suite, recipient.PublicKey(), info)
+	receiver := hpke.NewRecipientSealer(suite, recipient, info)
+
+	capsule, ciphertext, err := sender.Seal([]byte("sealed"), []byte("header"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext, err := receiver.Open(capsule, ciphertext, []byte("header")); err != nil || string(plaintext) != "sealed" {
+		t.Errorf("round trip failed: %v", err)
+	}
+
+	if _, err := receiver.Open(capsule, ciphertext, []byte("other header")); err == nil {
+		t.Error("altered additional data accepted")
+	}
+
+	if _, err := sender.Open(capsule, ciphertext, []byte("header")); !errors.Is(err, hpke.ErrNoPrivateKey) {
+		t.Error("sender opened without the private key")
+	}
+
+	// a capsule from another suite is refused before anything is derived
+	capsule, ciphertext, _ = hpke.NewSealer(other, recipient.PublicKey(), info).Seal([]byte("sealed"), nil)
+
+	if _, err := receiver.Open(capsule, ciphertext, nil); !errors.Is(err, hpke.ErrCapsuleSuite) {
+		t.Error("capsule from another suite accepted")
+	}
+}
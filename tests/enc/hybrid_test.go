@@ -0,0 +1,71 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestHybridKEM(t *testing.T) {
+	private, err := enc.ECDHKeyPair(ecdh.X25519())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	master := enc.NewSha256Deriver([]byte("secret"))
+	sender := enc.NewHybridEncapsulator(master, private.PublicKey())
+	receiver := enc.NewHybridDecapsulator(master, private)
+	message := []byte("the quick brown fox jumped over the lazy dog")
+
+	ciphertext, capsule, nonce, err := sender.Encapsulate(append([]byte{}, message...))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(capsule) != 64 {
+		t.Error("capsule should hold both the symmetric capsule and the ephemeral key")
+	}
+
+	// each tampered input must fail to open
+	attempts := map[string]func() ([]byte, error){
+		"symmetric capsule": func() ([]byte, error) {
+			tampered := append([]byte{}, capsule...)
+			tampered[0] ^= 1
+			return receiver.Decrypt(append([]byte{}, ciphertext...), tampered, nonce)
+		},
+		"ephemeral key": func() ([]byte, error) {
+			tampered := append([]byte{}, capsule...)
+			tampered[40] ^= 1
+			return receiver.Decrypt(append([]byte{}, ciphertext...), tampered, nonce)
+		},
+		"truncated capsule": func() ([]byte, error) {
+			return receiver.Decrypt(append([]byte{}, ciphertext...), capsule[:32], nonce)
+		},
+		"leaked master only": func() ([]byte, error) {
+			other, _ := enc.ECDHKeyPair(ecdh.X25519())
+			return enc.NewHybridDecapsulator(master, other).Decrypt(append([]byte{}, ciphertext...), capsule, nonce)
+		},
+		"leaked private key only": func() ([]byte, error) {
+			return enc.NewHybridDecapsulator(enc.NewSha256Deriver([]byte("guess")), private).Decrypt(append([]byte{}, ciphertext...), capsule, nonce)
+		},
+		"sending side": func() ([]byte, error) {
+			return sender.Decrypt(append([]byte{}, ciphertext...), capsule, nonce)
+		},
+	}
+
+	for name, attempt := range attempts {
+		if _, err := attempt(); err == nil {
+			t.Errorf("%s: decryption succeeded", name)
+		}
+	}
+
+	plaintext, err := receiver.Decrypt(ciphertext, capsule, nonce)
+
+	if err != nil || !bytes.Equal(plaintext, message) {
+		t.Error("round trip failed")
+	}
+}
@@ -0,0 +1,190 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestCapsuleMarshal(t *testing.T) {
+	capsule := enc.NewCapsule(enc.KEMECDH, []byte("key id"), []byte("capsule data"))
+	parsed, err := enc.ParseCapsule(capsule.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.KEM() != enc.KEMECDH || !bytes.Equal(parsed.KeyID(), []byte("key id")) || !bytes.Equal(parsed.Data(), []byte("capsule data")) {
+		t.Error("capsule did not survive marshalling")
+	}
+
+	encoded := capsule.Marshal()
+
+	for _, malformed := range [][]byte{nil, encoded[:1], encoded[:len(encoded)-1], append(encoded, 0)} {
+		if _, err := enc.ParseCapsule(malformed); !errors.Is(err, enc.ErrMalformed) {
+			t.Errorf("malformed capsule %x accepted", malformed)
+		}
+	}
+}
+
+func TestKEMDEM(t *testing.T) {
+	private, err := enc.ECDHKeyPair(ecdh.X25519())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	master := enc.NewSha256Deriver([]byte("secret"))
+	schemes := map[string][2]enc.KEM{
+		"symmetric": {enc.NewAESEncapsulator(master), enc.NewAESEncapsulator(master)},
+		"ecdh":      {enc.NewECDHEncapsulator(private.PublicKey()), enc.NewECDHDecapsulator(private)},
+		"hybrid":    {enc.NewHybridEncapsulator(master, private.PublicKey()), enc.NewHybridDecapsulator(master, private)},
+	}
+
+	plaintext := []byte("the quick brown fox jumped over the lazy dog")
+	header := []byte("length 44, sequence 7")
+
+	for name, kems := range schemes {
+		sender := enc.NewKEMDEM(kems[0], enc.NewGCMDEM())
+		receiver := enc.NewKEMDEM(kems[1], enc.NewGCMDEM())
+
+		capsule, ciphertext, err := sender.Seal(plaintext, header)
+
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if kem, dem := sender.Algorithms(); kem != capsule.KEM() || dem != enc.DEMAESGCM {
+			t.Errorf("%s: algorithms reported as %d and %d", name, kem, dem)
+		}
+
+		capsule, err = enc.ParseCapsule(capsule.Marshal())
+
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		opened, err := receiver.Open(capsule, ciphertext, header)
+
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("%s: round trip failed", name)
+		}
+
+		// the header is authenticated but not encrypted
+		if bytes.Contains(ciphertext, header) {
+			t.Errorf("%s: header found in the ciphertext", name)
+		}
+
+		if _, err := receiver.Open(capsule, ciphertext, []byte("length 44, sequence 8")); err == nil {
+			t.Errorf("%s: altered header accepted", name)
+		}
+
+		// the key id and kem id are bound even where they do not feed the key
+		relabelled := enc.NewCapsule(capsule.KEM(), []byte("another key"), capsule.Data())
+
+		if _, err := receiver.Open(relabelled, ciphertext, header); err == nil {
+			t.Errorf("%s: capsule with a different key id accepted", name)
+		}
+
+		mislabelled := enc.NewCapsule(capsule.KEM()+1, capsule.KeyID(), capsule.Data())
+
+		if _, err := receiver.Open(mislabelled, ciphertext, header); !errors.Is(err, enc.ErrKEM) {
+			t.Errorf("%s: capsule from another kem accepted", name)
+		}
+
+		// the buffered forms append to what the caller already has
+		capsule, sealed, err := sender.SealTo([]byte("prefix"), plaintext, header)
+
+		if err != nil || !bytes.HasPrefix(sealed, []byte("prefix")) {
+			t.Fatalf("%s: SealTo did not append: %v", name, err)
+		}
+
+		opened, err = receiver.OpenTo([]byte("prefix"), capsule, sealed[len("prefix"):], header)
+
+		if err != nil || !bytes.Equal(opened, append([]byte("prefix"), plaintext...)) {
+			t.Errorf("%s: OpenTo did not append", name)
+		}
+
+		// and are interchangeable with the unbuffered ones
+		if opened, err := receiver.Open(capsule, sealed[len("prefix"):], header); err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("%s: Open rejected a message from SealTo", name)
+		}
+	}
+}
+
+func TestKEMDEMAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("pooled scratch space is dropped under the race detector")
+	}
+
+	scheme := enc.NewKEMDEM(enc.NewAESEncapsulator(enc.NewSha256Deriver([]byte("secret"))), enc.NewGCMDEM())
+	plaintext := []byte("the quick brown fox jumped over the lazy dog")
+	header := []byte("length 44, sequence 7")
+	sealed := make([]byte, 0, 256)
+	opened := make([]byte, 0, 256)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		capsule, ciphertext, err := scheme.SealTo(sealed[:0], plaintext, header)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := scheme.OpenTo(opened[:0], capsule, ciphertext, header); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// every message has a fresh key, so the AES and GCM state are built once on each side, and the
+	// capsule is handed to the caller
+	if allocs > 5 {
+		t.Errorf("round trip made %v allocations, want at most 5", allocs)
+	}
+}
+
+// hands out keys the test can inspect after use
+type recordingKEM struct {
+	keys [][]byte
+}
+
+func (kem *recordingKEM) Algorithm() enc.KEMID {
+	return enc.KEMSymmetric
+}
+
+func (kem *recordingKEM) key() []byte {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	kem.keys = append(kem.keys, key)
+
+	return key
+}
+
+func (kem *recordingKEM) EncapsulateKey() ([]byte, *enc.Capsule, error) {
+	return kem.key(), enc.NewCapsule(enc.KEMSymmetric, nil, []byte("capsule")), nil
+}
+
+func (kem *recordingKEM) DecapsulateKey(capsule *enc.Capsule) ([]byte, error) {
+	return kem.key(), nil
+}
+
+func TestKEMDEMWipesKeys(t *testing.T) {
+	kem := new(recordingKEM)
+	scheme := enc.NewKEMDEM(kem, enc.NewGCMDEM())
+	capsule, ciphertext, err := scheme.Seal([]byte("plaintext"), nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := scheme.Open(capsule, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, key := range kem.keys {
+		if !bytes.Equal(key, make([]byte, len(key))) {
+			t.Errorf("key %d was not wiped", i)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package enc_test
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestEncryptedPEMBlock(t *testing.T) {
+	block := &pem.Block{Type: "FFS PRIVATE KEY", Headers: map[string]string{"k": "4", "size": "512"}, Bytes: []byte("private key elements")}
+	passphrase := []byte("correct horse battery staple")
+
+	encrypted, err := enc.EncryptPEMBlock(block, passphrase, enc.MinKeyFileIterations)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !enc.IsEncryptedPEMBlock(encrypted) || enc.IsEncryptedPEMBlock(block) || bytes.Contains(encrypted.Bytes, block.Bytes) {
+		t.Fatal("block was not encrypted")
+	}
+
+	// the block survives encoding as PEM
+	encrypted, _ = pem.Decode(pem.EncodeToMemory(encrypted))
+
+	if encrypted.Headers["kdf"] != "pbkdf2-sha256" || encrypted.Headers["iterations"] != "10000" || encrypted.Headers["k"] != "4" {
+		t.Errorf("headers %v do not record the parameters", encrypted.Headers)
+	}
+
+	decrypted, err := enc.DecryptPEMBlock(encrypted, passphrase)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted.Type != block.Type || !bytes.Equal(decrypted.Bytes, block.Bytes) || len(decrypted.Headers) != 2 || decrypted.Headers["size"] != "512" {
+		t.Errorf("decrypted to %v", decrypted)
+	}
+
+	if _, err := enc.DecryptPEMBlock(encrypted, []byte("wrong")); !errors.Is(err, enc.ErrPassphrase) {
+		t.Error("wrong passphrase accepted")
+	}
+
+	if _, err := enc.DecryptPEMBlock(block, passphrase); !errors.Is(err, enc.ErrNotEncrypted) {
+		t.Error("plain block decrypted")
+	}
+
+	if _, err := enc.EncryptPEMBlock(encrypted, passphrase, enc.MinKeyFileIterations); !errors.Is(err, enc.ErrKeyFile) {
+		t.Error("block encrypted twice")
+	}
+
+	// the type and every header are authenticated
+	tamper := func(change func(*pem.Block)) *pem.Block {
+		copied := &pem.Block{Type: encrypted.Type, Headers: make(map[string]string), Bytes: encrypted.Bytes}
+
+		for name, value := range encrypted.Headers {
+			copied.Headers[name] = value
+		}
+
+		change(copied)
+
+		return copied
+	}
+
+	cases := map[string]struct {
+		block *pem.Block
+		err   error
+	}{
+		"type":       {tamper(func(b *pem.Block) { b.Type = "KDF MASTER" }), enc.ErrPassphrase},
+		"size":       {tamper(func(b *pem.Block) { b.Headers["size"] = "1024" }), enc.ErrPassphrase},
+		"iterations": {tamper(func(b *pem.Block) { b.Headers["iterations"] = "10001" }), enc.ErrPassphrase},
+		"too cheap":  {tamper(func(b *pem.Block) { b.Headers["iterations"] = "1" }), enc.ErrIterations},
+		"too costly": {tamper(func(b *pem.Block) { b.Headers["iterations"] = "1000000000" }), enc.ErrIterations},
+		"kdf":        {tamper(func(b *pem.Block) { b.Headers["kdf"] = "md5" }), enc.ErrKeyFile},
+		"nonce":      {tamper(func(b *pem.Block) { b.Headers["nonce"] = "00" }), enc.ErrKeyFile},
+		"salt":       {tamper(func(b *pem.Block) { b.Headers["salt"] = "zz" }), enc.ErrKeyFile},
+	}
+
+	for name, test := range cases {
+		if _, err := enc.DecryptPEMBlock(test.block, passphrase); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", name, err, test.err)
+		}
+	}
+
+	if _, err := enc.EncryptPEMBlock(block, passphrase, 1); !errors.Is(err, enc.ErrIterations) {
+		t.Error("cheap iteration count accepted")
+	}
+}
@@ -0,0 +1,105 @@
+package paillier_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/auth"
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc/paillier"
+)
+
+func TestPaillier(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	public, private, err := paillier.KeyPair(group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := paillier.KeyPair(grouptheory.NewCompGroup(group.Ring())); err != paillier.ErrTotient {
+		t.Error("key pair generated without the factors")
+	}
+
+	a, err := public.Encrypt(big.NewInt(3))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := public.Encrypt(big.NewInt(4))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := private.Decrypt(public.Add(a, b))
+
+	if err != nil || sum.Int64() != 7 {
+		t.Error("homomorphic addition failed")
+	}
+
+	product, err := private.Decrypt(public.ScalarMul(a, big.NewInt(5)))
+
+	if err != nil || product.Int64() != 15 {
+		t.Error("homomorphic scalar multiplication failed")
+	}
+
+	if _, err := public.Encrypt(public.Modulus()); err != paillier.ErrRange {
+		t.Error("message larger than the modulus encrypted")
+	}
+}
+
+func TestPaillierBitProof(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	public, private, err := paillier.KeyPair(group)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tally a set of votes without seeing any of them
+	votes := []int64{1, 0, 1, 1}
+	tally, _ := public.Encrypt(big.NewInt(0))
+
+	for _, vote := range votes {
+		ciphertext, proof, err := public.EncryptBit(big.NewInt(vote), auth.NewChainChallenger(), []byte("vote"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proof, err = paillier.ParseBitProof(proof.Marshal())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !public.VerifyBit(ciphertext, proof, auth.NewChainChallenger(), []byte("vote")) {
+			t.Error("valid bit proof rejected")
+		}
+
+		if public.VerifyBit(ciphertext, proof, auth.NewChainChallenger(), []byte("other")) {
+			t.Error("bit proof accepted for the wrong block")
+		}
+
+		// the proof does not carry over to a ciphertext of 2
+		doubled := public.Add(ciphertext, ciphertext)
+
+		if public.VerifyBit(doubled, proof, auth.NewChainChallenger(), []byte("vote")) {
+			t.Error("bit proof accepted for another ciphertext")
+		}
+
+		tally = public.Add(tally, ciphertext)
+	}
+
+	total, err := private.Decrypt(tally)
+
+	if err != nil || total.Int64() != 3 {
+		t.Error("tally does not match")
+	}
+
+	if _, _, err := public.EncryptBit(big.NewInt(2), auth.NewChainChallenger(), nil); err != paillier.ErrNotBit {
+		t.Error("proof produced for a value other than 0 or 1")
+	}
+}
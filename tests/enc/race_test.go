@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package enc_test
+
+// the race detector drops pooled buffers at random, so allocation counts are not checked under it
+const raceEnabled = true
@@ -0,0 +1,207 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func newRatchets(t *testing.T) (*enc.Ratchet, *enc.Ratchet) {
+	secret := []byte("session secret from the handshake")
+	initiator, err := enc.NewRatchet(secret, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder, err := enc.NewRatchet(secret, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return initiator, responder
+}
+
+func TestKeyChain(t *testing.T) {
+	chain := enc.NewKeyChain(bytes.Repeat([]byte{0x01}, 32))
+	past := make(map[string]bool)
+
+	for i := 0; i < 10; i++ {
+		key, err := chain.Next()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		past[string(key)] = true
+	}
+
+	// a chain rebuilt from the current state only ever produces later keys
+	captured := enc.NewKeyChain(chain.State())
+
+	for i := 0; i < 1000; i++ {
+		key, err := captured.Next()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if past[string(key)] {
+			t.Fatal("captured state reproduced a past message key")
+		}
+
+		if i == 0 {
+			next, _ := chain.Next()
+
+			if !bytes.Equal(key, next) {
+				t.Error("captured state diverged from the chain")
+			}
+		}
+	}
+}
+
+func TestRatchet(t *testing.T) {
+	initiator, responder := newRatchets(t)
+	additional := []byte("frame header")
+
+	for i := 0; i < 5; i++ {
+		message, err := initiator.Seal([]byte(fmt.Sprintf("ping %d", i)), additional)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plaintext, err := responder.Open(message, additional)
+
+		if err != nil || string(plaintext) != fmt.Sprintf("ping %d", i) {
+			t.Fatalf("message %d: %v", i, err)
+		}
+
+		reply, err := responder.Seal([]byte("pong"), nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if plaintext, err := initiator.Open(reply, nil); err != nil || string(plaintext) != "pong" {
+			t.Fatalf("reply %d: %v", i, err)
+		}
+	}
+
+	// out of order delivery
+	messages := make([][]byte, 4)
+
+	for i := range messages {
+		messages[i], _ = initiator.Seal([]byte{byte(i)}, nil)
+	}
+
+	for _, i := range []int{2, 0, 3, 1} {
+		if plaintext, err := responder.Open(messages[i], nil); err != nil || plaintext[0] != byte(i) {
+			t.Errorf("message %d out of order: %v", i, err)
+		}
+	}
+
+	// every key is deleted once used, so a recorded frame can not be opened again
+	for i := range messages {
+		if _, err := responder.Open(messages[i], nil); !errors.Is(err, enc.ErrMessageKey) {
+			t.Errorf("message %d replayed: %v", i, err)
+		}
+	}
+}
+
+func TestRatchetDestroy(t *testing.T) {
+	initiator, responder := newRatchets(t)
+	message, _ := initiator.Seal([]byte("ping"), nil)
+	initiator.Destroy()
+	responder.Destroy()
+
+	if _, err := initiator.Seal([]byte("ping"), nil); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed ratchet sealed a message")
+	}
+
+	if _, err := responder.Open(message, nil); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed ratchet opened a message")
+	}
+}
+
+func TestRatchetForgery(t *testing.T) {
+	initiator, responder := newRatchets(t)
+	first, _ := initiator.Seal([]byte("first"), nil)
+	second, _ := initiator.Seal([]byte("second"), nil)
+
+	tampered := append([]byte{}, second...)
+	tampered[len(tampered)-1] ^= 1
+
+	if _, err := responder.Open(tampered, nil); !errors.Is(err, enc.ErrOpen) {
+		t.Error("tampered message accepted")
+	}
+
+	if _, err := responder.Open(second, []byte("other")); !errors.Is(err, enc.ErrOpen) {
+		t.Error("message opened with different additional data")
+	}
+
+	// the failed attempts did not advance the state or consume keys
+	for _, message := range [][]byte{first, second} {
+		if _, err := responder.Open(message, nil); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := responder.Open(first[:10], nil); !errors.Is(err, enc.ErrRatchetHeader) {
+		t.Error("short message accepted")
+	}
+
+	far := make([][]byte, enc.MaxSkippedKeys+2)
+
+	for i := range far {
+		far[i], _ = initiator.Seal(nil, nil)
+	}
+
+	if _, err := responder.Open(far[len(far)-1], nil); !errors.Is(err, enc.ErrTooManySkips) {
+		t.Error("unbounded skip accepted")
+	}
+}
+
+func TestRatchetKEMSteps(t *testing.T) {
+	initiator_key, _ := enc.ECDHKeyPair(ecdh.X25519())
+	responder_key, _ := enc.ECDHKeyPair(ecdh.X25519())
+
+	initiator, responder := newRatchets(t)
+	initiator.SetKEM(enc.NewECDHEncapsulator(responder_key.PublicKey()), enc.NewECDHDecapsulator(initiator_key), 3)
+	responder.SetKEM(enc.NewECDHEncapsulator(initiator_key.PublicKey()), enc.NewECDHDecapsulator(responder_key), 3)
+
+	messages := make([][]byte, 10)
+
+	for i := range messages {
+		messages[i], _ = initiator.Seal([]byte{byte(i)}, nil)
+	}
+
+	// deliver across epochs out of order, skipping the end of the first epoch
+	for _, i := range []int{0, 4, 3, 1, 5, 2, 6, 8, 7, 9} {
+		if plaintext, err := responder.Open(messages[i], nil); err != nil || plaintext[0] != byte(i) {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+
+	// a receiver that does not have the recipient key can not follow a step
+	_, stranger := newRatchets(t)
+
+	if _, err := stranger.Open(messages[3], nil); !errors.Is(err, enc.ErrNoRatchetKEM) {
+		t.Error("step followed without a KEM")
+	}
+
+	stranger.SetKEM(nil, enc.NewECDHDecapsulator(initiator_key), 0)
+
+	if _, err := stranger.Open(messages[3], nil); !errors.Is(err, enc.ErrKeyID) {
+		t.Error("step followed with the wrong key")
+	}
+
+	if _, err := stranger.Open(messages[6], nil); !errors.Is(err, enc.ErrEpoch) {
+		t.Error("skipped epoch accepted")
+	}
+}
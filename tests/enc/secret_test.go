@@ -0,0 +1,100 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func TestWipe(t *testing.T) {
+	key := []byte("key material")
+	enc.Wipe(key)
+
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Error("bytes not wiped")
+	}
+
+	x, _ := new(big.Int).SetString("123456789abcdef0123456789abcdef0123456789abcdef", 16)
+	words := x.Bits()
+	enc.WipeInt(x)
+	enc.WipeInt(nil)
+
+	if x.Sign() != 0 {
+		t.Error("integer not zeroed")
+	}
+
+	for i, word := range words[:cap(words)] {
+		if word != 0 {
+			t.Errorf("word %d of the integer survived", i)
+		}
+	}
+
+	xs := []*big.Int{big.NewInt(5), big.NewInt(-7)}
+	enc.WipeInts(xs)
+
+	if xs[0].Sign() != 0 || xs[1].Sign() != 0 {
+		t.Error("key vector not wiped")
+	}
+}
+
+func TestSecret(t *testing.T) {
+	data := []byte("master key")
+	secret := enc.NewSecretFrom(data)
+	data[0] = 'X'
+
+	if string(secret.Bytes()) != "master key" || secret.Len() != 10 {
+		t.Error("secret shares the caller's buffer")
+	}
+
+	held := secret.Bytes()
+	secret.Destroy()
+	secret.Destroy()
+
+	if !secret.Destroyed() || secret.Bytes() != nil || !bytes.Equal(held, make([]byte, 10)) {
+		t.Error("secret not wiped")
+	}
+}
+
+func TestDestroyKeyMaterial(t *testing.T) {
+	master := []byte("secret")
+	deriver := enc.NewSha256Deriver(master)
+	enc.Wipe(master)
+
+	encapsulator := enc.NewAESEncapsulator(deriver)
+	ciphertext, capsule, nonce, err := encapsulator.Encapsulate([]byte("plaintext"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the deriver kept its own copy of the master
+	if plaintext, err := enc.NewAESEncapsulator(enc.NewSha256Deriver([]byte("secret"))).Decrypt(append([]byte{}, ciphertext...), capsule, nonce); err != nil || string(plaintext) != "plaintext" {
+		t.Fatal("wiping the caller's master changed the deriver")
+	}
+
+	encapsulator.Destroy()
+
+	if deriver.Derive(capsule) != nil {
+		t.Error("destroyed deriver still derives keys")
+	}
+
+	if _, _, _, err := encapsulator.Encapsulate([]byte("plaintext")); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed encapsulator encrypted")
+	}
+
+	if _, err := encapsulator.Decrypt(ciphertext, capsule, nonce); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed encapsulator decrypted")
+	}
+
+	// a hybrid must not fall back to the ECDH secret alone
+	private, _ := enc.ECDHKeyPair(ecdh.X25519())
+	hybrid := enc.NewHybridEncapsulator(deriver, private.PublicKey())
+
+	if _, _, err := hybrid.EncapsulateKey(); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("hybrid encapsulated with a destroyed deriver")
+	}
+}
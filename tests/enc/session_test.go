@@ -0,0 +1,234 @@
+package enc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func newSessions(t *testing.T) (*enc.GCMSession, *enc.GCMSession) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+	initiator, err := enc.NewGCMSession(key, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder, err := enc.NewGCMSession(key, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return initiator, responder
+}
+
+func TestGCMSession(t *testing.T) {
+	initiator, responder := newSessions(t)
+	nonces := make(map[string]bool)
+
+	for i := 0; i < 10; i++ {
+		record, err := initiator.Seal([]byte("hello"), []byte("header"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the nonce is the epoch and counter
+		if binary.BigEndian.Uint64(record[4:12]) != uint64(i) || nonces[string(record[:enc.SessionHeaderSize])] {
+			t.Fatalf("record %d has nonce %x", i, record[:enc.SessionHeaderSize])
+		}
+
+		nonces[string(record[:enc.SessionHeaderSize])] = true
+
+		if plaintext, err := responder.Open(record, []byte("header")); err != nil || string(plaintext) != "hello" {
+			t.Fatalf("record %d: %v", i, err)
+		}
+
+		reply, _ := responder.Seal([]byte("world"), nil)
+
+		if plaintext, err := initiator.Open(reply, nil); err != nil || string(plaintext) != "world" {
+			t.Fatalf("reply %d: %v", i, err)
+		}
+	}
+
+	if initiator.Messages() != 10 || initiator.Bytes() != 50 {
+		t.Errorf("usage recorded as %d messages and %d bytes", initiator.Messages(), initiator.Bytes())
+	}
+
+	// each direction has its own key, a record can not be reflected back to its sender
+	record, _ := initiator.Seal([]byte("reflected"), nil)
+
+	if _, err := initiator.Open(record, nil); err == nil {
+		t.Error("reflected record accepted")
+	}
+
+	if _, err := responder.Open(record, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := responder.Open(record, nil); !errors.Is(err, enc.ErrReplay) {
+		t.Error("replayed record accepted")
+	}
+
+	tampered, _ := initiator.Seal([]byte("tampered"), nil)
+	tampered[len(tampered)-1] ^= 1
+
+	if _, err := responder.Open(tampered, nil); !errors.Is(err, enc.ErrOpen) {
+		t.Error("tampered record accepted")
+	}
+
+	if _, err := responder.Open(tampered[:20], nil); !errors.Is(err, enc.ErrMalformed) {
+		t.Error("short record accepted")
+	}
+
+	exhausted, _ := initiator.Seal(nil, nil)
+	binary.BigEndian.PutUint64(exhausted[4:12], ^uint64(0))
+
+	if _, err := responder.Open(exhausted, nil); !errors.Is(err, enc.ErrNonceReuse) {
+		t.Error("record with the last counter accepted")
+	}
+}
+
+func TestGCMSessionLimits(t *testing.T) {
+	initiator, responder := newSessions(t)
+	initiator.SetLimits(3, 100)
+	responder.SetLimits(3, 100)
+
+	var old []byte
+
+	// the fourth record starts a new epoch
+	for i := 0; i < 4; i++ {
+		record, err := initiator.Seal([]byte("0123456789"), nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			old = record
+		}
+
+		if epoch := binary.BigEndian.Uint32(record); epoch != uint32(i/3) {
+			t.Errorf("record %d sealed in epoch %d", i, epoch)
+		}
+
+		if _, err := responder.Open(record, nil); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+	}
+
+	if initiator.Epoch() != 1 || initiator.Messages() != 1 {
+		t.Errorf("rekey left epoch %d with %d messages", initiator.Epoch(), initiator.Messages())
+	}
+
+	// the old key is gone once the peer has moved on
+	if _, err := responder.Open(old, nil); !errors.Is(err, enc.ErrKeyEpoch) {
+		t.Error("record from a retired key accepted")
+	}
+
+	// the byte limit also forces a rekey
+	record, _ := initiator.Seal(make([]byte, 95), nil)
+
+	if epoch := binary.BigEndian.Uint32(record); epoch != 2 {
+		t.Errorf("byte limit left the key in epoch %d", epoch)
+	}
+
+	if _, err := responder.Open(record, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := initiator.Seal(make([]byte, 101), nil); !errors.Is(err, enc.ErrKeyLimit) {
+		t.Error("message larger than the byte limit accepted")
+	}
+
+	// a peer that ignores the limits is refused
+	careless, strict := newSessions(t)
+	strict.SetLimits(3, 100)
+
+	for i := 0; i < 4; i++ {
+		record, _ := careless.Seal(nil, nil)
+		_, err := strict.Open(record, nil)
+
+		if i < 3 && err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 3 && !errors.Is(err, enc.ErrKeyLimit) {
+			t.Error("record past the message limit accepted")
+		}
+	}
+
+	// lowering the byte limit below what the key has already sealed rekeys on the next record
+	lowered, _ := newSessions(t)
+	lowered.Seal(make([]byte, 50), nil)
+	lowered.SetLimits(0, 20)
+	lowered.Seal([]byte("0123456789"), nil)
+
+	if lowered.Epoch() != 1 {
+		t.Error("byte limit lowered below the bytes sealed did not rekey")
+	}
+
+	// a manual rekey is followed, but a skipped epoch is not
+	initiator.Rekey()
+	initiator.Rekey()
+	record, _ = initiator.Seal(nil, nil)
+
+	if _, err := responder.Open(record, nil); !errors.Is(err, enc.ErrKeyEpoch) {
+		t.Error("skipped epoch accepted")
+	}
+}
+
+func TestGCMSessionDestroy(t *testing.T) {
+	initiator, responder := newSessions(t)
+	record, _ := initiator.Seal([]byte("hello"), nil)
+	initiator.Destroy()
+	responder.Destroy()
+
+	if _, err := initiator.Seal([]byte("hello"), nil); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed session sealed a message")
+	}
+
+	if err := initiator.Rekey(); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed session rekeyed")
+	}
+
+	if _, err := responder.Open(record, nil); !errors.Is(err, enc.ErrDestroyed) {
+		t.Error("destroyed session opened a record")
+	}
+}
+
+func TestSessionSealer(t *testing.T) {
+	initiator, responder := newSessions(t)
+	sender, receiver := enc.NewSessionSealer(initiator), enc.NewSessionSealer(responder)
+
+	if kem, dem := sender.Algorithms(); kem != enc.KEMSession || dem != enc.DEMSession {
+		t.Errorf("algorithms reported as %d and %d", kem, dem)
+	}
+
+	capsule, ciphertext, err := sender.Seal([]byte("hello"), []byte("header"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(capsule.KeyID()) != 0 || len(capsule.Data()) != 0 {
+		t.Error("session capsule is not empty")
+	}
+
+	if _, err := receiver.Open(enc.NewCapsule(enc.KEMSymmetric, nil, nil), ciphertext, []byte("header")); !errors.Is(err, enc.ErrKEM) {
+		t.Error("capsule from another kem accepted")
+	}
+
+	if _, err := receiver.Open(capsule, ciphertext, []byte("other header")); err == nil {
+		t.Error("altered additional data accepted")
+	}
+
+	// the failed open did not advance the receiver, so the record still opens
+	if plaintext, err := receiver.Open(capsule, ciphertext, []byte("header")); err != nil || string(plaintext) != "hello" {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
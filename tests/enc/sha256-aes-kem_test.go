@@ -29,3 +29,50 @@ func TestSha256AESKEM(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestAESSealTo(t *testing.T) {
+	encapsulator := enc.NewAESEncapsulator(enc.NewSha256Deriver([]byte("secret")))
+	message := []byte("the quick brown fox jumped over the lazy dog")
+
+	// Encapsulate and Decrypt leave their inputs alone
+	plaintext := append([]byte{}, message...)
+	ciphertext, capsule, nonce, err := encapsulator.Encapsulate(plaintext)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := append([]byte{}, ciphertext...)
+
+	if _, err := encapsulator.Decrypt(ciphertext, capsule, nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(plaintext, message) || !bytes.Equal(ciphertext, sealed) {
+		t.Error("inputs were overwritten")
+	}
+
+	// a frame laid out as nonce || capsule || ciphertext, sealed and then opened in place
+	header := encapsulator.NonceSize() + encapsulator.CapsuleSize()
+	frame := make([]byte, header, header+len(message)+16)
+	frame = append(frame, message...)
+	frame, err = encapsulator.SealTo(frame[:header], frame[12:header], frame[:12], frame[header:])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := encapsulator.OpenTo(frame[header:header], frame[header:], frame[12:header], frame[:12])
+
+	if err != nil || !bytes.Equal(opened, message) {
+		t.Fatalf("in place round trip failed: %v", err)
+	}
+
+	if &opened[0] != &frame[header] {
+		t.Error("OpenTo did not decrypt in place")
+	}
+
+	if _, err := encapsulator.SealTo(nil, make([]byte, 16), make([]byte, 12), message); err == nil {
+		t.Error("short capsule accepted")
+	}
+}
@@ -0,0 +1,194 @@
+package shamir_test
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc/shamir"
+)
+
+func TestInterpolate(t *testing.T) {
+	ring := grouptheory.PrimeRing(64)
+	polynomial := grouptheory.NewPolynomial(ring, []*big.Int{big.NewInt(5), big.NewInt(3), big.NewInt(2)})
+	squared := polynomial.Mul(polynomial)
+
+	xs := make([]*big.Int, 0)
+	ys := make([]*big.Int, 0)
+
+	for i := int64(1); i <= int64(squared.Degree()+1); i++ {
+		xs = append(xs, big.NewInt(i))
+		ys = append(ys, squared.Evaluate(big.NewInt(i)))
+	}
+
+	// (2x^2 + 3x + 5)^2 = 25 at zero and 100 at one
+	at_zero, err := grouptheory.Interpolate(ring, xs, ys, big.NewInt(0))
+
+	if err != nil || at_zero.Int64() != 25 {
+		t.Error("interpolation at zero failed")
+	}
+
+	if polynomial.Add(polynomial).Evaluate(big.NewInt(1)).Int64() != 20 {
+		t.Error("polynomial addition failed")
+	}
+}
+
+func TestShamir(t *testing.T) {
+	group, err := grouptheory.SetupPrimeOrderGroup(512, 128)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leading zeros and several chunks must survive
+	secret := make([]byte, 100)
+	secret[50] = 1
+	secret[99] = 0xff
+
+	shares, commitments, err := shamir.Split(group, secret, 3, 5)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitments, err = shamir.ParseCommitments(commitments.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(shares); i++ {
+		shares[i], err = shamir.ParseShare(shares[i].Marshal())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !commitments.Check(shares[i]) {
+			t.Error("valid share rejected")
+		}
+	}
+
+	recovered, err := shamir.Combine(group, []*shamir.Share{shares[4], shares[0], shares[2]}, commitments)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(recovered, secret) {
+		t.Error("recovered secret does not match")
+	}
+
+	if _, err := shamir.Combine(group, shares[:2], commitments); err != shamir.ErrTooFew {
+		t.Error("secret recovered below the threshold")
+	}
+
+	// a share from another split is detected
+	others, _, _ := shamir.Split(group, []byte("other secret"), 3, 5)
+	bad, _ := shamir.ParseShare(others[1].Marshal())
+
+	var share_err *shamir.ShareError
+	_, err = shamir.Combine(group, []*shamir.Share{shares[0], bad, shares[2]}, commitments)
+
+	if !errors.As(err, &share_err) || share_err.Index != 2 {
+		t.Error("share from another secret not reported")
+	}
+
+	// so is a tampered share from this split
+	tampered := shares[1].Marshal()
+	tampered[len(tampered)-1] ^= 1
+	bad, _ = shamir.ParseShare(tampered)
+
+	if _, err := shamir.Combine(group, []*shamir.Share{shares[0], bad, shares[2]}, commitments); !errors.Is(err, shamir.ErrBadShare) {
+		t.Error("tampered share accepted")
+	}
+
+	// commitments to polynomials of another degree are refused outright
+	_, short, _ := shamir.Split(group, secret, 2, 5)
+
+	if short.Check(shares[0]) {
+		t.Error("share checked against a short commitment vector")
+	}
+
+	if _, err := shamir.Combine(group, shares[:3], short); err != shamir.ErrCommitments {
+		t.Error("short commitment vector accepted")
+	}
+}
+
+func TestCommitmentsHideChunks(t *testing.T) {
+	group, err := grouptheory.SetupPrimeOrderGroup(512, 128)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a 128 bit order holds 15 byte chunks, leaving a single byte in the last one
+	secret := bytes.Repeat([]byte{0x5a}, 16)
+	_, commitments, err := shamir.Split(group, secret, 2, 3)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := commitments.Values()
+	constant := chunks[len(chunks)-1][0]
+
+	for guess := int64(0); guess < 256; guess++ {
+		if group.Exp(group.Generator(), big.NewInt(guess)).Cmp(constant) == 0 {
+			t.Fatalf("last chunk recovered from its commitment as %d", guess)
+		}
+	}
+
+	// the same secret commits differently every time it is split
+	_, again, err := shamir.Split(group, secret, 2, 3)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for chunk := range chunks {
+		if again.Values()[chunk][0].Cmp(chunks[chunk][0]) == 0 {
+			t.Errorf("chunk %d committed to the same value twice", chunk)
+		}
+	}
+}
+
+func TestSplitFrom(t *testing.T) {
+	group, err := grouptheory.SetupPrimeOrderGroup(512, 128)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split := func() ([]*shamir.Share, *shamir.Commitments) {
+		drbg, err := enc.NewHMACDRBG(bytes.Repeat([]byte{0x01}, 32), []byte("nonce"), nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shares, commitments, err := shamir.SplitFrom(drbg, group, []byte("a secret of more than one chunk"), 2, 3)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return shares, commitments
+	}
+
+	shares, commitments := split()
+	again_shares, again_commitments := split()
+
+	if !bytes.Equal(commitments.Marshal(), again_commitments.Marshal()) {
+		t.Error("commitments differ for the same randomness")
+	}
+
+	for i := range shares {
+		if !bytes.Equal(shares[i].Marshal(), again_shares[i].Marshal()) {
+			t.Errorf("share %d differs for the same randomness", shares[i].Index())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package enc_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/SimonHorrocks/Zerocat/pkg/enc"
+)
+
+func sealStream(t *testing.T, key, additional, plaintext []byte, write_size int) []byte {
+	output := new(bytes.Buffer)
+	writer, err := enc.NewStreamWriter(key, additional, output)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for start := 0; start < len(plaintext); start += write_size {
+		end := start + write_size
+
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+
+		if _, err := writer.Write(plaintext[start:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte("late")); !errors.Is(err, enc.ErrStreamClosed) {
+		t.Error("write after close accepted")
+	}
+
+	return output.Bytes()
+}
+
+func openStream(key, additional, ciphertext []byte) ([]byte, error) {
+	reader, err := enc.NewStreamReader(key, additional, bytes.NewReader(ciphertext))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+func TestStream(t *testing.T) {
+	key := bytes.Repeat([]byte{0x05}, 32)
+	chunk := enc.StreamChunkSize
+	overhead := 16
+
+	// empty, short, exactly one chunk, exactly two chunks and a partial third
+	for _, size := range []int{0, 100, chunk, 2 * chunk, 2*chunk + 7} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		for _, write_size := range []int{1000, chunk + 1} {
+			ciphertext := sealStream(t, key, []byte("header"), plaintext, write_size)
+			opened, err := openStream(key, []byte("header"), ciphertext)
+
+			if err != nil || !bytes.Equal(opened, plaintext) {
+				t.Errorf("size %d: round trip failed: %v", size, err)
+			}
+		}
+	}
+
+	plaintext := make([]byte, 3*chunk+10)
+	rand.Read(plaintext)
+	ciphertext := sealStream(t, key, nil, plaintext, 4096)
+	sealed_chunk := chunk + overhead
+	header := 16
+
+	// dropping the final chunk leaves a stream that ends on a middle chunk
+	if _, err := openStream(key, nil, ciphertext[:header+3*sealed_chunk]); !errors.Is(err, enc.ErrTruncated) {
+		t.Errorf("truncated stream accepted: %v", err)
+	}
+
+	// a final chunk cut shorter than its tag, and one cut after it
+	if _, err := openStream(key, nil, ciphertext[:header+3*sealed_chunk+5]); !errors.Is(err, enc.ErrTruncated) {
+		t.Errorf("cut final chunk accepted: %v", err)
+	}
+
+	if _, err := openStream(key, nil, ciphertext[:header+3*sealed_chunk+20]); !errors.Is(err, enc.ErrChunk) {
+		t.Errorf("cut final chunk accepted: %v", err)
+	}
+
+	if _, err := openStream(key, nil, ciphertext[:8]); !errors.Is(err, enc.ErrTruncated) {
+		t.Error("missing header accepted")
+	}
+
+	// swapping two middle chunks
+	reordered := append([]byte{}, ciphertext...)
+	copy(reordered[header:], ciphertext[header+sealed_chunk:header+2*sealed_chunk])
+	copy(reordered[header+sealed_chunk:], ciphertext[header:header+sealed_chunk])
+
+	if _, err := openStream(key, nil, reordered); !errors.Is(err, enc.ErrChunk) {
+		t.Error("reordered stream accepted")
+	}
+
+	tampered := append([]byte{}, ciphertext...)
+	tampered[header+10] ^= 1
+
+	if _, err := openStream(key, nil, tampered); !errors.Is(err, enc.ErrChunk) {
+		t.Error("tampered stream accepted")
+	}
+
+	if _, err := openStream(key, []byte("other"), ciphertext); err == nil {
+		t.Error("stream opened with different associated data")
+	}
+
+	// nothing from a bad chunk is released, but earlier good chunks are
+	reader, _ := enc.NewStreamReader(key, nil, bytes.NewReader(ciphertext[:header+3*sealed_chunk]))
+	released, err := io.ReadAll(reader)
+
+	if err == nil || len(released) != 2*chunk || !bytes.Equal(released, plaintext[:2*chunk]) {
+		t.Error("unexpected output before the truncation was detected")
+	}
+}
@@ -0,0 +1,60 @@
+package timelock_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	grouptheory "github.com/SimonHorrocks/Zerocat/pkg/auth/group-theory"
+	"github.com/SimonHorrocks/Zerocat/pkg/enc/timelock"
+)
+
+func TestTimeLock(t *testing.T) {
+	group := grouptheory.SetupCompGroup(1024)
+	payload := []byte("the quick brown fox jumped over the lazy dog")
+
+	puzzle, err := timelock.Seal(group, 5000, payload)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sealing must not touch the caller's buffer
+	if !bytes.Equal(payload, []byte("the quick brown fox jumped over the lazy dog")) {
+		t.Error("payload was modified by sealing")
+	}
+
+	puzzle, err = timelock.ParsePuzzle(puzzle.Marshal())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solution, proof := puzzle.SolveWithProof()
+
+	if !puzzle.Verify(solution, proof) {
+		t.Error("valid proof rejected")
+	}
+
+	plaintext, err := puzzle.Open(solution)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(plaintext, payload) {
+		t.Error("opened payload does not match")
+	}
+
+	// a wrong solution can not be proven or used
+	wrong := big.NewInt(0)
+	wrong.Add(solution, big.NewInt(1))
+
+	if puzzle.Verify(wrong, proof) || puzzle.Verify(wrong, timelock.Prove(puzzle.Modulus(), puzzle.Base(), puzzle.Squarings(), wrong)) {
+		t.Error("proof accepted for a wrong solution")
+	}
+
+	if _, err := puzzle.Open(wrong); err == nil {
+		t.Error("puzzle opened with a wrong solution")
+	}
+}